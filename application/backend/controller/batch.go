@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"backend/pkg"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SwapIntentRequest is a swap committed to the current batch of a pair in batch auction mode
+type SwapIntentRequest struct {
+	PairID         string  `json:"pairId"`
+	TokenIn        string  `json:"tokenIn"`
+	Amount         string  `json:"amount"`
+	MaxSlippagePct float64 `json:"maxSlippagePct"` // applied to the current quote to set the intent's minimum output
+}
+
+// SubmitSwapIntent handles committing a swap intent to the pair's open batch
+func SubmitSwapIntent(c *gin.Context) {
+	var req SwapIntentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pairID := pairOrDefault(req.PairID)
+
+	amountIn, ok := new(big.Int).SetString(req.Amount, 10)
+	if !ok || amountIn.Cmp(big.NewInt(0)) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount"})
+		return
+	}
+	if !validSlippage(req.MaxSlippagePct) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slippage percentage"})
+		return
+	}
+
+	// The batch clears at a single price later, so the bound is taken from the pool price now
+	amountOut, err := quoteExactIn(pairID, req.TokenIn, amountIn)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to quote swap: %v", err)})
+		return
+	}
+	minAmountOut := applySlippage(amountOut, req.MaxSlippagePct)
+
+	// Call chaincode
+	txID, res, err := pkg.ChaincodeSubmitAs(currentUser(c), "Exchange:SubmitSwapIntent", []string{
+		pairID, req.TokenIn, amountIn.String(), minAmountOut.String(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to submit swap intent: %v", err)})
+		return
+	}
+	var intent struct {
+		IntentID string `json:"intentId"`
+	}
+	if err := json.Unmarshal([]byte(res), &intent); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to decode swap intent: %v", err)})
+		return
+	}
+	if err := pkg.InsertRecordOwner(intent.IntentID, currentUser(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to record swap intent owner: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"txId":   txID,
+		"intent": json.RawMessage(res),
+	})
+}
+
+// CancelSwapIntent handles the backend user who submitted a swap intent withdrawing it before its batch closes
+func CancelSwapIntent(c *gin.Context) {
+	var req struct {
+		IntentID string `json:"intentId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.IntentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "intentId is required"})
+		return
+	}
+	if !requireRecordOwner(c, req.IntentID) {
+		return
+	}
+
+	// Call chaincode
+	txID, res, err := pkg.ChaincodeSubmitAs(currentUser(c), "Exchange:CancelSwapIntent", []string{req.IntentID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to cancel swap intent: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"txId":   txID,
+		"intent": json.RawMessage(res),
+	})
+}
+
+// SettleBatch handles clearing the oldest closed batch of a pair
+func SettleBatch(c *gin.Context) {
+	var req struct {
+		PairID string `json:"pairId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call chaincode
+	txID, res, err := pkg.ChaincodeSubmit("Exchange:SettleBatch", []string{pairOrDefault(req.PairID)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to settle batch: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"txId":   txID,
+		"batch":  json.RawMessage(res),
+	})
+}
+
+// GetSwapIntent returns a single swap intent
+func GetSwapIntent(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("Exchange:GetSwapIntent", c.Param("intentId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get swap intent: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"intent": json.RawMessage(res),
+	})
+}
+
+// GetAccountSwapIntents returns every swap intent submitted by an account
+func GetAccountSwapIntents(c *gin.Context) {
+	accountQuery(c, "Exchange:GetSwapIntentsByAccount", "intents")
+}
+
+// GetBatchResult returns the clearing price and pool trade of a settled batch
+func GetBatchResult(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("Exchange:GetBatchResult", c.Param("pairId"), c.Param("batchId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get batch result: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"batch":  json.RawMessage(res),
+	})
+}
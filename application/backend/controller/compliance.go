@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"backend/pkg"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SurrenderRequest burns whole CCT against an enterprise's obligation in a compliance period
+type SurrenderRequest struct {
+	PeriodID string `json:"periodId"`
+	Amount   uint64 `json:"amount"`
+}
+
+// Surrender handles an enterprise surrendering CCT before the period deadline
+func Surrender(c *gin.Context) {
+	var req SurrenderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.PeriodID == "" || req.Amount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "periodId and amount are required"})
+		return
+	}
+
+	// Call chaincode
+	txID, res, err := pkg.ChaincodeSubmitAs(currentUser(c), "Compliance:Surrender", []string{req.PeriodID, strconv.FormatUint(req.Amount, 10)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to surrender credits: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"txId":       txID,
+		"obligation": json.RawMessage(res),
+	})
+}
+
+// GetCompliancePeriod returns a compliance period together with every enterprise obligation in it
+func GetCompliancePeriod(c *gin.Context) {
+	periodID := c.Param("periodId")
+	period, err := pkg.ChaincodeQuery("Compliance:GetPeriod", periodID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get compliance period: %v", err)})
+		return
+	}
+	obligations, err := pkg.ChaincodeQuery("Compliance:GetObligationsByPeriod", periodID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get obligations: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "success",
+		"period":      json.RawMessage(period),
+		"obligations": json.RawMessage(obligations),
+	})
+}
+
+// GetObligations returns an enterprise's obligations, in one period when periodId is given or across all periods
+func GetObligations(c *gin.Context) {
+	periodID := c.Query("periodId")
+	if periodID == "" {
+		accountQuery(c, "Compliance:GetObligationsByAccount", "obligations")
+		return
+	}
+
+	account := c.Query("account")
+	if account == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account is required"})
+		return
+	}
+	res, err := pkg.ChaincodeQuery("Compliance:GetObligation", periodID, account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get obligation: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "success",
+		"account":    account,
+		"obligation": json.RawMessage(res),
+	})
+}
@@ -2,175 +2,567 @@ package controller
 
 import (
 	"backend/pkg"
+	"encoding/json"
 	"fmt"
+	"math"
 	"math/big"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// txDeadline is how long a submitted exchange transaction stays valid on chain
+	txDeadline = 5 * time.Minute
+	// defaultPairID is used when a request does not name a pair
+	defaultPairID = "CCT-STABLE"
+)
+
+// LiquidityRequest amounts follow the token order of the pair ID, e.g. CCT then STABLE for CCT-STABLE
 type LiquidityRequest struct {
-	AmountETH       string  `json:"amountEth"`
-	MaxSlippagePct  float64 `json:"maxSlippagePct"`
+	PairID         string  `json:"pairId"`
+	Amount0        string  `json:"amount0"`
+	Amount1        string  `json:"amount1"` // optional when adding, derived from the pool ratio when empty
+	Shares         string  `json:"shares"`  // LP shares to burn when removing
+	MaxSlippagePct float64 `json:"maxSlippagePct"`
 }
 
 type SwapRequest struct {
-	Amount          string  `json:"amount"`
-	MaxSlippagePct  float64 `json:"maxSlippagePct"`
+	PairID         string  `json:"pairId"`
+	TokenIn        string  `json:"tokenIn"`
+	Amount         string  `json:"amount"`
+	MaxSlippagePct float64 `json:"maxSlippagePct"`
+}
+
+// poolState is a fresh snapshot of the pool used to quote liquidity changes
+type poolState struct {
+	Reserve0    *big.Int
+	Reserve1    *big.Int
+	TotalShares *big.Int
+}
+
+type swapResult struct {
+	TxID        string `json:"txId"`
+	AmountIn    string `json:"amountIn"`
+	AmountOut   string `json:"amountOut"`
+	HaltedUntil int64  `json:"haltedUntil"` // non-zero when the swap tripped the circuit breaker and was not executed
+}
+
+type liquidityResult struct {
+	TxID    string `json:"txId"`
+	Amount0 string `json:"amount0"`
+	Amount1 string `json:"amount1"`
+	Shares  string `json:"shares"`
+}
+
+type liquidityPosition struct {
+	Shares  string `json:"shares"`
+	Amount0 string `json:"amount0"`
+	Amount1 string `json:"amount1"`
+}
+
+type feeClaim struct {
+	TxID    string `json:"txId"`
+	Amount0 string `json:"amount0"`
+	Amount1 string `json:"amount1"`
+}
+
+// ListPairs returns every pair created by the factory
+func ListPairs(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("Exchange:ListPairs")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to list pairs: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"pairs":  json.RawMessage(res),
+	})
+}
+
+// GetPair returns a single pair with its reserves and fee
+func GetPair(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("Exchange:GetPair", c.Param("pairId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get pair: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"pair":   json.RawMessage(res),
+	})
 }
 
-// AddLiquidity handles adding liquidity to the pool
+// ListLiquidityProviders returns one page of a pair's liquidity providers with their share of the pool
+func ListLiquidityProviders(c *gin.Context) {
+	pageSize := c.DefaultQuery("pageSize", "20")
+	if n, err := strconv.Atoi(pageSize); err != nil || n <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pageSize"})
+		return
+	}
+
+	res, err := pkg.ChaincodeQuery("Exchange:ListLiquidityProviders", c.Param("pairId"), pageSize, c.Query("bookmark"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to list liquidity providers: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"page":   json.RawMessage(res),
+	})
+}
+
+// AddLiquidity handles adding liquidity to a pool
 func AddLiquidity(c *gin.Context) {
 	var req LiquidityRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	pairID := pairOrDefault(req.PairID)
 
-	// Validate ETH amount
-	ethAmount, ok := new(big.Int).SetString(req.AmountETH, 10)
-	if !ok || ethAmount.Cmp(big.NewInt(0)) <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ETH amount"})
+	// Validate amount and slippage
+	amount0, ok := new(big.Int).SetString(req.Amount0, 10)
+	if !ok || amount0.Cmp(big.NewInt(0)) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount0"})
+		return
+	}
+	if !validSlippage(req.MaxSlippagePct) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slippage percentage"})
 		return
 	}
 
+	pool, err := queryPoolState(pairID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to quote pool: %v", err)})
+		return
+	}
+
+	// Quote the second side from the current pool ratio unless the caller set it
+	var amount1 *big.Int
+	if req.Amount1 != "" {
+		amount1, ok = new(big.Int).SetString(req.Amount1, 10)
+		if !ok || amount1.Cmp(big.NewInt(0)) <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount1"})
+			return
+		}
+	} else if pool.Reserve0.Sign() > 0 {
+		amount1 = new(big.Int).Mul(amount0, pool.Reserve1)
+		amount1.Div(amount1, pool.Reserve0)
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount1 is required for an empty pool"})
+		return
+	}
+	amount0Min := applySlippage(amount0, req.MaxSlippagePct)
+	amount1Min := applySlippage(amount1, req.MaxSlippagePct)
+
 	// Call chaincode
-	res, err := pkg.ChaincodeInvoke("AddLiquidity", []string{req.AmountETH})
+	_, res, err := pkg.ChaincodeSubmitAs(currentUser(c), "Exchange:AddLiquidity", []string{
+		pairID, amount0.String(), amount1.String(), amount0Min.String(), amount1Min.String(), deadline(),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to add liquidity: %v", err)})
 		return
 	}
+	// The chaincode only takes the amounts matching the pool ratio, so report what was deposited
+	var result liquidityResult
+	if err := json.Unmarshal([]byte(res), &result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to parse liquidity result: %v", err)})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-		"txId":   res,
+		"status":     "success",
+		"txId":       result.TxID,
+		"amount0":    result.Amount0,
+		"amount1":    result.Amount1,
+		"shares":     result.Shares,
+		"amount0Min": amount0Min.String(),
+		"amount1Min": amount1Min.String(),
 	})
 }
 
-// RemoveLiquidity handles removing liquidity from the pool
+// RemoveLiquidity handles removing liquidity from a pool
 func RemoveLiquidity(c *gin.Context) {
 	var req LiquidityRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	pairID := pairOrDefault(req.PairID)
 
-	// Validate ETH amount and slippage
-	if req.MaxSlippagePct < 0 || req.MaxSlippagePct > 100 {
+	// Validate shares and slippage
+	shares, ok := new(big.Int).SetString(req.Shares, 10)
+	if !ok || shares.Cmp(big.NewInt(0)) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shares"})
+		return
+	}
+	if !validSlippage(req.MaxSlippagePct) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slippage percentage"})
 		return
 	}
 
-	// Call chaincode
-	response, err := pkg.ChaincodeInvoke("RemoveLiquidity", []string{req.AmountETH})
+	pool, err := queryPoolState(pairID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to quote pool: %v", err)})
+		return
+	}
+	if pool.TotalShares.Sign() == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pool has no liquidity"})
+		return
+	}
+
+	// Quote the amounts returned for these shares at the current reserves
+	amount0 := new(big.Int).Mul(shares, pool.Reserve0)
+	amount0.Div(amount0, pool.TotalShares)
+	amount1 := new(big.Int).Mul(shares, pool.Reserve1)
+	amount1.Div(amount1, pool.TotalShares)
+	amount0Min := applySlippage(amount0, req.MaxSlippagePct)
+	amount1Min := applySlippage(amount1, req.MaxSlippagePct)
 
+	// Call chaincode
+	response, err := pkg.ChaincodeInvokeAs(currentUser(c), "Exchange:RemoveLiquidity", []string{
+		pairID, shares.String(), amount0Min.String(), amount1Min.String(), deadline(),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to remove liquidity: %v", err)})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-		"txId":   response,
+		"status":     "success",
+		"txId":       response,
+		"amount0Min": amount0Min.String(),
+		"amount1Min": amount1Min.String(),
 	})
 }
 
-// RemoveAllLiquidity handles removing all liquidity from the pool
+// RemoveAllLiquidity handles removing all liquidity from a pool
 func RemoveAllLiquidity(c *gin.Context) {
 	var req struct {
+		PairID         string  `json:"pairId"`
 		MaxSlippagePct float64 `json:"maxSlippagePct"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	pairID := pairOrDefault(req.PairID)
 
 	// Validate slippage
-	if req.MaxSlippagePct < 0 || req.MaxSlippagePct > 100 {
+	if !validSlippage(req.MaxSlippagePct) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slippage percentage"})
 		return
 	}
 
-	// Call chaincode
-	response, err := pkg.ChaincodeInvoke("RemoveAllLiquidity", []string{})
+	// Quote the caller's whole position at the current reserves
+	res, err := pkg.ChaincodeQueryAs(currentUser(c), "Exchange:GetMyLiquidity", pairID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to quote liquidity: %v", err)})
+		return
+	}
+	var position liquidityPosition
+	if err := json.Unmarshal([]byte(res), &position); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to parse liquidity: %v", err)})
+		return
+	}
+	amount0, _ := new(big.Int).SetString(position.Amount0, 10)
+	amount1, _ := new(big.Int).SetString(position.Amount1, 10)
+	if amount0 == nil || amount1 == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse liquidity"})
+		return
+	}
+	amount0Min := applySlippage(amount0, req.MaxSlippagePct)
+	amount1Min := applySlippage(amount1, req.MaxSlippagePct)
 
+	// Call chaincode
+	response, err := pkg.ChaincodeInvokeAs(currentUser(c), "Exchange:RemoveAllLiquidity", []string{
+		pairID, amount0Min.String(), amount1Min.String(), deadline(),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to remove all liquidity: %v", err)})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-		"txId":   response,
+		"status":     "success",
+		"txId":       response,
+		"amount0Min": amount0Min.String(),
+		"amount1Min": amount1Min.String(),
 	})
 }
 
-// SwapTokensForETH handles token to ETH swaps
-func SwapTokensForETH(c *gin.Context) {
-	var req SwapRequest
+// ClaimFees handles claiming the swap fees earned by the caller's liquidity
+func ClaimFees(c *gin.Context) {
+	var req struct {
+		PairID string `json:"pairId"`
+	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	pairID := pairOrDefault(req.PairID)
 
-	// Validate amount and slippage
-	tokenAmount, ok := new(big.Int).SetString(req.Amount, 10)
-	if !ok || tokenAmount.Cmp(big.NewInt(0)) <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token amount"})
+	// Call chaincode
+	_, res, err := pkg.ChaincodeSubmitAs(currentUser(c), "Exchange:ClaimFees", []string{pairID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to claim fees: %v", err)})
+		return
+	}
+	var claim feeClaim
+	if err := json.Unmarshal([]byte(res), &claim); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to parse fee claim: %v", err)})
 		return
 	}
 
-	if req.MaxSlippagePct < 0 || req.MaxSlippagePct > 100 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slippage percentage"})
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"txId":    claim.TxID,
+		"pairId":  pairID,
+		"amount0": claim.Amount0,
+		"amount1": claim.Amount1,
+	})
+}
+
+// GetLPBalance returns an account's LP share tokens for a pair
+func GetLPBalance(c *gin.Context) {
+	owner := c.Query("owner")
+	if owner == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "owner is required"})
 		return
 	}
 
-	// Call chaincode
-	response, err := pkg.ChaincodeInvoke("SwapTokensForETH", []string{req.Amount})
+	res, err := pkg.ChaincodeQuery("Exchange:BalanceOf", c.Param("pairId"), owner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get LP balance: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"pairId":  c.Param("pairId"),
+		"owner":   owner,
+		"balance": res,
+	})
+}
+
+// TransferLiquidity handles moving LP share tokens to another account
+func TransferLiquidity(c *gin.Context) {
+	var req struct {
+		PairID string `json:"pairId"`
+		To     string `json:"to"`
+		Shares string `json:"shares"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pairID := pairOrDefault(req.PairID)
+
+	// Validate recipient and shares
+	if req.To == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to is required"})
+		return
+	}
+	shares, ok := new(big.Int).SetString(req.Shares, 10)
+	if !ok || shares.Cmp(big.NewInt(0)) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shares"})
+		return
+	}
 
+	// Call chaincode
+	res, err := pkg.ChaincodeInvokeAs(currentUser(c), "Exchange:Transfer", []string{pairID, req.To, shares.String()})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to swap tokens for ETH: %v", err)})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to transfer liquidity: %v", err)})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":    "success",
-		"txId":      response,
-		"ethAmount": string(response),
+		"status": "success",
+		"txId":   res,
 	})
 }
 
-// SwapETHForTokens handles ETH to token swaps
+// Swap handles selling an exact amount of tokenIn in a pool
+func Swap(c *gin.Context) {
+	var req SwapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.TokenIn == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tokenIn is required"})
+		return
+	}
+	swap(c, &req)
+}
+
+// SwapTokensForETH handles CCT to STABLE swaps in the default pool
+func SwapTokensForETH(c *gin.Context) {
+	var req SwapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.PairID = defaultPairID
+	req.TokenIn = "CCT"
+	swap(c, &req)
+}
+
+// SwapETHForTokens handles STABLE to CCT swaps in the default pool
 func SwapETHForTokens(c *gin.Context) {
 	var req SwapRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.PairID = defaultPairID
+	req.TokenIn = "STABLE"
+	swap(c, &req)
+}
+
+// swap quotes the trade, bounds it by the caller's slippage and submits it
+func swap(c *gin.Context, req *SwapRequest) {
+	pairID := pairOrDefault(req.PairID)
 
 	// Validate amount and slippage
-	ethAmount, ok := new(big.Int).SetString(req.Amount, 10)
-	if !ok || ethAmount.Cmp(big.NewInt(0)) <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ETH amount"})
+	amountIn, ok := new(big.Int).SetString(req.Amount, 10)
+	if !ok || amountIn.Cmp(big.NewInt(0)) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount"})
 		return
 	}
 
-	if req.MaxSlippagePct < 0 || req.MaxSlippagePct > 100 {
+	if !validSlippage(req.MaxSlippagePct) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slippage percentage"})
 		return
 	}
 
+	// Quote with the chaincode so the bound uses the same fee and rounding as the swap
+	amountOut, err := quoteExactIn(pairID, req.TokenIn, amountIn)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to quote swap: %v", err)})
+		return
+	}
+	minAmountOut := applySlippage(amountOut, req.MaxSlippagePct)
+
 	// Call chaincode
-	response, err := pkg.ChaincodeInvoke("SwapETHForTokens", []string{req.Amount})
+	_, res, err := pkg.ChaincodeSubmitAs(currentUser(c), "Exchange:SwapExactIn", []string{
+		pairID, req.TokenIn, amountIn.String(), minAmountOut.String(), deadline(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to swap %s: %v", req.TokenIn, err)})
+		return
+	}
+	var result swapResult
+	if err := json.Unmarshal([]byte(res), &result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to parse swap result: %v", err)})
+		return
+	}
+	if result.HaltedUntil != 0 {
+		circuitBreakerTripped(c, result.TxID, result.HaltedUntil)
+		return
+	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "success",
+		"txId":         result.TxID,
+		"pairId":       pairID,
+		"tokenIn":      req.TokenIn,
+		"amountIn":     result.AmountIn,
+		"amountOut":    result.AmountOut,
+		"minAmountOut": minAmountOut.String(),
+	})
+}
+
+// circuitBreakerTripped reports a swap that was rejected because it tripped the circuit breaker;
+// the transaction itself is committed so the halt is recorded on chain
+func circuitBreakerTripped(c *gin.Context, txID string, haltedUntil int64) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":       "Swap moves the price beyond the circuit breaker limit, trading is halted",
+		"txId":        txID,
+		"haltedUntil": haltedUntil,
+	})
+}
+
+// GetTradingStatus returns whether a pair is paused or halted by the circuit breaker
+func GetTradingStatus(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("Exchange:GetTradingStatus", c.Param("pairId"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to swap ETH for tokens: %v", err)})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get trading status: %v", err)})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":      "success",
-		"txId":        response,
-		"tokenAmount": string(response),
+		"status":  "success",
+		"trading": json.RawMessage(res),
 	})
-}
\ No newline at end of file
+}
+
+// GetCircuitBreaker returns the circuit breaker settings
+func GetCircuitBreaker(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("Exchange:GetCircuitBreaker")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get circuit breaker: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":         "success",
+		"circuitBreaker": json.RawMessage(res),
+	})
+}
+
+// queryPoolState reads the current reserves and total shares of a pool
+func queryPoolState(pairID string) (*poolState, error) {
+	res, err := pkg.ChaincodeQuery("Exchange:GetReserves", pairID)
+	if err != nil {
+		return nil, err
+	}
+	var reserves struct {
+		Reserve0    string `json:"reserve0"`
+		Reserve1    string `json:"reserve1"`
+		TotalShares string `json:"totalShares"`
+	}
+	if err := json.Unmarshal([]byte(res), &reserves); err != nil {
+		return nil, fmt.Errorf("failed to parse reserves: %w", err)
+	}
+
+	state := &poolState{}
+	var ok1, ok2, ok3 bool
+	state.Reserve0, ok1 = new(big.Int).SetString(reserves.Reserve0, 10)
+	state.Reserve1, ok2 = new(big.Int).SetString(reserves.Reserve1, 10)
+	state.TotalShares, ok3 = new(big.Int).SetString(reserves.TotalShares, 10)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, fmt.Errorf("failed to parse reserves")
+	}
+	return state, nil
+}
+
+// applySlippage returns the smallest acceptable amount for the given slippage percentage
+func applySlippage(amount *big.Int, slippagePct float64) *big.Int {
+	// Work in basis points so fractional percentages such as 0.5% are honoured
+	bps := int64(math.Round(slippagePct * 100))
+	minAmount := new(big.Int).Mul(amount, big.NewInt(10000-bps))
+	return minAmount.Div(minAmount, big.NewInt(10000))
+}
+
+func validSlippage(slippagePct float64) bool {
+	return slippagePct >= 0 && slippagePct <= 100
+}
+
+func pairOrDefault(pairID string) string {
+	if pairID == "" {
+		return defaultPairID
+	}
+	return pairID
+}
+
+// deadline is the Unix time after which the chaincode rejects the transaction
+func deadline() string {
+	return strconv.FormatInt(time.Now().Add(txDeadline).Unix(), 10)
+}
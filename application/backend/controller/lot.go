@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"backend/pkg"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LotRequest moves whole credits of one lot, either to another account or into and out of a basket
+type LotRequest struct {
+	LotID  string `json:"lotId"`
+	To     string `json:"to"`     // recipient for transfers
+	Basket string `json:"basket"` // basket token symbol for deposits and redemptions
+	Amount uint64 `json:"amount"`
+}
+
+// TransferLot handles sending credits of a specific lot to another account
+func TransferLot(c *gin.Context) {
+	var req LotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.LotID == "" || req.To == "" || req.Amount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lotId, to and amount are required"})
+		return
+	}
+
+	submitLot(c, "CarbonCoinToken:TransferLot", "transfer lot", []string{req.LotID, req.To, strconv.FormatUint(req.Amount, 10)})
+}
+
+// DepositLot handles exchanging eligible lot credits for basket tokens that trade in the basket's pools
+func DepositLot(c *gin.Context) {
+	basketLot(c, "Exchange:DepositLot", "deposit lot")
+}
+
+// RedeemLot handles burning basket tokens to withdraw credits of a chosen lot
+func RedeemLot(c *gin.Context) {
+	basketLot(c, "Exchange:RedeemLot", "redeem lot")
+}
+
+func basketLot(c *gin.Context, fcn string, action string) {
+	var req LotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Basket == "" || req.LotID == "" || req.Amount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "basket, lotId and amount are required"})
+		return
+	}
+
+	submitLot(c, fcn, action, []string{req.Basket, req.LotID, strconv.FormatUint(req.Amount, 10)})
+}
+
+func submitLot(c *gin.Context, fcn string, action string, args []string) {
+	txID, err := pkg.ChaincodeInvokeAs(currentUser(c), fcn, args)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"txId":   txID,
+	})
+}
+
+// GetLot returns a lot's attributes with its issued, outstanding and retired amounts
+func GetLot(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("CarbonCoinToken:GetLot", c.Param("lotId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get lot: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"lot":    json.RawMessage(res),
+	})
+}
+
+// GetProjectLots returns every lot issued for a project
+func GetProjectLots(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("CarbonCoinToken:GetLotsByProject", c.Param("projectId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get project lots: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"lots":   json.RawMessage(res),
+	})
+}
+
+// GetAccountLots returns the per-lot credit balances of an account
+func GetAccountLots(c *gin.Context) {
+	accountQuery(c, "CarbonCoinToken:GetLotBalances", "balances")
+}
+
+// GetBasket returns a basket's eligibility rule, supply and the lots backing it
+func GetBasket(c *gin.Context) {
+	symbol := c.Param("symbol")
+	basket, err := pkg.ChaincodeQuery("Exchange:GetBasket", symbol)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get basket: %v", err)})
+		return
+	}
+	lots, err := pkg.ChaincodeQuery("Exchange:GetBasketLots", symbol)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get basket lots: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"basket": json.RawMessage(basket),
+		"lots":   json.RawMessage(lots),
+	})
+}
+
+// GetSerialStatus returns the range, owner and retirement status of a single credit serial
+func GetSerialStatus(c *gin.Context) {
+	serial, err := strconv.ParseUint(c.Param("serial"), 10, 64)
+	if err != nil || serial == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid serial"})
+		return
+	}
+
+	res, err := pkg.ChaincodeQuery("CarbonCoinToken:GetSerialStatus", strconv.FormatUint(serial, 10))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get serial status: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"serial": json.RawMessage(res),
+	})
+}
+
+// GetAccountSerials returns the active serial ranges an account holds in a lot
+func GetAccountSerials(c *gin.Context) {
+	account := c.Query("account")
+	if account == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account is required"})
+		return
+	}
+
+	res, err := pkg.ChaincodeQuery("CarbonCoinToken:GetSerialRanges", account, c.Param("lotId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get serial ranges: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"account": account,
+		"ranges":  json.RawMessage(res),
+	})
+}
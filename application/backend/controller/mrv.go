@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"backend/pkg"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmissionLine is one scope and source of an emissions report, in tonnes of CO2e
+type EmissionLine struct {
+	Scope     int    `json:"scope"`
+	Source    string `json:"source"`
+	Emissions uint64 `json:"emissions"`
+}
+
+// ReportRequest submits or amends an annual emissions report, the lines stay in the enterprise's private collection
+type ReportRequest struct {
+	ReportID       string         `json:"reportId"` // set when amending an existing report
+	Year           int            `json:"year"`
+	Lines          []EmissionLine `json:"lines"`
+	EvidenceHashes []string       `json:"evidenceHashes"` // SHA-256 hex digests of the supporting documents
+}
+
+// SubmitReport handles an enterprise submitting a new emissions report or amending one that has findings
+func SubmitReport(c *gin.Context) {
+	var req ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Lines) == 0 || len(req.EvidenceHashes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lines and evidenceHashes are required"})
+		return
+	}
+	if req.ReportID == "" && req.Year <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+		return
+	}
+
+	lines, err := json.Marshal(req.Lines)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to encode emission lines: %v", err)})
+		return
+	}
+	evidenceHashes, err := json.Marshal(req.EvidenceHashes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to encode evidence hashes: %v", err)})
+		return
+	}
+
+	fcn := "MRV:SubmitReport"
+	args := []string{strconv.Itoa(req.Year), string(evidenceHashes)}
+	if req.ReportID != "" {
+		if !requireRecordOwner(c, req.ReportID) {
+			return
+		}
+		fcn = "MRV:AmendReport"
+		args = []string{req.ReportID, string(evidenceHashes)}
+	}
+
+	// A random salt keeps the public data hash from being reversed by trying plausible emission figures
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to generate salt: %v", err)})
+		return
+	}
+
+	// The emission figures travel as transient data so they never appear in the proposal arguments or the block
+	txID, res, err := pkg.ChaincodeSubmitTransientAs(currentUser(c), fcn, args, map[string][]byte{
+		"report": lines,
+		"salt":   []byte(hex.EncodeToString(salt)),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to submit report: %v", err)})
+		return
+	}
+	if req.ReportID == "" {
+		var report struct {
+			ReportID string `json:"reportId"`
+		}
+		if err := json.Unmarshal([]byte(res), &report); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to decode report: %v", err)})
+			return
+		}
+		if err := pkg.InsertRecordOwner(report.ReportID, currentUser(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to record report owner: %v", err)})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"txId":   txID,
+		"report": json.RawMessage(res),
+	})
+}
+
+// GetReport returns the public part of an emissions report
+func GetReport(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("MRV:GetReport", c.Param("reportId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get report: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"report": json.RawMessage(res),
+	})
+}
+
+// GetReportData returns the private emission figures of a report to the backend user who submitted it
+func GetReportData(c *gin.Context) {
+	if !requireRecordOwner(c, c.Param("reportId")) {
+		return
+	}
+
+	res, err := pkg.ChaincodeQueryAs(currentUser(c), "MRV:GetReportData", c.Param("reportId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get report data: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   json.RawMessage(res),
+	})
+}
+
+// GetReportHistory returns every submitted, amended, verified and approved version of a report
+func GetReportHistory(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("MRV:GetReportHistory", c.Param("reportId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get report history: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"history": json.RawMessage(res),
+	})
+}
+
+// GetAccountReports returns every emissions report an account submitted
+func GetAccountReports(c *gin.Context) {
+	accountQuery(c, "MRV:GetReportsByAccount", "reports")
+}
@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"backend/pkg"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrderRequest prices are STABLE cents per CCT and amounts are whole CCT
+type OrderRequest struct {
+	Side       string `json:"side"` // BUY or SELL
+	Price      uint64 `json:"price"`
+	Amount     uint64 `json:"amount"`
+	Expiry     int64  `json:"expiry"`     // Unix seconds, 0 keeps the order until cancelled
+	LimitPrice uint64 `json:"limitPrice"` // worst acceptable price for market orders
+	UsePool    bool   `json:"usePool"`    // also route market orders through the CCT-STABLE pool
+}
+
+type orderResult struct {
+	TxID          string          `json:"txId"`
+	Order         json.RawMessage `json:"order"`
+	Fills         json.RawMessage `json:"fills"`
+	PoolAmountIn  string          `json:"poolAmountIn"`
+	PoolAmountOut string          `json:"poolAmountOut"`
+}
+
+// PlaceOrder handles placing a limit order on the order book
+func PlaceOrder(c *gin.Context) {
+	var req OrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validOrder(c, req.Side, req.Price, req.Amount) {
+		return
+	}
+
+	submitOrder(c, "LimitOrderBook:PlaceOrder", []string{
+		req.Side,
+		strconv.FormatUint(req.Price, 10),
+		strconv.FormatUint(req.Amount, 10),
+		strconv.FormatInt(req.Expiry, 10),
+	})
+}
+
+// MarketOrder handles an immediate order against the book, optionally routed through the pool as well
+func MarketOrder(c *gin.Context) {
+	var req OrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validOrder(c, req.Side, req.LimitPrice, req.Amount) {
+		return
+	}
+
+	submitOrder(c, "LimitOrderBook:MarketOrder", []string{
+		req.Side,
+		strconv.FormatUint(req.Amount, 10),
+		strconv.FormatUint(req.LimitPrice, 10),
+		strconv.FormatBool(req.UsePool),
+		deadline(),
+	})
+}
+
+// CancelOrder handles the backend user who placed a resting order cancelling it and refunding its escrow
+func CancelOrder(c *gin.Context) {
+	var req struct {
+		OrderID string `json:"orderId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.OrderID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "orderId is required"})
+		return
+	}
+	if !requireRecordOwner(c, req.OrderID) {
+		return
+	}
+
+	// Call chaincode
+	txID, res, err := pkg.ChaincodeSubmitAs(currentUser(c), "LimitOrderBook:CancelOrder", []string{req.OrderID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to cancel order: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"txId":   txID,
+		"order":  json.RawMessage(res),
+	})
+}
+
+// GetOrderBook returns the aggregated price levels on both sides of the book
+func GetOrderBook(c *gin.Context) {
+	depth := c.DefaultQuery("depth", "20")
+	if n, err := strconv.Atoi(depth); err != nil || n <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid depth"})
+		return
+	}
+
+	res, err := pkg.ChaincodeQuery("LimitOrderBook:GetOrderBook", depth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get order book: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"book":   json.RawMessage(res),
+	})
+}
+
+// GetOrder returns a single order
+func GetOrder(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("LimitOrderBook:GetOrder", c.Param("orderId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get order: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"order":  json.RawMessage(res),
+	})
+}
+
+// GetAccountOrders returns every order placed by an account
+func GetAccountOrders(c *gin.Context) {
+	accountQuery(c, "LimitOrderBook:GetOrdersByAccount", "orders")
+}
+
+// GetAccountFills returns every fill an account took part in as maker or taker
+func GetAccountFills(c *gin.Context) {
+	accountQuery(c, "LimitOrderBook:GetFillsByAccount", "fills")
+}
+
+func accountQuery(c *gin.Context, fcn string, field string) {
+	account := c.Query("account")
+	if account == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account is required"})
+		return
+	}
+
+	res, err := pkg.ChaincodeQuery(fcn, account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get %s: %v", field, err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"account": account,
+		field:     json.RawMessage(res),
+	})
+}
+
+// submitOrder submits an order transaction and returns its fills
+func submitOrder(c *gin.Context, fcn string, args []string) {
+	_, res, err := pkg.ChaincodeSubmitAs(currentUser(c), fcn, args)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to place order: %v", err)})
+		return
+	}
+	var result orderResult
+	if err := json.Unmarshal([]byte(res), &result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to parse order result: %v", err)})
+		return
+	}
+	var order struct {
+		OrderID string `json:"orderId"`
+	}
+	if err := json.Unmarshal(result.Order, &order); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to parse order: %v", err)})
+		return
+	}
+	if err := pkg.InsertRecordOwner(order.OrderID, currentUser(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to record order owner: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":        "success",
+		"txId":          result.TxID,
+		"order":         result.Order,
+		"fills":         result.Fills,
+		"poolAmountIn":  result.PoolAmountIn,
+		"poolAmountOut": result.PoolAmountOut,
+	})
+}
+
+func validOrder(c *gin.Context, side string, price uint64, amount uint64) bool {
+	if side != "BUY" && side != "SELL" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "side must be BUY or SELL"})
+		return false
+	}
+	if price == 0 || amount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid price or amount"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"backend/pkg"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accountIDPattern matches an on-chain account ID, the SHA-256 hex digest of the MSP ID and certificate ID
+var accountIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// OfferRequest is an OTC block offer, the price is STABLE cents per CCT and the amount is whole CCT
+type OfferRequest struct {
+	Counterparty string `json:"counterparty"` // on-chain account ID of the buyer, who accepts with their own identity
+	Price        uint64 `json:"price"`
+	Amount       uint64 `json:"amount"`
+	Expiry       int64  `json:"expiry"` // Unix seconds
+}
+
+// CreateOffer handles a seller escrowing CCT for a named counterparty at a price kept private until settlement
+// Backend users all sign with the gateway identity and share one account, so the counterparty must be an account outside the backend
+func CreateOffer(c *gin.Context) {
+	var req OfferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !accountIDPattern.MatchString(req.Counterparty) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "counterparty must be an on-chain account ID"})
+		return
+	}
+	if req.Price == 0 || req.Amount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid price or amount"})
+		return
+	}
+	if req.Expiry <= time.Now().Unix() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry"})
+		return
+	}
+
+	// A random salt keeps the public terms hash from being reversed by trying every price
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to generate salt: %v", err)})
+		return
+	}
+
+	// The price travels as transient data so it never appears in the proposal arguments or the block
+	txID, res, err := pkg.ChaincodeSubmitTransientAs(currentUser(c), "OTC:CreateOffer", []string{
+		req.Counterparty,
+		strconv.FormatUint(req.Amount, 10),
+		strconv.FormatInt(req.Expiry, 10),
+	}, map[string][]byte{
+		"price": []byte(strconv.FormatUint(req.Price, 10)),
+		"salt":  []byte(hex.EncodeToString(salt)),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create offer: %v", err)})
+		return
+	}
+	var offer struct {
+		OfferID string `json:"offerId"`
+	}
+	if err := json.Unmarshal([]byte(res), &offer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to decode offer: %v", err)})
+		return
+	}
+	if err := pkg.InsertRecordOwner(offer.OfferID, currentUser(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to record offer owner: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"txId":   txID,
+		"offer":  json.RawMessage(res),
+	})
+}
+
+// CancelOffer handles the backend user who created an offer withdrawing it and refunding the escrowed CCT
+func CancelOffer(c *gin.Context) {
+	var req struct {
+		OfferID string `json:"offerId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.OfferID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offerId is required"})
+		return
+	}
+	if !requireRecordOwner(c, req.OfferID) {
+		return
+	}
+
+	// Call chaincode
+	txID, res, err := pkg.ChaincodeSubmitAs(currentUser(c), "OTC:CancelOffer", []string{req.OfferID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to cancel offer: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"txId":   txID,
+		"offer":  json.RawMessage(res),
+	})
+}
+
+// GetOffer returns the public part of an offer
+func GetOffer(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("OTC:GetOffer", c.Param("offerId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get offer: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"offer":  json.RawMessage(res),
+	})
+}
+
+// GetOfferTerms returns the private price of an offer to the backend user who created it
+func GetOfferTerms(c *gin.Context) {
+	if !requireRecordOwner(c, c.Param("offerId")) {
+		return
+	}
+
+	res, err := pkg.ChaincodeQueryAs(currentUser(c), "OTC:GetOfferTerms", c.Param("offerId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get offer terms: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"terms":  json.RawMessage(res),
+	})
+}
+
+// GetAccountOffers returns every offer an account made or received
+func GetAccountOffers(c *gin.Context) {
+	accountQuery(c, "OTC:GetOffersByAccount", "offers")
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"backend/pkg"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuoteExactIn previews the output of swapping an exact input amount
+func QuoteExactIn(c *gin.Context) {
+	quoteAmount(c, "Exchange:QuoteExactIn")
+}
+
+// QuoteExactOut previews the input needed for an exact output amount
+func QuoteExactOut(c *gin.Context) {
+	quoteAmount(c, "Exchange:QuoteExactOut")
+}
+
+// GetSpotPrice returns the current price of tokenIn in units of the other token of the pair
+func GetSpotPrice(c *gin.Context) {
+	pairID := pairOrDefault(c.Query("pairId"))
+	tokenIn := c.Query("tokenIn")
+	if tokenIn == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tokenIn is required"})
+		return
+	}
+
+	res, err := pkg.ChaincodeQuery("Exchange:GetSpotPrice", pairID, tokenIn)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get spot price: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "success",
+		"pairId":    pairID,
+		"tokenIn":   tokenIn,
+		"spotPrice": res,
+	})
+}
+
+// GetPriceImpact returns the price impact percentage of swapping amount of tokenIn
+func GetPriceImpact(c *gin.Context) {
+	pairID, tokenIn, amount, ok := quoteParams(c)
+	if !ok {
+		return
+	}
+
+	res, err := pkg.ChaincodeQuery("Exchange:GetPriceImpact", pairID, tokenIn, amount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get price impact: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "success",
+		"pairId":      pairID,
+		"tokenIn":     tokenIn,
+		"amount":      amount,
+		"priceImpact": res,
+	})
+}
+
+func quoteAmount(c *gin.Context, fcn string) {
+	pairID, tokenIn, amount, ok := quoteParams(c)
+	if !ok {
+		return
+	}
+
+	res, err := pkg.ChaincodeQuery(fcn, pairID, tokenIn, amount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get quote: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"quote":  json.RawMessage(res),
+	})
+}
+
+// GetTWAP returns the time-weighted average price of a pool over the last window seconds
+func GetTWAP(c *gin.Context) {
+	window := c.DefaultQuery("window", "3600")
+	if n, err := strconv.ParseInt(window, 10, 64); err != nil || n <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window"})
+		return
+	}
+
+	res, err := pkg.ChaincodeQuery("Exchange:GetTWAP", c.Param("pairId"), window)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get TWAP: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"twap":   json.RawMessage(res),
+	})
+}
+
+// quoteParams reads and validates the pairId, tokenIn and amount query parameters
+func quoteParams(c *gin.Context) (string, string, string, bool) {
+	pairID := pairOrDefault(c.Query("pairId"))
+	tokenIn := c.Query("tokenIn")
+	if tokenIn == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tokenIn is required"})
+		return "", "", "", false
+	}
+	amount, ok := new(big.Int).SetString(c.Query("amount"), 10)
+	if !ok || amount.Cmp(big.NewInt(0)) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount"})
+		return "", "", "", false
+	}
+	return pairID, tokenIn, amount.String(), true
+}
+
+// quoteExactIn fetches an exact-input quote from the chaincode
+func quoteExactIn(pairID string, tokenIn string, amountIn *big.Int) (*big.Int, error) {
+	res, err := pkg.ChaincodeQuery("Exchange:QuoteExactIn", pairID, tokenIn, amountIn.String())
+	if err != nil {
+		return nil, err
+	}
+	var quote struct {
+		AmountOut string `json:"amountOut"`
+	}
+	if err := json.Unmarshal([]byte(res), &quote); err != nil {
+		return nil, fmt.Errorf("failed to parse quote: %w", err)
+	}
+	amountOut, ok := new(big.Int).SetString(quote.AmountOut, 10)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse quote amount %q", quote.AmountOut)
+	}
+	return amountOut, nil
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"backend/pkg"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetireRequest burns whole CCT against a beneficiary's emissions for a reporting year
+type RetireRequest struct {
+	Amount        uint64 `json:"amount"`
+	Beneficiary   string `json:"beneficiary"`
+	Purpose       string `json:"purpose"`
+	ReportingYear int    `json:"reportingYear"`
+	LotID         string `json:"lotId"` // retire credits of this lot instead of unattributed CCT
+}
+
+// Retire handles retiring CCT and returns the retirement certificate
+func Retire(c *gin.Context) {
+	var req RetireRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Amount == 0 || req.ReportingYear <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount or reporting year"})
+		return
+	}
+	if req.Beneficiary == "" || req.Purpose == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "beneficiary and purpose are required"})
+		return
+	}
+
+	fcn := "CarbonCoinToken:Retire"
+	args := []string{
+		strconv.FormatUint(req.Amount, 10),
+		req.Beneficiary,
+		req.Purpose,
+		strconv.Itoa(req.ReportingYear),
+	}
+	if req.LotID != "" {
+		fcn = "CarbonCoinToken:RetireLot"
+		args = append([]string{req.LotID}, args...)
+	}
+
+	// Call chaincode
+	txID, res, err := pkg.ChaincodeSubmitAs(currentUser(c), fcn, args)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to retire credits: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "success",
+		"txId":        txID,
+		"certificate": json.RawMessage(res),
+	})
+}
+
+// GetRetirementCertificate returns a retirement certificate by its ID
+func GetRetirementCertificate(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("CarbonCoinToken:GetRetirementCertificate", c.Param("certificateId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get retirement certificate: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "success",
+		"certificate": json.RawMessage(res),
+	})
+}
+
+// GetRetirements lists retirement certificates by beneficiary, reporting year or account
+func GetRetirements(c *gin.Context) {
+	var fcn, arg string
+	switch {
+	case c.Query("beneficiary") != "":
+		fcn, arg = "CarbonCoinToken:GetRetirementsByBeneficiary", c.Query("beneficiary")
+	case c.Query("year") != "":
+		if _, err := strconv.Atoi(c.Query("year")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+			return
+		}
+		fcn, arg = "CarbonCoinToken:GetRetirementsByYear", c.Query("year")
+	case c.Query("account") != "":
+		fcn, arg = "CarbonCoinToken:GetRetirementsByAccount", c.Query("account")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "beneficiary, year or account is required"})
+		return
+	}
+
+	res, err := pkg.ChaincodeQuery(fcn, arg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get retirements: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "success",
+		"certificates": json.RawMessage(res),
+	})
+}
+
+// GetRetiredSupply returns the total CCT retired so far
+func GetRetiredSupply(c *gin.Context) {
+	res, err := pkg.ChaincodeQuery("CarbonCoinToken:RetiredSupply")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get retired supply: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"retired": res,
+	})
+}
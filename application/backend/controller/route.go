@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"backend/pkg"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRouteTokens matches the chaincode limit of four tokens (three hops) per path
+const maxRouteTokens = 4
+
+type RouteRequest struct {
+	TokenIn        string  `json:"tokenIn"`
+	TokenOut       string  `json:"tokenOut"`
+	Amount         string  `json:"amount"`
+	MaxSlippagePct float64 `json:"maxSlippagePct"`
+}
+
+// pairReserves is the subset of a chaincode pair needed to price a hop
+type pairReserves struct {
+	PairID        string `json:"pairId"`
+	Token0        string `json:"token0"`
+	Token1        string `json:"token1"`
+	Reserve0      string `json:"reserve0"`
+	Reserve1      string `json:"reserve1"`
+	SwapFeeNum    uint64 `json:"swapFeeNum"`
+	SwapFeeDenom  uint64 `json:"swapFeeDenom"`
+	Paused        bool   `json:"paused"`
+	HaltedUntil   int64  `json:"haltedUntil"`
+	BatchInterval int64  `json:"batchInterval"`
+}
+
+// swapRoute is a path with the amount of every token along it, amounts[0] being the input
+type swapRoute struct {
+	Path    []string
+	Amounts []string
+}
+
+type pathSwapResult struct {
+	TxID        string   `json:"txId"`
+	Path        []string `json:"path"`
+	Amounts     []string `json:"amounts"`
+	HaltedUntil int64    `json:"haltedUntil"`
+}
+
+// GetSwapRoute returns the best path and its quote for selling amount of tokenIn for tokenOut
+func GetSwapRoute(c *gin.Context) {
+	req := RouteRequest{TokenIn: c.Query("tokenIn"), TokenOut: c.Query("tokenOut"), Amount: c.Query("amount")}
+	amountIn, ok := validRoute(c, &req)
+	if !ok {
+		return
+	}
+
+	route, err := findRoute(req.TokenIn, req.TokenOut, amountIn)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to find route: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "success",
+		"path":      route.Path,
+		"amounts":   route.Amounts,
+		"amountIn":  amountIn.String(),
+		"amountOut": route.Amounts[len(route.Amounts)-1],
+	})
+}
+
+// SwapAlongRoute finds the best path and swaps along it in a single transaction
+func SwapAlongRoute(c *gin.Context) {
+	var req RouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	amountIn, ok := validRoute(c, &req)
+	if !ok {
+		return
+	}
+	if !validSlippage(req.MaxSlippagePct) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid slippage percentage"})
+		return
+	}
+
+	route, err := findRoute(req.TokenIn, req.TokenOut, amountIn)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to find route: %v", err)})
+		return
+	}
+	amountOut, _ := new(big.Int).SetString(route.Amounts[len(route.Amounts)-1], 10)
+	minAmountOut := applySlippage(amountOut, req.MaxSlippagePct)
+	path, err := json.Marshal(route.Path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to encode path: %v", err)})
+		return
+	}
+
+	// Call chaincode
+	_, res, err := pkg.ChaincodeSubmitAs(currentUser(c), "Exchange:SwapExactInAlongPath", []string{
+		string(path), amountIn.String(), minAmountOut.String(), deadline(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to swap along path: %v", err)})
+		return
+	}
+	var result pathSwapResult
+	if err := json.Unmarshal([]byte(res), &result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to parse swap result: %v", err)})
+		return
+	}
+	if result.HaltedUntil != 0 {
+		circuitBreakerTripped(c, result.TxID, result.HaltedUntil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "success",
+		"txId":         result.TxID,
+		"path":         result.Path,
+		"amounts":      result.Amounts,
+		"amountIn":     result.Amounts[0],
+		"amountOut":    result.Amounts[len(result.Amounts)-1],
+		"minAmountOut": minAmountOut.String(),
+	})
+}
+
+func validRoute(c *gin.Context, req *RouteRequest) (*big.Int, bool) {
+	if req.TokenIn == "" || req.TokenOut == "" || req.TokenIn == req.TokenOut {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tokenIn and tokenOut must be two different tokens"})
+		return nil, false
+	}
+	amountIn, ok := new(big.Int).SetString(req.Amount, 10)
+	if !ok || amountIn.Cmp(big.NewInt(0)) <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount"})
+		return nil, false
+	}
+	return amountIn, true
+}
+
+// findRoute searches every simple path over the current reserves for the largest output,
+// skipping pairs that are paused, halted by the circuit breaker or only clear in batch auctions,
+// then re-quotes the chosen path with the chaincode so the amounts use its exact rounding
+func findRoute(tokenIn string, tokenOut string, amountIn *big.Int) (*swapRoute, error) {
+	res, err := pkg.ChaincodeQuery("Exchange:ListPairs")
+	if err != nil {
+		return nil, err
+	}
+	var pairs []*pairReserves
+	if err := json.Unmarshal([]byte(res), &pairs); err != nil {
+		return nil, fmt.Errorf("failed to parse pairs: %w", err)
+	}
+	graph := make(map[string][]*pairReserves)
+	now := time.Now().Unix()
+	for _, pair := range pairs {
+		if pair.Paused || pair.HaltedUntil > now || pair.BatchInterval > 0 {
+			continue
+		}
+		graph[pair.Token0] = append(graph[pair.Token0], pair)
+		graph[pair.Token1] = append(graph[pair.Token1], pair)
+	}
+
+	var bestPath []string
+	var bestOut *big.Int
+	visited := map[string]bool{tokenIn: true}
+	var search func(path []string, amount *big.Int)
+	search = func(path []string, amount *big.Int) {
+		token := path[len(path)-1]
+		if token == tokenOut {
+			if bestOut == nil || amount.Cmp(bestOut) > 0 {
+				bestPath = append([]string{}, path...)
+				bestOut = amount
+			}
+			return
+		}
+		if len(path) == maxRouteTokens {
+			return
+		}
+		for _, pair := range graph[token] {
+			next, out := hopAmountOut(pair, token, amount)
+			if visited[next] || out == nil || out.Sign() <= 0 {
+				continue
+			}
+			visited[next] = true
+			search(append(path, next), out)
+			visited[next] = false
+		}
+	}
+	search([]string{tokenIn}, amountIn)
+	if bestPath == nil {
+		return nil, fmt.Errorf("no route from %s to %s", tokenIn, tokenOut)
+	}
+
+	path, err := json.Marshal(bestPath)
+	if err != nil {
+		return nil, err
+	}
+	res, err = pkg.ChaincodeQuery("Exchange:GetAmountsOut", string(path), amountIn.String())
+	if err != nil {
+		return nil, err
+	}
+	route := &swapRoute{Path: bestPath}
+	if err := json.Unmarshal([]byte(res), &route.Amounts); err != nil {
+		return nil, fmt.Errorf("failed to parse path quote: %w", err)
+	}
+	return route, nil
+}
+
+// hopAmountOut returns the other token of the pair and the output of selling amountIn of tokenIn,
+// using the same constant product formula and rounding as the chaincode
+func hopAmountOut(pair *pairReserves, tokenIn string, amountIn *big.Int) (string, *big.Int) {
+	reserveIn, ok1 := new(big.Int).SetString(pair.Reserve0, 10)
+	reserveOut, ok2 := new(big.Int).SetString(pair.Reserve1, 10)
+	next := pair.Token1
+	if tokenIn == pair.Token1 {
+		reserveIn, reserveOut = reserveOut, reserveIn
+		next = pair.Token0
+	}
+	if !ok1 || !ok2 || pair.SwapFeeDenom == 0 || reserveIn.Sign() == 0 || reserveOut.Sign() == 0 {
+		return next, nil
+	}
+
+	fee := new(big.Int).Mul(amountIn, new(big.Int).SetUint64(pair.SwapFeeNum))
+	fee.Div(fee, new(big.Int).SetUint64(pair.SwapFeeDenom))
+	amountAfterFee := new(big.Int).Sub(amountIn, fee)
+	amountOut := new(big.Int).Mul(amountAfterFee, reserveOut)
+	return next, amountOut.Div(amountOut, new(big.Int).Add(reserveIn, amountAfterFee))
+}
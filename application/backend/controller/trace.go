@@ -19,7 +19,7 @@ func Uplink(c *gin.Context) {
 	if args == nil {
 		return
 	}
-	res, err := pkg.ChaincodeInvoke("Uplink", args)
+	res, err := pkg.ChaincodeInvokeAs(currentUser(c), "Uplink", args)
 	if err != nil {
 		c.JSON(200, gin.H{
 			"message": "uplink failed" + err.Error(),
@@ -3,6 +3,10 @@ package controller
 import (
 	"backend/model"
 	"backend/pkg"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -131,3 +135,26 @@ func GetInfo(c *gin.Context) {
 		"username": username,
 	})
 }
+
+// 当前登录用户ID，代用户调用链码时作为 actingUser 传给链码
+func currentUser(c *gin.Context) string {
+	value, _ := c.Get("userID")
+	userID, _ := value.(string)
+	return userID
+}
+
+// 校验当前登录用户是否为链上记录的创建者，否则中止请求
+// 链码按 actingUser 的子账户校验调用者，后端在调用前先按创建记录校验，避免无效交易并给出明确的 403
+func requireRecordOwner(c *gin.Context, recordID string) bool {
+	userID := currentUser(c)
+	owner, err := pkg.GetRecordOwner(recordID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to get record owner: %v", err)})
+		return false
+	}
+	if owner == "" || owner != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the user who created " + recordID + " can access it"})
+		return false
+	}
+	return true
+}
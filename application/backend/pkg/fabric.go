@@ -22,14 +22,25 @@ const (
 	tlsCertPath  = cryptoPath + "/peers/peer0.org1.example.com/tls/ca.crt"
 	peerEndpoint = "127.0.0.1:7051"
 	gatewayPeer  = "peer0.org1.example.com"
+	// 代用户调用时在 transient 中声明的用户ID，链码据此使用该用户绑定的子账户
+	transientActingUser = "actingUser"
 )
 
-// 链码查询
-func ChaincodeQuery(fcn string, arg string) (string, error) {
+// 链码查询，无参数的函数不传 args
+func ChaincodeQuery(fcn string, args ...string) (string, error) {
+	return evaluate(fcn, client.WithArguments(args...))
+}
+
+// 以用户子账户身份查询，用于读取调用者自己的数据
+func ChaincodeQueryAs(userID string, fcn string, args ...string) (string, error) {
+	return evaluate(fcn, client.WithArguments(args...), actingUser(userID, nil))
+}
+
+func evaluate(fcn string, options ...client.ProposalOption) (string, error) {
 	contract, conn, gw := GetContract()
 	defer conn.Close()
 	defer gw.Close()
-	evaluateResult, err := contract.EvaluateTransaction(fcn, arg)
+	evaluateResult, err := contract.Evaluate(fcn, options...)
 	if err != nil {
 		return "", fmt.Errorf("failed to evaluate transaction: %w", err)
 	}
@@ -40,24 +51,64 @@ func ChaincodeQuery(fcn string, arg string) (string, error) {
 
 // 链码调用，返回交易ID
 func ChaincodeInvoke(fcn string, args []string) (string, error) {
+	txID, _, err := ChaincodeSubmit(fcn, args)
+	return txID, err
+}
+
+// 链码调用，返回交易ID和链码函数的返回值
+func ChaincodeSubmit(fcn string, args []string) (string, string, error) {
+	return submit(fcn, client.WithArguments(args...))
+}
+
+// 携带 transient 数据的链码调用，transient 不会写入交易提案的参数与区块，用于传递私有数据
+func ChaincodeSubmitTransient(fcn string, args []string, transient map[string][]byte) (string, string, error) {
+	return submit(fcn, client.WithArguments(args...), client.WithTransient(transient))
+}
+
+// 以用户子账户身份调用，返回交易ID
+func ChaincodeInvokeAs(userID string, fcn string, args []string) (string, error) {
+	txID, _, err := ChaincodeSubmitAs(userID, fcn, args)
+	return txID, err
+}
+
+// 以用户子账户身份调用，返回交易ID和链码函数的返回值
+func ChaincodeSubmitAs(userID string, fcn string, args []string) (string, string, error) {
+	return submit(fcn, client.WithArguments(args...), actingUser(userID, nil))
+}
+
+// 以用户子账户身份携带 transient 数据调用
+func ChaincodeSubmitTransientAs(userID string, fcn string, args []string, transient map[string][]byte) (string, string, error) {
+	return submit(fcn, client.WithArguments(args...), actingUser(userID, transient))
+}
+
+// actingUser 将用户ID加入 transient，不修改调用方传入的 map
+func actingUser(userID string, transient map[string][]byte) client.ProposalOption {
+	merged := map[string][]byte{transientActingUser: []byte(userID)}
+	for key, value := range transient {
+		merged[key] = value
+	}
+	return client.WithTransient(merged)
+}
+
+func submit(fcn string, options ...client.ProposalOption) (string, string, error) {
 	contract, conn, gw := GetContract()
 	defer conn.Close()
 	defer gw.Close()
-	submitResult, commit, err := contract.SubmitAsync(fcn, client.WithArguments(args...))
+	submitResult, commit, err := contract.SubmitAsync(fcn, options...)
 	if err != nil {
-		return "", fmt.Errorf("failed to submit transaction asynchronously: %w", err)
+		return "", "", fmt.Errorf("failed to submit transaction asynchronously: %w", err)
 	}
 	fmt.Printf("*** Successfully submitted transaction :%v", string(submitResult))
 	fmt.Println("*** Waiting for transaction commit.")
 
 	if commitStatus, err := commit.Status(); err != nil {
-		return "", fmt.Errorf("failed to get commit status: %w", err)
+		return "", "", fmt.Errorf("failed to get commit status: %w", err)
 	} else if !commitStatus.Successful {
-		return "", fmt.Errorf("transaction %s failed to commit with status: %d", commitStatus.TransactionID, int32(commitStatus.Code))
+		return "", "", fmt.Errorf("transaction %s failed to commit with status: %d", commitStatus.TransactionID, int32(commitStatus.Code))
 	}
 
 	fmt.Printf("*** Transaction committed successfully\n")
-	return commit.TransactionID(), nil
+	return commit.TransactionID(), string(submitResult), nil
 
 }
 
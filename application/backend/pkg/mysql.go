@@ -30,6 +30,11 @@ func MysqlInit() (err error) {
 	if err != nil {
 		panic(err.Error())
 	}
+	// 记录链上记录由哪个后端用户创建，后端在代用户撤单、改报告前先行校验
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS record_owners (record_id VARCHAR(100) PRIMARY KEY, user_id VARCHAR(50) NOT NULL)")
+	if err != nil {
+		panic(err.Error())
+	}
 	// 重新配置下数据库连接信息
 	dsn = viper.GetString("mysql.user") + ":" + viper.GetString("mysql.password") + "@tcp(" + viper.GetString("mysql.host") + ":" + viper.GetString("mysql.port") + ")/" + viper.GetString("mysql.db")
 	db, err = sql.Open("mysql", dsn)
@@ -96,3 +101,23 @@ func GetUsername(userID string) (username string, err error) {
 	}
 	return username, nil
 }
+
+// 记录链上记录的创建者
+func InsertRecordOwner(recordID string, userID string) (err error) {
+	sqlStr := "insert into record_owners(record_id,user_id) values(?,?)"
+	_, err = db.Exec(sqlStr, recordID, userID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// 获取链上记录的创建者
+func GetRecordOwner(recordID string) (userID string, err error) {
+	sqlStr := "select user_id from record_owners where record_id = ?"
+	err = db.QueryRow(sqlStr, recordID).Scan(&userID)
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
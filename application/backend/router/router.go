@@ -52,16 +52,126 @@ func SetupRouter() *gin.Engine {
 	r.POST("/getAllFruitInfo", middleware.JWTAuthMiddleware(), con.GetAllFruitInfo)
 	// 获取农产品上链历史(溯源)
 	r.POST("/getFruitHistory", middleware.JWTAuthMiddleware(), con.GetFruitHistory)
+	// 查询所有交易对
+	r.GET("/pairs", con.ListPairs)
+	// 查询交易对
+	r.GET("/pairs/:pairId", con.GetPair)
+	// 分页查询交易对的流动性提供者
+	r.GET("/pairs/:pairId/providers", con.ListLiquidityProviders)
+	// 查询交易对的时间加权平均价格
+	r.GET("/pairs/:pairId/twap", con.GetTWAP)
+	// 查询交易对是否暂停或熔断
+	r.GET("/pairs/:pairId/status", con.GetTradingStatus)
+	// 查询已结算批次的清算结果
+	r.GET("/pairs/:pairId/batches/:batchId", con.GetBatchResult)
+	// 查询账户持有的份额代币
+	r.GET("/pairs/:pairId/lp-balance", con.GetLPBalance)
 	// 添加流动性
 	r.POST("/liquidity/add", middleware.JWTAuthMiddleware(), con.AddLiquidity)
 	// 移除流动性
 	r.POST("/liquidity/remove", middleware.JWTAuthMiddleware(), con.RemoveLiquidity)
 	// 移除所有流动性
 	r.POST("/liquidity/remove-all", middleware.JWTAuthMiddleware(), con.RemoveAllLiquidity)
+	// 转让份额代币
+	r.POST("/liquidity/transfer", middleware.JWTAuthMiddleware(), con.TransferLiquidity)
+	// 领取流动性手续费
+	r.POST("/liquidity/claim-fees", middleware.JWTAuthMiddleware(), con.ClaimFees)
+	// 在指定交易对中兑换
+	r.POST("/swap", middleware.JWTAuthMiddleware(), con.Swap)
 	// 代币换ETH
 	r.POST("/swap/tokens-for-eth", middleware.JWTAuthMiddleware(), con.SwapTokensForETH)
 	// ETH换代币
 	r.POST("/swap/eth-for-tokens", middleware.JWTAuthMiddleware(), con.SwapETHForTokens)
+	// 查询多跳兑换的最优路径与报价
+	r.GET("/swap/route", con.GetSwapRoute)
+	// 沿最优路径多跳兑换
+	r.POST("/swap/route", middleware.JWTAuthMiddleware(), con.SwapAlongRoute)
+	// 查询熔断参数
+	r.GET("/circuit-breaker", con.GetCircuitBreaker)
+	// 查询账户的兑换意向
+	r.GET("/swap/intents", con.GetAccountSwapIntents)
+	// 查询兑换意向
+	r.GET("/swap/intents/:intentId", con.GetSwapIntent)
+	// 提交批量拍卖兑换意向
+	r.POST("/swap/intents", middleware.JWTAuthMiddleware(), con.SubmitSwapIntent)
+	// 撤销兑换意向
+	r.POST("/swap/intents/cancel", middleware.JWTAuthMiddleware(), con.CancelSwapIntent)
+	// 结算已截止的批次
+	r.POST("/swap/batches/settle", middleware.JWTAuthMiddleware(), con.SettleBatch)
+	// 按输入数量报价
+	r.GET("/quote/exact-in", con.QuoteExactIn)
+	// 按输出数量报价
+	r.GET("/quote/exact-out", con.QuoteExactOut)
+	// 查询现价
+	r.GET("/quote/spot-price", con.GetSpotPrice)
+	// 查询价格影响
+	r.GET("/quote/price-impact", con.GetPriceImpact)
+	// 查询订单簿深度
+	r.GET("/orderbook", con.GetOrderBook)
+	// 查询账户的订单
+	r.GET("/orders", con.GetAccountOrders)
+	// 查询账户的成交记录
+	r.GET("/orders/fills", con.GetAccountFills)
+	// 查询订单
+	r.GET("/orders/:orderId", con.GetOrder)
+	// 下限价单
+	r.POST("/orders", middleware.JWTAuthMiddleware(), con.PlaceOrder)
+	// 下市价单，可同时路由到流动性池
+	r.POST("/orders/market", middleware.JWTAuthMiddleware(), con.MarketOrder)
+	// 撤销订单
+	r.POST("/orders/cancel", middleware.JWTAuthMiddleware(), con.CancelOrder)
+	// 查询账户发出或收到的场外报价
+	r.GET("/otc/offers", con.GetAccountOffers)
+	// 查询场外报价
+	r.GET("/otc/offers/:offerId", con.GetOffer)
+	// 查询场外报价的私有成交条款，仅限发出报价的用户
+	r.GET("/otc/offers/:offerId/terms", middleware.JWTAuthMiddleware(), con.GetOfferTerms)
+	// 向后端以外的指定对手方账户发出场外报价，对手方以自己的身份接受报价
+	r.POST("/otc/offers", middleware.JWTAuthMiddleware(), con.CreateOffer)
+	// 撤销自己发出的场外报价
+	r.POST("/otc/offers/cancel", middleware.JWTAuthMiddleware(), con.CancelOffer)
+	// 按受益方、报告年度或账户查询注销证书
+	r.GET("/retirements", con.GetRetirements)
+	// 查询累计注销量
+	r.GET("/retirements/supply", con.GetRetiredSupply)
+	// 查询注销证书
+	r.GET("/retirements/:certificateId", con.GetRetirementCertificate)
+	// 注销碳币并生成注销证书
+	r.POST("/retirements", middleware.JWTAuthMiddleware(), con.Retire)
+	// 查询账户持有的各批次碳信用
+	r.GET("/lots", con.GetAccountLots)
+	// 查询批次属性与签发、注销数量
+	r.GET("/lots/:lotId", con.GetLot)
+	// 查询账户持有的批次序列号区间
+	r.GET("/lots/:lotId/serials", con.GetAccountSerials)
+	// 查询序列号的持有人与注销状态
+	r.GET("/serials/:serial", con.GetSerialStatus)
+	// 查询项目的全部批次
+	r.GET("/projects/:projectId/lots", con.GetProjectLots)
+	// 转让指定批次的碳信用
+	r.POST("/lots/transfer", middleware.JWTAuthMiddleware(), con.TransferLot)
+	// 查询批次篮子的准入规则与托管批次
+	r.GET("/baskets/:symbol", con.GetBasket)
+	// 将合格批次存入篮子换取篮子代币
+	r.POST("/baskets/deposit", middleware.JWTAuthMiddleware(), con.DepositLot)
+	// 销毁篮子代币赎回指定批次
+	r.POST("/baskets/redeem", middleware.JWTAuthMiddleware(), con.RedeemLot)
+	// 查询履约期及各企业的履约情况
+	r.GET("/compliance/periods/:periodId", con.GetCompliancePeriod)
+	// 查询企业的履约义务
+	r.GET("/compliance/obligations", con.GetObligations)
+	// 清缴碳币抵扣履约义务
+	r.POST("/compliance/surrender", middleware.JWTAuthMiddleware(), con.Surrender)
+	// 查询账户的排放报告
+	r.GET("/mrv/reports", con.GetAccountReports)
+	// 查询排放报告
+	r.GET("/mrv/reports/:reportId", con.GetReport)
+	// 查询排放报告的私有明细，仅限提交报告的用户
+	r.GET("/mrv/reports/:reportId/data", middleware.JWTAuthMiddleware(), con.GetReportData)
+	// 查询排放报告的修改与审批历史
+	r.GET("/mrv/reports/:reportId/history", con.GetReportHistory)
+	// 提交或修改排放报告
+	r.POST("/mrv/reports", middleware.JWTAuthMiddleware(), con.SubmitReport)
 	return r
 }
 
@@ -1,82 +1,70 @@
 package chaincode
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// 代幣元數據
+const (
+	cctName     = "CarbonCoinToken"
+	cctSymbol   = "CCT"
+	cctDecimals = 0 // 1 CCT 對應 1 噸 CO2e，不可再分
+)
+
 // CarbonCoinToken 定義智能合約結構
 type CarbonCoinToken struct {
 	contractapi.Contract
 }
 
-// Token 定義代幣結構
-type Token struct {
-	Owner   string `json:"owner"`
-	Balance uint64 `json:"balance"`
-}
-
 // 初始化合約
 func (c *CarbonCoinToken) InitLedger(ctx contractapi.TransactionContextInterface) error {
 	return nil
 }
 
-// Mint 鑄造新代幣
-func (c *CarbonCoinToken) Mint(ctx contractapi.TransactionContextInterface, owner string, amount uint64) error {
-	// 獲取當前狀態
-	tokenBytes, err := ctx.GetStub().GetState(owner)
-	if err != nil {
-		return fmt.Errorf("failed to read from world state: %v", err)
-	}
-
-	var token Token
-	if tokenBytes == nil {
-		// 如果用戶還沒有代幣，初始化
-		token = Token{
-			Owner:   owner,
-			Balance: 0,
-		}
-	} else {
-		err = json.Unmarshal(tokenBytes, &token)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal token: %v", err)
-		}
-	}
+// Name 返回代幣名稱
+func (c *CarbonCoinToken) Name(ctx contractapi.TransactionContextInterface) (string, error) {
+	return cctName, nil
+}
 
-	// 增加代幣餘額
-	token.Balance += amount
+// Symbol 返回代幣符號
+func (c *CarbonCoinToken) Symbol(ctx contractapi.TransactionContextInterface) (string, error) {
+	return cctSymbol, nil
+}
 
-	// 更新狀態
-	tokenBytes, err = json.Marshal(token)
-	if err != nil {
-		return fmt.Errorf("failed to marshal token: %v", err)
-	}
-	err = ctx.GetStub().PutState(owner, tokenBytes)
-	if err != nil {
-		return fmt.Errorf("failed to update state: %v", err)
-	}
+// Decimals 返回代幣精度
+func (c *CarbonCoinToken) Decimals(ctx contractapi.TransactionContextInterface) (uint8, error) {
+	return cctDecimals, nil
+}
 
-	return nil
+// TotalSupply 查詢代幣總供應量
+func (c *CarbonCoinToken) TotalSupply(ctx contractapi.TransactionContextInterface) (uint64, error) {
+	return readTotalSupply(ctx, cctSymbol)
 }
 
-// GetBalance 查詢餘額
+// GetBalance 查詢餘額，owner 可以是賬戶ID或後端用戶ID
 func (c *CarbonCoinToken) GetBalance(ctx contractapi.TransactionContextInterface, owner string) (uint64, error) {
-	tokenBytes, err := ctx.GetStub().GetState(owner)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read from world state: %v", err)
-	}
-	if tokenBytes == nil {
-		return 0, nil // 如果沒有記錄，返回0
-	}
+	return queryBalance(ctx, cctSymbol, owner)
+}
 
-	var token Token
-	err = json.Unmarshal(tokenBytes, &token)
-	if err != nil {
-		return 0, fmt.Errorf("failed to unmarshal token: %v", err)
-	}
+// Transfer 從調用者賬戶轉賬給 to
+func (c *CarbonCoinToken) Transfer(ctx contractapi.TransactionContextInterface, to string, amount uint64) error {
+	return callerTransfer(ctx, cctSymbol, to, amount)
+}
+
+// Approve 授權 spender 代表調用者轉出最多 amount 個代幣，覆蓋原有額度
+func (c *CarbonCoinToken) Approve(ctx contractapi.TransactionContextInterface, spender string, amount uint64) error {
+	return callerApprove(ctx, cctSymbol, spender, amount)
+}
 
-	return token.Balance, nil
+// Allowance 查詢 owner 授權給 spender 的剩餘額度
+func (c *CarbonCoinToken) Allowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (uint64, error) {
+	return queryAllowance(ctx, cctSymbol, owner, spender)
+}
+
+// TransferFrom 調用者使用 from 的授權額度轉賬給 to
+func (c *CarbonCoinToken) TransferFrom(ctx contractapi.TransactionContextInterface, from string, to string, amount uint64) error {
+	return callerTransferFrom(ctx, cctSymbol, from, to, amount)
 }
 
 func main() {
@@ -89,4 +77,4 @@ func main() {
 	if err := chaincode.Start(); err != nil {
 		fmt.Printf("Error starting CarbonCoinToken chaincode: %v", err)
 	}
-}
\ No newline at end of file
+}
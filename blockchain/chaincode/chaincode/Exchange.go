@@ -8,386 +8,861 @@ import (
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// 世界状态命名空间，每个交易对的池子存放在 pool~交易对 组合键下
+const (
+	poolPrefix    = "pool"
+	defaultPairID = "CCT-STABLE"
+)
+
+const (
+	// 首次注入流动性时永久锁定在池子账户下的份额，防止总份额被清零后操纵份额价格
+	minimumLiquidity = 1000
+	// 分页查询每页最多返回的记录数
+	maxPageSize = 100
+	// 份额占比保留的小数位数
+	percentDecimals = 6
+)
+
 // Exchange 定义交易所智能合约结构
+// 每个交易对一个池子，两侧代币按符号排序为 Token0 与 Token1，资产托管在池子账户下
 type Exchange struct {
 	contractapi.Contract
 }
 
-// Pool 定义流动性池结构
+// Pool 定义流动性池结构，只保存储备与累计值，LP 持仓另存，避免每笔交易改写所有 LP 的数据
 type Pool struct {
-	ETHReserve         *big.Int            `json:"ethReserve"`         // ETH 储备量
-	TokenReserve       *big.Int            `json:"tokenReserve"`       // 代币储备量
-	ETHFeeReserve      *big.Int            `json:"ethFeeReserve"`      // ETH 费用池
-	TokenFeeReserve    *big.Int            `json:"tokenFeeReserve"`    // 代币费用池
-	SwapFeeNum         uint64              `json:"swapFeeNum"`         // 交易费分子
-	SwapFeeDenom       uint64              `json:"swapFeeDenom"`       // 交易费分母
-	LiquidityProviders []string            `json:"liquidityProviders"` // 流动性提供者列表
-	TotalShares        *big.Int            `json:"totalShares"`        // 总份额
-	LPShares           map[string]*big.Int `json:"lpShares"`           // 每个 LP 的份额
+	PairID       string   `json:"pairId"`       // 交易对ID，Token0-Token1
+	Token0       string   `json:"token0"`       // 符号较小的代币
+	Token1       string   `json:"token1"`       // 符号较大的代币
+	Reserve0     *big.Int `json:"reserve0"`     // Token0 储备量
+	Reserve1     *big.Int `json:"reserve1"`     // Token1 储备量
+	FeeReserve0  *big.Int `json:"feeReserve0"`  // Token0 费用池，LP 尚未领取的手续费
+	FeeReserve1  *big.Int `json:"feeReserve1"`  // Token1 费用池，LP 尚未领取的手续费
+	FeeGrowth0   *big.Int `json:"feeGrowth0"`   // 每份额累计的 Token0 手续费，按 2^128 放大
+	FeeGrowth1   *big.Int `json:"feeGrowth1"`   // 每份额累计的 Token1 手续费，按 2^128 放大
+	ProtocolFee0 *big.Int `json:"protocolFee0"` // 待归集到国库的 Token0 协议费
+	ProtocolFee1 *big.Int `json:"protocolFee1"` // 待归集到国库的 Token1 协议费
+	SwapFeeNum   uint64   `json:"swapFeeNum"`   // 交易费分子
+	SwapFeeDenom uint64   `json:"swapFeeDenom"` // 交易费分母
+	TotalShares  *big.Int `json:"totalShares"`  // 总份额，各 LP 的份额见 Position
+
+	PriceCumulative0 *big.Int `json:"priceCumulative0"` // Token0 以 Token1 计价的累计价格，按 2^112 放大后按秒累加
+	PriceCumulative1 *big.Int `json:"priceCumulative1"` // Token1 以 Token0 计价的累计价格
+	LastObserved     int64    `json:"lastObserved"`     // 上次累加价格的交易时间（Unix 秒）
+	ObservationIndex uint32   `json:"observationIndex"` // 最新观测在环形缓冲区中的位置
+	ObservationCount uint32   `json:"observationCount"` // 已保存的观测数量，不超过 maxObservations
+
+	Paused      bool  `json:"paused"`      // 监管机构暂停交易
+	HaltedUntil int64 `json:"haltedUntil"` // 熔断冷却结束时间（Unix 秒），之前拒绝兑换
+
+	BatchInterval int64 `json:"batchInterval"` // 批量拍卖周期（秒），0 表示连续兑换
+
+	observation *Observation // 本次交易生成、尚未写入的价格观测
 }
 
 // Liquidity 定义流动性提供者的记录
 type Liquidity struct {
-	Owner       string   `json:"owner"`
-	ETHAmount   *big.Int `json:"ethAmount"`
-	TokenAmount *big.Int `json:"tokenAmount"`
+	PairID  string `json:"pairId"`
+	Owner   string `json:"owner"`
+	Shares  string `json:"shares"`
+	Amount0 string `json:"amount0"`
+	Amount1 string `json:"amount1"`
+	Fees0   string `json:"fees0"` // 可领取的 Token0 手续费
+	Fees1   string `json:"fees1"` // 可领取的 Token1 手续费
+}
+
+// LiquidityProvider 定义流动性提供者查询结果，Percent 为占总份额的百分比
+type LiquidityProvider struct {
+	Account string `json:"account"`
+	Shares  string `json:"shares"`
+	Percent string `json:"percent"`
+}
+
+// LiquidityProviderPage 定义流动性提供者分页查询结果，FetchedRecordsCount 为本页读取的持仓记录数
+type LiquidityProviderPage struct {
+	Providers           []*LiquidityProvider `json:"providers"`
+	FetchedRecordsCount int32                `json:"fetchedRecordsCount"`
+	Bookmark            string               `json:"bookmark"`
+}
+
+// Reserves 定义储备量查询结果
+type Reserves struct {
+	PairID      string `json:"pairId"`
+	Token0      string `json:"token0"`
+	Token1      string `json:"token1"`
+	Reserve0    string `json:"reserve0"`
+	Reserve1    string `json:"reserve1"`
+	TotalShares string `json:"totalShares"`
+}
+
+// SwapFee 定义交易费率查询结果
+type SwapFee struct {
+	SwapFeeNum   uint64 `json:"swapFeeNum"`
+	SwapFeeDenom uint64 `json:"swapFeeDenom"`
 }
 
-// Init 初始化合约（构造函数）
+// LiquidityResult 定义添加流动性结果，Amount0/Amount1 为实际存入数量，超出比例的部分不会扣款
+type LiquidityResult struct {
+	TxID    string `json:"txId"`
+	PairID  string `json:"pairId"`
+	Amount0 string `json:"amount0"`
+	Amount1 string `json:"amount1"`
+	Shares  string `json:"shares"`
+}
+
+// SwapResult 定义兑换结果
+type SwapResult struct {
+	TxID        string `json:"txId"`
+	AmountIn    string `json:"amountIn"`
+	AmountOut   string `json:"amountOut"`
+	HaltedUntil int64  `json:"haltedUntil,omitempty" metadata:",optional"` // 非0表示兑换触发熔断未执行，交易对暂停兑换到该时间
+}
+
+// SwapEvent 兑换事件
+type SwapEvent struct {
+	PairID    string `json:"pairId"`
+	Trader    string `json:"trader"`
+	TokenIn   string `json:"tokenIn"`
+	AmountIn  string `json:"amountIn"`
+	TokenOut  string `json:"tokenOut"`
+	AmountOut string `json:"amountOut"`
+}
+
+// LiquidityEvent 添加/移除流动性事件
+type LiquidityEvent struct {
+	PairID   string `json:"pairId"`
+	Provider string `json:"provider"`
+	Amount0  string `json:"amount0"`
+	Amount1  string `json:"amount1"`
+	Shares   string `json:"shares"`
+	Fees0    string `json:"fees0,omitempty"` // 移除流动性时一并领取的手续费
+	Fees1    string `json:"fees1,omitempty"`
+}
+
+// poolSide 指向池子某一侧的代币与储备，修改储备即修改池子本身
+type poolSide struct {
+	token       string
+	reserve     *big.Int
+	feeReserve  *big.Int
+	feeGrowth   *big.Int
+	protocolFee *big.Int
+}
+
+// Init 初始化合约，默认交易对 CCT-STABLE 不存在时创建，已存在时不做修改
 func (e *Exchange) Init(ctx contractapi.TransactionContextInterface) error {
-	pool := Pool{
-		ETHReserve:         big.NewInt(0),
-		TokenReserve:       big.NewInt(0),
-		ETHFeeReserve:      big.NewInt(0),
-		TokenFeeReserve:    big.NewInt(0),
-		SwapFeeNum:         3,    // 默认交易费率 0.3%（3/1000）
-		SwapFeeDenom:       1000,
-		LiquidityProviders: []string{},
-		TotalShares:        big.NewInt(0),
-		LPShares:           make(map[string]*big.Int),
+	exists, err := poolExists(ctx, defaultPairID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
 	}
 
-	poolBytes, err := json.Marshal(pool)
+	// 默认交易费率 0.3%（3/1000）
+	_, err = e.CreatePair(ctx, cctSymbol, stableSymbol, 3, 1000)
+	return err
+}
+
+// RemoveLP 移除流动性提供者的全部份额，并将对应的储备与手续费返还给该提供者
+// 只能由提供者本人或监管机构（紧急处置）调用
+func (e *Exchange) RemoveLP(ctx contractapi.TransactionContextInterface, pairID string, account string) (string, error) {
+	caller, err := getCallerAccount(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal pool: %v", err)
+		return "", err
+	}
+	owner, err := resolveAccount(ctx, account)
+	if err != nil {
+		return "", err
+	}
+	if owner != caller {
+		err = requireRegulator(ctx)
+		if err != nil {
+			return "", fmt.Errorf("caller is not the liquidity provider or a regulator")
+		}
 	}
 
-	err = ctx.GetStub().PutState("pool", poolBytes)
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return "", err
+	}
+	position, err := getPosition(ctx, pool.PairID, owner)
 	if err != nil {
-		return fmt.Errorf("failed to initialize pool: %v", err)
+		return "", err
+	}
+	if position.Shares.Sign() == 0 || owner == poolAccount(pool.PairID) {
+		return "", fmt.Errorf("%s is not a liquidity provider of %s", account, pairID)
 	}
 
-	return nil
+	return removeLiquidity(ctx, pool, position, new(big.Int).Set(position.Shares), big.NewInt(0), big.NewInt(0))
 }
 
-// CreatePool 初始化流动性池
-func (e *Exchange) CreatePool(ctx contractapi.TransactionContextInterface, amountTokens string) error {
-	amount, ok := new(big.Int).SetString(amountTokens, 10)
-	if !ok || amount.Cmp(big.NewInt(0)) <= 0 {
-		return fmt.Errorf("invalid amountTokens")
-	}
-
-	poolBytes, err := ctx.GetStub().GetState("pool")
+// GetSwapFee 查询交易费率
+func (e *Exchange) GetSwapFee(ctx contractapi.TransactionContextInterface, pairID string) (*SwapFee, error) {
+	pool, err := getPool(ctx, pairID)
 	if err != nil {
-		return fmt.Errorf("failed to read pool: %v", err)
+		return nil, err
 	}
 
-	var pool Pool
-	err = json.Unmarshal(poolBytes, &pool)
+	return &SwapFee{SwapFeeNum: pool.SwapFeeNum, SwapFeeDenom: pool.SwapFeeDenom}, nil
+}
+
+// GetReserves 查询储备量
+func (e *Exchange) GetReserves(ctx contractapi.TransactionContextInterface, pairID string) (*Reserves, error) {
+	pool, err := getPool(ctx, pairID)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal pool: %v", err)
+		return nil, err
 	}
 
-	// 检查是否已经初始化
-	if pool.ETHReserve.Cmp(big.NewInt(0)) > 0 || pool.TokenReserve.Cmp(big.NewInt(0)) > 0 {
-		return fmt.Errorf("pool already initialized")
-	}
+	return &Reserves{
+		PairID:      pool.PairID,
+		Token0:      pool.Token0,
+		Token1:      pool.Token1,
+		Reserve0:    pool.Reserve0.String(),
+		Reserve1:    pool.Reserve1.String(),
+		TotalShares: pool.TotalShares.String(),
+	}, nil
+}
 
-	creatorBytes, err := ctx.GetStub().GetCreator()
+// GetMyLiquidity 查询调用者的份额及按当前储备可取回的资产数量
+func (e *Exchange) GetMyLiquidity(ctx contractapi.TransactionContextInterface, pairID string) (*Liquidity, error) {
+	pool, err := getPool(ctx, pairID)
 	if err != nil {
-		return fmt.Errorf("failed to get creator: %v", err)
+		return nil, err
 	}
-	owner := string(creatorBytes)
-	pool.TokenReserve = amount
-	pool.LiquidityProviders = append(pool.LiquidityProviders, owner)
-	pool.LPShares[owner] = new(big.Int).Set(amount) // 初始份额等于代币数量
-	pool.TotalShares = new(big.Int).Set(amount)
 
-	poolBytes, err = json.Marshal(pool)
+	owner, err := getCallerAccount(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal pool: %v", err)
+		return nil, err
 	}
-
-	err = ctx.GetStub().PutState("pool", poolBytes)
+	position, err := getPosition(ctx, pool.PairID, owner)
 	if err != nil {
-		return fmt.Errorf("failed to update pool: %v", err)
+		return nil, err
+	}
+	fee0, fee1 := pendingFees(pool, position)
+	liquidity := Liquidity{PairID: pool.PairID, Owner: owner, Shares: "0", Amount0: "0", Amount1: "0", Fees0: fee0.String(), Fees1: fee1.String()}
+	lpShare := position.Shares
+	if lpShare.Sign() == 0 || pool.TotalShares.Sign() == 0 {
+		return &liquidity, nil
 	}
 
-	return nil
+	amount0 := new(big.Int).Mul(lpShare, pool.Reserve0)
+	amount0.Div(amount0, pool.TotalShares)
+	amount1 := new(big.Int).Mul(lpShare, pool.Reserve1)
+	amount1.Div(amount1, pool.TotalShares)
+	liquidity.Shares = lpShare.String()
+	liquidity.Amount0 = amount0.String()
+	liquidity.Amount1 = amount1.String()
+
+	return &liquidity, nil
 }
 
-// RemoveLP 移除流动性提供者
-func (e *Exchange) RemoveLP(ctx contractapi.TransactionContextInterface, index uint64) error {
-	poolBytes, err := ctx.GetStub().GetState("pool")
+// ListLiquidityProviders 分页查询交易对的流动性提供者及其份额占比，按账户ID排序
+// bookmark 为上一页返回的书签，首页传空字符串；本页读取的记录数少于 pageSize 时没有下一页
+func (e *Exchange) ListLiquidityProviders(ctx contractapi.TransactionContextInterface, pairID string, pageSize int32, bookmark string) (*LiquidityProviderPage, error) {
+	if pageSize <= 0 || pageSize > maxPageSize {
+		return nil, fmt.Errorf("page size must be between 1 and %d", maxPageSize)
+	}
+	pool, err := getPool(ctx, pairID)
 	if err != nil {
-		return fmt.Errorf("failed to read pool: %v", err)
+		return nil, err
 	}
 
-	var pool Pool
-	err = json.Unmarshal(poolBytes, &pool)
+	iterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(positionPrefix, []string{pool.PairID}, pageSize, bookmark)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal pool: %v", err)
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	// 锁定份额属于池子本身，只剩未领取手续费的持仓也不计入提供者列表
+	lockAccount := poolAccount(pool.PairID)
+	page := &LiquidityProviderPage{
+		Providers:           []*LiquidityProvider{},
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
 	}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var position Position
+		err = json.Unmarshal(queryResponse.Value, &position)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal position: %v", err)
+		}
+		if position.Owner == lockAccount || position.Shares.Sign() == 0 {
+			continue
+		}
 
-	if index >= uint64(len(pool.LiquidityProviders)) {
-		return fmt.Errorf("invalid index")
+		percent := new(big.Rat).SetFrac(new(big.Int).Mul(position.Shares, big.NewInt(100)), pool.TotalShares)
+		page.Providers = append(page.Providers, &LiquidityProvider{
+			Account: position.Owner,
+			Shares:  position.Shares.String(),
+			Percent: percent.FloatString(percentDecimals),
+		})
 	}
 
-	owner := pool.LiquidityProviders[index]
-	pool.LiquidityProviders = append(pool.LiquidityProviders[:index], pool.LiquidityProviders[index+1:]...)
-	delete(pool.LPShares, owner)
+	return page, nil
+}
 
-	poolBytes, err = json.Marshal(pool)
+// AddLiquidity 添加流动性，按当前储备比例存入不超过期望数量的 Token0 与 Token1
+// 首次注入时两侧都必须存入，份额为两侧数量的几何平均数，其中 minimumLiquidity 份永久锁定
+// 之后按较少一侧的比例铸造份额，另一侧超出比例的部分不扣款
+// 实际存入数量低于 amount0Min 或 amount1Min，或交易时间晚于 deadline 时交易失败
+func (e *Exchange) AddLiquidity(ctx contractapi.TransactionContextInterface, pairID string, amount0Desired string, amount1Desired string, amount0Min string, amount1Min string, deadline int64) (*LiquidityResult, error) {
+	err := checkDeadline(ctx, deadline)
 	if err != nil {
-		return fmt.Errorf("failed to marshal pool: %v", err)
+		return nil, err
+	}
+	amount0, err := parsePositiveAmount(amount0Desired, "amount0Desired")
+	if err != nil {
+		return nil, err
+	}
+	amount1, err := parsePositiveAmount(amount1Desired, "amount1Desired")
+	if err != nil {
+		return nil, err
+	}
+	min0, err := parseAmount(amount0Min, "amount0Min")
+	if err != nil {
+		return nil, err
+	}
+	min1, err := parseAmount(amount1Min, "amount1Min")
+	if err != nil {
+		return nil, err
 	}
 
-	err = ctx.GetStub().PutState("pool", poolBytes)
+	pool, err := getPool(ctx, pairID)
 	if err != nil {
-		return fmt.Errorf("failed to update pool: %v", err)
+		return nil, err
+	}
+	err = requirePoolOpen(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	err = observe(ctx, pool)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
-}
+	owner, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 初始情况下按期望数量存入，否则按储备比例取两侧中较小的组合
+	if pool.TotalShares.Sign() > 0 {
+		if pool.Reserve0.Sign() == 0 || pool.Reserve1.Sign() == 0 {
+			return nil, fmt.Errorf("pool has no reserves")
+		}
+		amount1Optimal := new(big.Int).Mul(amount0, pool.Reserve1)
+		amount1Optimal.Div(amount1Optimal, pool.Reserve0)
+		if amount1Optimal.Cmp(amount1) <= 0 {
+			amount1 = amount1Optimal
+		} else {
+			amount0Optimal := new(big.Int).Mul(amount1, pool.Reserve0)
+			amount0Optimal.Div(amount0Optimal, pool.Reserve1)
+			amount0 = amount0Optimal
+		}
+	}
+	if amount0.Cmp(min0) < 0 {
+		return nil, fmt.Errorf("insufficient %s amount: %s < %s", pool.Token0, amount0.String(), min0.String())
+	}
+	if amount1.Cmp(min1) < 0 {
+		return nil, fmt.Errorf("insufficient %s amount: %s < %s", pool.Token1, amount1.String(), min1.String())
+	}
 
-// GetSwapFee 查询交易费率
-func (e *Exchange) GetSwapFee(ctx contractapi.TransactionContextInterface) (uint64, uint64, error) {
-	poolBytes, err := ctx.GetStub().GetState("pool")
+	shares, locked, err := mintShares(pool, amount0, amount1)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to read pool: %v", err)
+		return nil, err
 	}
 
-	var pool Pool
-	err = json.Unmarshal(poolBytes, &pool)
+	// 从提供者账户扣款，余额不足时整笔交易失败
+	err = pullFromTrader(ctx, pool, pool.Token0, owner, amount0)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to unmarshal pool: %v", err)
+		return nil, err
+	}
+	err = pullFromTrader(ctx, pool, pool.Token1, owner, amount1)
+	if err != nil {
+		return nil, err
 	}
 
-	return pool.SwapFeeNum, pool.SwapFeeDenom, nil
-}
+	// 份额变动前结算手续费，新份额只参与之后的手续费分配
+	position, err := getPosition(ctx, pool.PairID, owner)
+	if err != nil {
+		return nil, err
+	}
+	settleFees(pool, position)
+	position.Shares.Add(position.Shares, shares)
+	pool.Reserve0.Add(pool.Reserve0, amount0)
+	pool.Reserve1.Add(pool.Reserve1, amount1)
+	pool.TotalShares.Add(pool.TotalShares, shares)
+	if locked.Sign() > 0 {
+		// 锁定份额记在池子托管账户下，没有任何身份可以取回
+		lockPosition := newPosition(pool.PairID, poolAccount(pool.PairID))
+		settleFees(pool, lockPosition)
+		lockPosition.Shares = locked
+		pool.TotalShares.Add(pool.TotalShares, locked)
+		err = putPosition(ctx, lockPosition)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-// GetReserves 查询储备量
-func (e *Exchange) GetReserves(ctx contractapi.TransactionContextInterface) (string, string, error) {
-	poolBytes, err := ctx.GetStub().GetState("pool")
+	err = putPosition(ctx, position)
+	if err != nil {
+		return nil, err
+	}
+	err = putPool(ctx, pool)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to read pool: %v", err)
+		return nil, err
 	}
 
-	var pool Pool
-	err = json.Unmarshal(poolBytes, &pool)
+	err = emitEvent(ctx, "AddLiquidity", LiquidityEvent{
+		PairID:   pool.PairID,
+		Provider: owner,
+		Amount0:  amount0.String(),
+		Amount1:  amount1.String(),
+		Shares:   shares.String(),
+	})
 	if err != nil {
-		return "", "", fmt.Errorf("failed to unmarshal pool: %v", err)
+		return nil, err
 	}
 
-	return pool.ETHReserve.String(), pool.TokenReserve.String(), nil
+	return &LiquidityResult{
+		TxID:    ctx.GetStub().GetTxID(),
+		PairID:  pool.PairID,
+		Amount0: amount0.String(),
+		Amount1: amount1.String(),
+		Shares:  shares.String(),
+	}, nil
 }
 
-// AddLiquidity 添加流动性
-func (e *Exchange) AddLiquidity(ctx contractapi.TransactionContextInterface, ethAmount string) (string, error) {
-	eth, ok := new(big.Int).SetString(ethAmount, 10)
-	if !ok || eth.Cmp(big.NewInt(0)) <= 0 {
-		return "", fmt.Errorf("invalid ethAmount")
+// mintShares 计算存入 amount0/amount1 应铸造给提供者的份额，以及首次注入时锁定的份额
+func mintShares(pool *Pool, amount0 *big.Int, amount1 *big.Int) (*big.Int, *big.Int, error) {
+	locked := big.NewInt(0)
+	var shares *big.Int
+	if pool.TotalShares.Sign() == 0 {
+		shares = new(big.Int).Sqrt(new(big.Int).Mul(amount0, amount1))
+		locked.SetInt64(minimumLiquidity)
+		if shares.Cmp(locked) <= 0 {
+			return nil, nil, fmt.Errorf("insufficient initial liquidity: sqrt(amount0 * amount1) must exceed %d", minimumLiquidity)
+		}
+		shares.Sub(shares, locked)
+	} else {
+		shares0 := new(big.Int).Mul(amount0, pool.TotalShares)
+		shares0.Div(shares0, pool.Reserve0)
+		shares1 := new(big.Int).Mul(amount1, pool.TotalShares)
+		shares1.Div(shares1, pool.Reserve1)
+		shares = shares0
+		if shares1.Cmp(shares0) < 0 {
+			shares = shares1
+		}
+	}
+	if shares.Sign() == 0 {
+		return nil, nil, fmt.Errorf("insufficient liquidity minted")
+	}
+	return shares, locked, nil
+}
+
+// RemoveLiquidity 移除部分流动性，销毁 shares 个份额代币并按比例返还 Token0 与 Token1
+// 返还数量低于 amount0Min 或 amount1Min，或交易时间晚于 deadline 时交易失败
+func (e *Exchange) RemoveLiquidity(ctx contractapi.TransactionContextInterface, pairID string, shares string, amount0Min string, amount1Min string, deadline int64) (string, error) {
+	err := checkDeadline(ctx, deadline)
+	if err != nil {
+		return "", err
+	}
+	amount, err := parsePositiveAmount(shares, "shares")
+	if err != nil {
+		return "", err
+	}
+	min0, err := parseAmount(amount0Min, "amount0Min")
+	if err != nil {
+		return "", err
+	}
+	min1, err := parseAmount(amount1Min, "amount1Min")
+	if err != nil {
+		return "", err
 	}
 
-	poolBytes, err := ctx.GetStub().GetState("pool")
+	pool, err := getPool(ctx, pairID)
 	if err != nil {
-		return "", fmt.Errorf("failed to read pool: %v", err)
+		return "", err
 	}
 
-	var pool Pool
-	err = json.Unmarshal(poolBytes, &pool)
+	owner, err := getCallerAccount(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal pool: %v", err)
+		return "", err
 	}
+	position, err := getPosition(ctx, pool.PairID, owner)
+	if err != nil {
+		return "", err
+	}
+
+	return removeLiquidity(ctx, pool, position, amount, min0, min1)
+}
 
-	creatorBytes, err := ctx.GetStub().GetCreator()
+// removeLiquidity 销毁份额代币，从池子中返还 Token0、Token1 及已累计的手续费，份额清零时删除持仓
+func removeLiquidity(ctx contractapi.TransactionContextInterface, pool *Pool, position *Position, amount *big.Int, min0 *big.Int, min1 *big.Int) (string, error) {
+	owner := position.Owner
+	if position.Shares.Cmp(amount) < 0 {
+		return "", fmt.Errorf("insufficient liquidity")
+	}
+	err := observe(ctx, pool)
 	if err != nil {
-		return "", fmt.Errorf("failed to get creator: %v", err)
+		return "", err
 	}
-	owner := string(creatorBytes)
-	var tokenAmount *big.Int
-	if pool.TotalShares.Cmp(big.NewInt(0)) == 0 {
-		tokenAmount = eth // 初始情况下，代币数量等于 ETH 数量
-	} else {
-		tokenAmount = new(big.Int).Mul(eth, pool.TokenReserve)
-		tokenAmount.Div(tokenAmount, pool.ETHReserve)
+
+	amount0 := new(big.Int).Mul(amount, pool.Reserve0)
+	amount0.Div(amount0, pool.TotalShares)
+	amount1 := new(big.Int).Mul(amount, pool.Reserve1)
+	amount1.Div(amount1, pool.TotalShares)
+	if amount0.Cmp(min0) < 0 {
+		return "", fmt.Errorf("insufficient %s amount: %s < %s", pool.Token0, amount0.String(), min0.String())
+	}
+	if amount1.Cmp(min1) < 0 {
+		return "", fmt.Errorf("insufficient %s amount: %s < %s", pool.Token1, amount1.String(), min1.String())
 	}
 
-	pool.ETHReserve.Add(pool.ETHReserve, eth)
-	pool.TokenReserve.Add(pool.TokenReserve, tokenAmount)
-	pool.LiquidityProviders = append(pool.LiquidityProviders, owner)
-	if pool.LPShares[owner] == nil {
-		pool.LPShares[owner] = new(big.Int)
+	// 移除前结算手续费，随本金一并返还
+	fee0, fee1 := takeFees(pool, position)
+	position.Shares.Sub(position.Shares, amount)
+	pool.Reserve0.Sub(pool.Reserve0, amount0)
+	pool.Reserve1.Sub(pool.Reserve1, amount1)
+	pool.TotalShares.Sub(pool.TotalShares, amount)
+
+	// 从池子托管账户向提供者返还资产
+	err = payFromPool(ctx, pool, pool.Token0, owner, new(big.Int).Add(amount0, fee0))
+	if err != nil {
+		return "", err
+	}
+	err = payFromPool(ctx, pool, pool.Token1, owner, new(big.Int).Add(amount1, fee1))
+	if err != nil {
+		return "", err
 	}
-	pool.LPShares[owner].Add(pool.LPShares[owner], eth)
-	pool.TotalShares.Add(pool.TotalShares, eth)
 
-	poolBytes, err = json.Marshal(pool)
+	err = putPosition(ctx, position)
+	if err != nil {
+		return "", err
+	}
+	err = putPool(ctx, pool)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal pool: %v", err)
+		return "", err
 	}
 
-	err = ctx.GetStub().PutState("pool", poolBytes)
+	err = emitEvent(ctx, "RemoveLiquidity", LiquidityEvent{
+		PairID:   pool.PairID,
+		Provider: owner,
+		Amount0:  amount0.String(),
+		Amount1:  amount1.String(),
+		Shares:   amount.String(),
+		Fees0:    fee0.String(),
+		Fees1:    fee1.String(),
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to update pool: %v", err)
+		return "", err
 	}
 
 	return ctx.GetStub().GetTxID(), nil
 }
 
-// RemoveLiquidity 移除部分流动性
-func (e *Exchange) RemoveLiquidity(ctx contractapi.TransactionContextInterface, amountETH string) (string, error) {
-	amount, ok := new(big.Int).SetString(amountETH, 10)
-	if !ok || amount.Cmp(big.NewInt(0)) <= 0 {
-		return "", fmt.Errorf("invalid amountETH")
+// RemoveAllLiquidity 移除所有流动性
+func (e *Exchange) RemoveAllLiquidity(ctx contractapi.TransactionContextInterface, pairID string, amount0Min string, amount1Min string, deadline int64) (string, error) {
+	owner, err := getCallerAccount(ctx)
+	if err != nil {
+		return "", err
 	}
-
-	poolBytes, err := ctx.GetStub().GetState("pool")
+	position, err := getPosition(ctx, pairID, owner)
 	if err != nil {
-		return "", fmt.Errorf("failed to read pool: %v", err)
+		return "", err
 	}
+	if position.Shares.Sign() == 0 {
+		return "", fmt.Errorf("no liquidity to remove")
+	}
+
+	return e.RemoveLiquidity(ctx, pairID, position.Shares.String(), amount0Min, amount1Min, deadline)
+}
 
-	var pool Pool
-	err = json.Unmarshal(poolBytes, &pool)
+// SwapExactIn 在交易对中卖出 amountIn 个 tokenIn，输出低于 minAmountOut 或交易时间晚于 deadline 时交易失败
+// 兑换后价格超出熔断区间时不执行兑换，只记录熔断，结果中的 HaltedUntil 为冷却结束时间
+func (e *Exchange) SwapExactIn(ctx contractapi.TransactionContextInterface, pairID string, tokenIn string, amountIn string, minAmountOut string, deadline int64) (*SwapResult, error) {
+	err := checkDeadline(ctx, deadline)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal pool: %v", err)
+		return nil, err
 	}
-
-	creatorBytes, err := ctx.GetStub().GetCreator()
+	amount, err := parsePositiveAmount(amountIn, "amountIn")
 	if err != nil {
-		return "", fmt.Errorf("failed to get creator: %v", err)
+		return nil, err
 	}
-	owner := string(creatorBytes)
-	lpShare := pool.LPShares[owner]
-	if lpShare == nil || lpShare.Cmp(amount) < 0 {
-		return "", fmt.Errorf("insufficient liquidity")
+	minOut, err := parseAmount(minAmountOut, "minAmountOut")
+	if err != nil {
+		return nil, err
 	}
 
-	ethShare := new(big.Int).Mul(amount, pool.ETHReserve)
-	ethShare.Div(ethShare, pool.TotalShares)
-	tokenShare := new(big.Int).Mul(amount, pool.TokenReserve)
-	tokenShare.Div(tokenShare, pool.TotalShares)
-
-	pool.ETHReserve.Sub(pool.ETHReserve, ethShare)
-	pool.TokenReserve.Sub(pool.TokenReserve, tokenShare)
-	pool.LPShares[owner].Sub(pool.LPShares[owner], amount)
-	pool.TotalShares.Sub(pool.TotalShares, amount)
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return nil, err
+	}
 
-	poolBytes, err = json.Marshal(pool)
+	trader, err := getCallerAccount(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal pool: %v", err)
+		return nil, err
 	}
 
-	err = ctx.GetStub().PutState("pool", poolBytes)
+	amountOut, err := swapExactIn(ctx, pool, trader, tokenIn, amount, minOut)
+	if trip, ok := err.(*circuitBreakerTrip); ok {
+		haltedUntil, err := tripCircuitBreaker(ctx, trip)
+		if err != nil {
+			return nil, err
+		}
+		return &SwapResult{TxID: ctx.GetStub().GetTxID(), AmountIn: amount.String(), AmountOut: "0", HaltedUntil: haltedUntil}, nil
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to update pool: %v", err)
+		return nil, err
 	}
 
-	return ctx.GetStub().GetTxID(), nil
+	return &SwapResult{TxID: ctx.GetStub().GetTxID(), AmountIn: amount.String(), AmountOut: amountOut.String()}, nil
 }
 
-// RemoveAllLiquidity 移除所有流动性
-func (e *Exchange) RemoveAllLiquidity(ctx contractapi.TransactionContextInterface) (string, error) {
-	creatorBytes, err := ctx.GetStub().GetCreator()
+// swapExactIn 执行一次兑换：交易者支付输入代币（含手续费），从池子领取输出代币，并写回池子
+func swapExactIn(ctx contractapi.TransactionContextInterface, pool *Pool, trader string, tokenIn string, amount *big.Int, minOut *big.Int) (*big.Int, error) {
+	err := requireContinuous(pool)
+	if err != nil {
+		return nil, err
+	}
+	protocolFee, err := getProtocolFee(ctx)
+	if err != nil {
+		return nil, err
+	}
+	breaker, err := getCircuitBreaker(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to get creator: %v", err)
+		return nil, err
 	}
-	owner := string(creatorBytes)
-	poolBytes, err := ctx.GetStub().GetState("pool")
+	in, out, amountOut, err := applySwap(ctx, pool, tokenIn, amount, protocolFee, breaker)
 	if err != nil {
-		return "", fmt.Errorf("failed to read pool: %v", err)
+		return nil, err
+	}
+	if amountOut.Cmp(minOut) < 0 {
+		return nil, fmt.Errorf("insufficient output amount: %s < %s", amountOut.String(), minOut.String())
 	}
 
-	var pool Pool
-	err = json.Unmarshal(poolBytes, &pool)
+	err = pullFromTrader(ctx, pool, in.token, trader, amount)
+	if err != nil {
+		return nil, err
+	}
+	err = payFromPool(ctx, pool, out.token, trader, amountOut)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal pool: %v", err)
+		return nil, err
+	}
+	err = putPool(ctx, pool)
+	if err != nil {
+		return nil, err
 	}
 
-	lpShare := pool.LPShares[owner]
-	if lpShare == nil {
-		return "", fmt.Errorf("no liquidity to remove")
+	err = emitEvent(ctx, "Swap", SwapEvent{
+		PairID:    pool.PairID,
+		Trader:    trader,
+		TokenIn:   in.token,
+		AmountIn:  amount.String(),
+		TokenOut:  out.token,
+		AmountOut: amountOut.String(),
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return e.RemoveLiquidity(ctx, lpShare.String())
+	return amountOut, nil
 }
 
-// SwapTokensForETH 将代币换成 ETH
-func (e *Exchange) SwapTokensForETH(ctx contractapi.TransactionContextInterface, amountTokens string) (string, string, error) {
-	amount, ok := new(big.Int).SetString(amountTokens, 10)
-	if !ok || amount.Cmp(big.NewInt(0)) <= 0 {
-		return "", "", fmt.Errorf("amount must be greater than 0")
+// applySwap 在内存中执行一次兑换：记录价格观测，按恒定乘积公式计算输出并更新储备，不处理转账
+func applySwap(ctx contractapi.TransactionContextInterface, pool *Pool, tokenIn string, amount *big.Int, protocolFee *ProtocolFee, breaker *CircuitBreaker) (*poolSide, *poolSide, *big.Int, error) {
+	in, out, err := pool.sides(tokenIn)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-
-	poolBytes, err := ctx.GetStub().GetState("pool")
+	err = requireTradable(ctx, pool)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	reference, err := referencePrice(ctx, pool, breaker)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to read pool: %v", err)
+		return nil, nil, nil, err
+	}
+	err = observe(ctx, pool)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	var pool Pool
-	err = json.Unmarshal(poolBytes, &pool)
+	// 根据恒定乘积公式计算输出数量，手续费从输入中扣除
+	amountOut, fee := getAmountOut(amount, in.reserve, out.reserve, pool.SwapFeeNum, pool.SwapFeeDenom)
+	if amountOut.Sign() <= 0 {
+		return nil, nil, nil, fmt.Errorf("insufficient output amount")
+	}
+	updateReserves(pool, in, out, amount, fee, amountOut, protocolFee)
+	err = checkPriceMove(pool, reference, breaker)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to unmarshal pool: %v", err)
+		return nil, nil, nil, err
 	}
+	return in, out, amountOut, nil
+}
 
-	// 计算交易费用（从输入的 amountTokens 中扣除）
-	fee := new(big.Int).Mul(amount, big.NewInt(int64(pool.SwapFeeNum)))
-	fee.Div(fee, big.NewInt(int64(pool.SwapFeeDenom)))
-	amountAfterFee := new(big.Int).Sub(amount, fee)
+// updateReserves 按一次兑换更新池子储备并计提手续费，不处理转账，调用方负责写回池子
+func updateReserves(pool *Pool, in *poolSide, out *poolSide, amountIn *big.Int, fee *big.Int, amountOut *big.Int, config *ProtocolFee) {
+	in.reserve.Add(in.reserve, new(big.Int).Sub(amountIn, fee))
+	accrueFees(pool, in, fee, config)
+	out.reserve.Sub(out.reserve, amountOut)
+}
 
-	// 根据恒定乘积公式计算输出的 ETH 数量
-	amountETH := new(big.Int).Mul(amountAfterFee, pool.ETHReserve)
-	amountETH.Div(amountETH, new(big.Int).Add(pool.TokenReserve, amountAfterFee))
+// sides 按输入代币返回池子的输入侧与输出侧
+func (p *Pool) sides(tokenIn string) (*poolSide, *poolSide, error) {
+	side0 := &poolSide{token: p.Token0, reserve: p.Reserve0, feeReserve: p.FeeReserve0, feeGrowth: p.FeeGrowth0, protocolFee: p.ProtocolFee0}
+	side1 := &poolSide{token: p.Token1, reserve: p.Reserve1, feeReserve: p.FeeReserve1, feeGrowth: p.FeeGrowth1, protocolFee: p.ProtocolFee1}
 
-	// 更新池子储备
-	pool.ETHReserve.Sub(pool.ETHReserve, amountETH)
-	pool.TokenReserve.Add(pool.TokenReserve, amountAfterFee)
-	pool.TokenFeeReserve.Add(pool.TokenFeeReserve, fee)
+	switch tokenIn {
+	case p.Token0:
+		return side0, side1, nil
+	case p.Token1:
+		return side1, side0, nil
+	default:
+		return nil, nil, fmt.Errorf("token %s is not in pool %s", tokenIn, p.PairID)
+	}
+}
 
-	poolBytes, err = json.Marshal(pool)
+// poolKey 交易对在世界状态中的键
+func poolKey(ctx contractapi.TransactionContextInterface, pairID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(poolPrefix, []string{pairID})
 	if err != nil {
-		return "", "", fmt.Errorf("failed to marshal pool: %v", err)
+		return "", fmt.Errorf("failed to create pool key: %v", err)
 	}
+	return key, nil
+}
 
-	err = ctx.GetStub().PutState("pool", poolBytes)
+// poolExists 判断交易对是否已创建
+func poolExists(ctx contractapi.TransactionContextInterface, pairID string) (bool, error) {
+	key, err := poolKey(ctx, pairID)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to update pool: %v", err)
+		return false, err
 	}
-
-	return ctx.GetStub().GetTxID(), amountETH.String(), nil
+	poolBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read pool: %v", err)
+	}
+	return poolBytes != nil, nil
 }
 
-// SwapETHForTokens 将 ETH 换成代币
-func (e *Exchange) SwapETHForTokens(ctx contractapi.TransactionContextInterface, ethAmount string) (string, string, error) {
-	amount, ok := new(big.Int).SetString(ethAmount, 10)
-	if !ok || amount.Cmp(big.NewInt(0)) <= 0 {
-		return "", "", fmt.Errorf("amount must be greater than 0")
+// getPool 读取交易对的流动性池
+func getPool(ctx contractapi.TransactionContextInterface, pairID string) (*Pool, error) {
+	key, err := poolKey(ctx, pairID)
+	if err != nil {
+		return nil, err
 	}
 
-	poolBytes, err := ctx.GetStub().GetState("pool")
+	poolBytes, err := ctx.GetStub().GetState(key)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to read pool: %v", err)
+		return nil, fmt.Errorf("failed to read pool: %v", err)
 	}
+	if poolBytes == nil {
+		return nil, fmt.Errorf("the pool %s does not exist", pairID)
+	}
+
+	return unmarshalPool(pairID, poolBytes)
+}
 
-	var pool Pool
-	err = json.Unmarshal(poolBytes, &pool)
+// unmarshalPool 解析池子记录，持仓仍保存在池子记录中的旧版本数据需先通过 MigratePools 迁移
+func unmarshalPool(pairID string, poolBytes []byte) (*Pool, error) {
+	var stored struct {
+		Pool
+		LPShares json.RawMessage `json:"lpShares"`
+	}
+	err := json.Unmarshal(poolBytes, &stored)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to unmarshal pool: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal pool: %v", err)
+	}
+	if stored.Token0 == "" || stored.LPShares != nil {
+		return nil, fmt.Errorf("the pool %s must be migrated with MigratePools", pairID)
 	}
 
-	// 计算交易费用（从输入的 ethAmount 中扣除）
-	fee := new(big.Int).Mul(amount, big.NewInt(int64(pool.SwapFeeNum)))
-	fee.Div(fee, big.NewInt(int64(pool.SwapFeeDenom)))
-	amountAfterFee := new(big.Int).Sub(amount, fee)
+	return &stored.Pool, nil
+}
 
-	// 根据恒定乘积公式计算输出的 Token 数量
-	amountTokens := new(big.Int).Mul(amountAfterFee, pool.TokenReserve)
-	amountTokens.Div(amountTokens, new(big.Int).Add(pool.ETHReserve, amountAfterFee))
+// putPool 写入流动性池及本次交易生成的价格观测
+func putPool(ctx contractapi.TransactionContextInterface, pool *Pool) error {
+	key, err := poolKey(ctx, pool.PairID)
+	if err != nil {
+		return err
+	}
 
-	// 更新池子储备
-	pool.ETHReserve.Add(pool.ETHReserve, amountAfterFee)
-	pool.TokenReserve.Sub(pool.TokenReserve, amountTokens)
-	pool.ETHFeeReserve.Add(pool.ETHFeeReserve, fee)
+	poolBytes, err := json.Marshal(pool)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pool: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, poolBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update pool: %v", err)
+	}
+	if pool.observation != nil {
+		err = putObservation(ctx, pool.PairID, pool.ObservationIndex, pool.observation)
+		if err != nil {
+			return err
+		}
+		pool.observation = nil
+	}
+
+	return nil
+}
+
+// poolAccount 池子托管账户，储备与手续费都记在该账户的代币余额中
+func poolAccount(pairID string) string {
+	return poolPrefix + ":" + pairID
+}
 
-	poolBytes, err = json.Marshal(pool)
+// pullFromTrader 从交易者账户向池子托管账户转入代币
+func pullFromTrader(ctx contractapi.TransactionContextInterface, pool *Pool, symbol string, trader string, amount *big.Int) error {
+	if amount.Sign() == 0 {
+		return nil
+	}
+	value, err := toUint64(amount)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to marshal pool: %v", err)
+		return err
 	}
+	return transfer(ctx, symbol, trader, poolAccount(pool.PairID), value)
+}
 
-	err = ctx.GetStub().PutState("pool", poolBytes)
+// payFromPool 从池子托管账户向交易者转出代币
+func payFromPool(ctx contractapi.TransactionContextInterface, pool *Pool, symbol string, trader string, amount *big.Int) error {
+	if amount.Sign() == 0 {
+		return nil
+	}
+	value, err := toUint64(amount)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to update pool: %v", err)
+		return err
 	}
+	return transfer(ctx, symbol, poolAccount(pool.PairID), trader, value)
+}
+
+// toUint64 将池子中的大整数金额转换为代币账本使用的 uint64
+func toUint64(amount *big.Int) (uint64, error) {
+	if amount.Sign() < 0 || !amount.IsUint64() {
+		return 0, fmt.Errorf("amount %s is out of range", amount.String())
+	}
+	return amount.Uint64(), nil
+}
 
-	return ctx.GetStub().GetTxID(), amountTokens.String(), nil
-}
\ No newline at end of file
+// parseAmount 解析非负的十进制金额
+func parseAmount(value string, name string) (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(value, 10)
+	if !ok || amount.Sign() < 0 {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return amount, nil
+}
+
+// parsePositiveAmount 解析大于0的十进制金额
+func parsePositiveAmount(value string, name string) (*big.Int, error) {
+	amount, err := parseAmount(value, name)
+	if err != nil {
+		return nil, err
+	}
+	if amount.Sign() == 0 {
+		return nil, fmt.Errorf("%s must be greater than 0", name)
+	}
+	return amount, nil
+}
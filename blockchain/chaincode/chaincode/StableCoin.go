@@ -0,0 +1,90 @@
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 穩定幣元數據
+const (
+	stableName     = "Stablecoin"
+	stableSymbol   = "STABLE"
+	stableDecimals = 2 // 以分計價
+)
+
+// StableCoin 定義穩定幣合約結構，作為 AMM 中與 CCT 配對的計價貨幣
+type StableCoin struct {
+	contractapi.Contract
+}
+
+// Name 返回代幣名稱
+func (s *StableCoin) Name(ctx contractapi.TransactionContextInterface) (string, error) {
+	return stableName, nil
+}
+
+// Symbol 返回代幣符號
+func (s *StableCoin) Symbol(ctx contractapi.TransactionContextInterface) (string, error) {
+	return stableSymbol, nil
+}
+
+// Decimals 返回代幣精度
+func (s *StableCoin) Decimals(ctx contractapi.TransactionContextInterface) (uint8, error) {
+	return stableDecimals, nil
+}
+
+// TotalSupply 查詢穩定幣總供應量
+func (s *StableCoin) TotalSupply(ctx contractapi.TransactionContextInterface) (uint64, error) {
+	return readTotalSupply(ctx, stableSymbol)
+}
+
+// GetBalance 查詢餘額，owner 可以是賬戶ID或後端用戶ID
+func (s *StableCoin) GetBalance(ctx contractapi.TransactionContextInterface, owner string) (uint64, error) {
+	return queryBalance(ctx, stableSymbol, owner)
+}
+
+// Mint 監管機構向 to 發行穩定幣
+func (s *StableCoin) Mint(ctx contractapi.TransactionContextInterface, to string, amount uint64) error {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return err
+	}
+	to, err = resolveAccount(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	return mint(ctx, stableSymbol, to, amount)
+}
+
+// Burn 監管機構從 from 回收並銷毀穩定幣
+func (s *StableCoin) Burn(ctx contractapi.TransactionContextInterface, from string, amount uint64) error {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return err
+	}
+	from, err = resolveAccount(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	return burn(ctx, stableSymbol, from, amount)
+}
+
+// Transfer 從調用者賬戶轉賬給 to
+func (s *StableCoin) Transfer(ctx contractapi.TransactionContextInterface, to string, amount uint64) error {
+	return callerTransfer(ctx, stableSymbol, to, amount)
+}
+
+// Approve 授權 spender 代表調用者轉出最多 amount 個穩定幣，覆蓋原有額度
+func (s *StableCoin) Approve(ctx contractapi.TransactionContextInterface, spender string, amount uint64) error {
+	return callerApprove(ctx, stableSymbol, spender, amount)
+}
+
+// Allowance 查詢 owner 授權給 spender 的剩餘額度
+func (s *StableCoin) Allowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (uint64, error) {
+	return queryAllowance(ctx, stableSymbol, owner, spender)
+}
+
+// TransferFrom 調用者使用 from 的授權額度轉賬給 to
+func (s *StableCoin) TransferFrom(ctx contractapi.TransactionContextInterface, from string, to string, amount uint64) error {
+	return callerTransferFrom(ctx, stableSymbol, from, to, amount)
+}
@@ -0,0 +1,131 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 角色判定：證書屬性 role 由 Fabric CA 登記時寫入，且必須與所屬 MSP 同時匹配，
+// 其他機構的 CA 簽發的同名屬性不被認可
+const (
+	roleAttribute  = "role"
+	roleRegulator  = "regulator"
+	roleVerifier   = "verifier"
+	roleRegistrar  = "registrar"
+	regulatorMSPID = "Org2MSP"
+	registrarMSPID = "Org1MSP"
+)
+
+// 監管機構認可的核查機構 MSP，鍵為 verifierMSP~MSP ID
+const accreditedVerifierPrefix = "verifierMSP"
+
+// isRegulator 判斷調用者是否為監管機構
+func isRegulator(ctx contractapi.TransactionContextInterface) (bool, error) {
+	return hasRole(ctx, roleRegulator, regulatorMSPID)
+}
+
+// requireRegulator 僅允許監管機構調用
+func requireRegulator(ctx contractapi.TransactionContextInterface) error {
+	ok, err := isRegulator(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("caller is not a regulator")
+	}
+	return nil
+}
+
+// requireRegistrar 僅允許後端登記身份綁定用戶別名，後端網關身份屬於 Org1MSP
+func requireRegistrar(ctx contractapi.TransactionContextInterface) error {
+	ok, err := hasRole(ctx, roleRegistrar, registrarMSPID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("caller is not the registrar")
+	}
+	return nil
+}
+
+// requireVerifier 僅允許經認可的核查機構調用，證書屬性 role=verifier 且所屬 MSP 已由監管機構認可
+func requireVerifier(ctx contractapi.TransactionContextInterface) error {
+	role, found, err := ctx.GetClientIdentity().GetAttributeValue(roleAttribute)
+	if err != nil {
+		return fmt.Errorf("failed to read client attribute: %v", err)
+	}
+	if !found || role != roleVerifier {
+		return fmt.Errorf("caller is not an accredited verifier")
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	accredited, err := isAccreditedVerifier(ctx, mspID)
+	if err != nil {
+		return err
+	}
+	if !accredited {
+		return fmt.Errorf("caller is not an accredited verifier")
+	}
+	return nil
+}
+
+// hasRole 判斷調用者的證書屬性 role 是否為指定角色且屬於指定 MSP
+func hasRole(ctx contractapi.TransactionContextInterface, role string, mspID string) (bool, error) {
+	value, found, err := ctx.GetClientIdentity().GetAttributeValue(roleAttribute)
+	if err != nil {
+		return false, fmt.Errorf("failed to read client attribute: %v", err)
+	}
+	if !found || value != role {
+		return false, nil
+	}
+
+	callerMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	return callerMSPID == mspID, nil
+}
+
+// isAccreditedVerifier 判斷 MSP 是否已被認可為核查機構
+func isAccreditedVerifier(ctx contractapi.TransactionContextInterface, mspID string) (bool, error) {
+	accreditationKey, err := ctx.GetStub().CreateCompositeKey(accreditedVerifierPrefix, []string{mspID})
+	if err != nil {
+		return false, fmt.Errorf("failed to create accreditation key: %v", err)
+	}
+	accreditationBytes, err := ctx.GetStub().GetState(accreditationKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	return accreditationBytes != nil, nil
+}
+
+// setVerifierAccreditation 認可或撤銷核查機構 MSP，認可時記錄交易時間
+func setVerifierAccreditation(ctx contractapi.TransactionContextInterface, mspID string, accredited bool) error {
+	if mspID == "" {
+		return fmt.Errorf("MSP ID must not be empty")
+	}
+	accreditationKey, err := ctx.GetStub().CreateCompositeKey(accreditedVerifierPrefix, []string{mspID})
+	if err != nil {
+		return fmt.Errorf("failed to create accreditation key: %v", err)
+	}
+	if !accredited {
+		err = ctx.GetStub().DelState(accreditationKey)
+		if err != nil {
+			return fmt.Errorf("failed to revoke accreditation: %v", err)
+		}
+		return nil
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(accreditationKey, []byte(txTime))
+	if err != nil {
+		return fmt.Errorf("failed to put accreditation: %v", err)
+	}
+	return nil
+}
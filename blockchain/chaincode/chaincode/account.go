@@ -0,0 +1,347 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	accountPrefix      = "account"
+	aliasPrefix        = "alias"
+	accountAliasIndex  = "accountAlias"
+	accountIDSeparator = "::"
+	// 后端登记身份代用户调用时在 transient 中携带的用户ID
+	transientActingUser = "actingUser"
+	// 雪花ID为int64，十进制最多19位
+	maxAliasLength = 19
+)
+
+/*
+定义链上账户结构
+账户ID由调用者证书ID（cid.GetID）与 MSP ID 计算得出，所有合约统一使用该ID记账
+别名为后端的雪花用户ID，后端以同一网关身份代理多个用户，每个别名绑定到登记身份名下独立的子账户，
+子账户ID再拼接用户ID计算，后端调用时在 transient 的 actingUser 中声明代哪个用户操作
+*/
+type Account struct {
+	AccountID string `json:"accountID"`
+	MSPID     string `json:"mspID"`
+	ClientID  string `json:"clientID"`
+	UserID    string `json:"userID,omitempty" metadata:",optional"` // 子账户所属的后端用户ID
+}
+
+// AccountAlias 定义别名到账户的映射
+type AccountAlias struct {
+	UserID    string `json:"userID"`
+	AccountID string `json:"accountID"`
+}
+
+// RegisterAccount 登记调用者账户，重复调用返回已有账户
+func (s *SmartContract) RegisterAccount(ctx contractapi.TransactionContextInterface) (*Account, error) {
+	return registerCallerAccount(ctx)
+}
+
+// BindAlias 为后端用户ID创建调用者名下的子账户并绑定，仅允许后端登记身份调用
+func (s *SmartContract) BindAlias(ctx contractapi.TransactionContextInterface, userID string) error {
+	return bindAlias(ctx, userID)
+}
+
+// GetMyAccount 查询调用者账户
+func (s *SmartContract) GetMyAccount(ctx contractapi.TransactionContextInterface) (*Account, error) {
+	accountID, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return getAccount(ctx, accountID)
+}
+
+// GetAccount 通过账户ID查询账户
+func (s *SmartContract) GetAccount(ctx contractapi.TransactionContextInterface, accountID string) (*Account, error) {
+	return getAccount(ctx, accountID)
+}
+
+// GetAccountByAlias 通过后端用户ID查询账户
+func (s *SmartContract) GetAccountByAlias(ctx contractapi.TransactionContextInterface, userID string) (*Account, error) {
+	alias, err := getAlias(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if alias == nil {
+		return nil, fmt.Errorf("the alias %s does not exist", userID)
+	}
+	return getAccount(ctx, alias.AccountID)
+}
+
+// GetAccountAliases 查询账户绑定的所有后端用户ID
+func (s *SmartContract) GetAccountAliases(ctx contractapi.TransactionContextInterface, accountID string) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(accountAliasIndex, []string{accountID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	userIDs := []string{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split index key: %v", err)
+		}
+		userIDs = append(userIDs, attributes[1])
+	}
+
+	return userIDs, nil
+}
+
+// getCallerAccount 计算调用者的账户ID，后端登记身份声明了 actingUser 时返回该用户的子账户
+func getCallerAccount(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, clientID, err := callerIdentity(ctx)
+	if err != nil {
+		return "", err
+	}
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("failed to get transient: %v", err)
+	}
+	userID, ok := transientMap[transientActingUser]
+	if !ok {
+		return accountID(mspID, clientID), nil
+	}
+
+	err = requireRegistrar(ctx)
+	if err != nil {
+		return "", err
+	}
+	subAccount := subAccountID(mspID, clientID, string(userID))
+	alias, err := getAlias(ctx, string(userID))
+	if err != nil {
+		return "", err
+	}
+	if alias == nil || alias.AccountID != subAccount {
+		return "", fmt.Errorf("the user %s is not bound to the caller", userID)
+	}
+	return subAccount, nil
+}
+
+// callerIdentity 读取调用者的 MSP ID 与证书ID
+func callerIdentity(ctx contractapi.TransactionContextInterface) (string, string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get client id: %v", err)
+	}
+	return mspID, clientID, nil
+}
+
+// accountID 账户ID为 MSP ID 与证书ID拼接后的 SHA-256 摘要
+func accountID(mspID string, clientID string) string {
+	digest := sha256.Sum256([]byte(mspID + accountIDSeparator + clientID))
+	return hex.EncodeToString(digest[:])
+}
+
+// subAccountID 子账户ID为 MSP ID、登记身份证书ID与后端用户ID拼接后的 SHA-256 摘要
+func subAccountID(mspID string, clientID string, userID string) string {
+	digest := sha256.Sum256([]byte(mspID + accountIDSeparator + clientID + accountIDSeparator + userID))
+	return hex.EncodeToString(digest[:])
+}
+
+// registerCallerAccount 登记调用者账户
+func registerCallerAccount(ctx contractapi.TransactionContextInterface) (*Account, error) {
+	mspID, clientID, err := callerIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return putAccount(ctx, &Account{
+		AccountID: accountID(mspID, clientID),
+		MSPID:     mspID,
+		ClientID:  clientID,
+	})
+}
+
+// putAccount 写入账户，已登记时直接返回
+func putAccount(ctx contractapi.TransactionContextInterface, account *Account) (*Account, error) {
+	accountKey, err := ctx.GetStub().CreateCompositeKey(accountPrefix, []string{account.AccountID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account key: %v", err)
+	}
+	accountBytes, err := ctx.GetStub().GetState(accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if accountBytes != nil {
+		return account, nil
+	}
+
+	accountBytes, err = json.Marshal(account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal account: %v", err)
+	}
+	err = ctx.GetStub().PutState(accountKey, accountBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to put account: %v", err)
+	}
+
+	return account, nil
+}
+
+// getAccount 读取账户
+func getAccount(ctx contractapi.TransactionContextInterface, accountID string) (*Account, error) {
+	accountKey, err := ctx.GetStub().CreateCompositeKey(accountPrefix, []string{accountID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account key: %v", err)
+	}
+	accountBytes, err := ctx.GetStub().GetState(accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if accountBytes == nil {
+		return nil, fmt.Errorf("the account %s does not exist", accountID)
+	}
+
+	var account Account
+	err = json.Unmarshal(accountBytes, &account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal account: %v", err)
+	}
+
+	return &account, nil
+}
+
+// getAlias 读取别名映射，不存在时返回nil
+func getAlias(ctx contractapi.TransactionContextInterface, userID string) (*AccountAlias, error) {
+	aliasKey, err := ctx.GetStub().CreateCompositeKey(aliasPrefix, []string{userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create alias key: %v", err)
+	}
+	aliasBytes, err := ctx.GetStub().GetState(aliasKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if aliasBytes == nil {
+		return nil, nil
+	}
+
+	var alias AccountAlias
+	err = json.Unmarshal(aliasBytes, &alias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal alias: %v", err)
+	}
+
+	return &alias, nil
+}
+
+// bindAlias 为后端用户ID登记调用者名下的子账户并绑定，已绑定到其他账户的别名不能被抢注
+func bindAlias(ctx contractapi.TransactionContextInterface, userID string) error {
+	err := requireRegistrar(ctx)
+	if err != nil {
+		return err
+	}
+	err = validateAlias(userID)
+	if err != nil {
+		return err
+	}
+
+	mspID, clientID, err := callerIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	account, err := putAccount(ctx, &Account{
+		AccountID: subAccountID(mspID, clientID, userID),
+		MSPID:     mspID,
+		ClientID:  clientID,
+		UserID:    userID,
+	})
+	if err != nil {
+		return err
+	}
+	alias, err := getAlias(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if alias != nil {
+		if alias.AccountID != account.AccountID {
+			return fmt.Errorf("the alias %s is bound to another account", userID)
+		}
+		return nil
+	}
+
+	aliasKey, err := ctx.GetStub().CreateCompositeKey(aliasPrefix, []string{userID})
+	if err != nil {
+		return fmt.Errorf("failed to create alias key: %v", err)
+	}
+	aliasBytes, err := json.Marshal(AccountAlias{UserID: userID, AccountID: account.AccountID})
+	if err != nil {
+		return fmt.Errorf("failed to marshal alias: %v", err)
+	}
+	err = ctx.GetStub().PutState(aliasKey, aliasBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put alias: %v", err)
+	}
+
+	return putIndex(ctx, accountAliasIndex, []string{account.AccountID, userID})
+}
+
+// validateAlias 别名只能是雪花用户ID，避免与账户ID（64位十六进制）或池子、篮子等带 ":" 的内部账户混淆
+func validateAlias(userID string) error {
+	if userID == "" || len(userID) > maxAliasLength {
+		return fmt.Errorf("userID must be a snowflake ID of 1 to %d digits", maxAliasLength)
+	}
+	for _, c := range userID {
+		if c < '0' || c > '9' {
+			return fmt.Errorf("userID must be a snowflake ID of 1 to %d digits", maxAliasLength)
+		}
+	}
+	return nil
+}
+
+// requireAlias 校验调用者是否在代该后端用户操作，即 actingUser 声明的子账户与别名绑定的账户一致
+// 参数中已给出 userID，登记方未通过 transient 声明时按其为该用户创建的子账户校验，便于压测工具直接调用
+func requireAlias(ctx contractapi.TransactionContextInterface, userID string) error {
+	caller, err := getCallerAccount(ctx)
+	if err != nil {
+		return err
+	}
+	alias, err := getAlias(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if alias != nil && alias.AccountID == caller {
+		return nil
+	}
+	mspID, clientID, err := callerIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	if alias == nil || alias.AccountID != subAccountID(mspID, clientID, userID) || requireRegistrar(ctx) != nil {
+		return fmt.Errorf("caller is not authorized to act for user %s", userID)
+	}
+	return nil
+}
+
+// resolveAccount 将后端用户ID解析为账户ID，非别名时必须是已登记的账户ID，避免资产转入无人可控的键
+func resolveAccount(ctx contractapi.TransactionContextInterface, ref string) (string, error) {
+	if ref == "" {
+		return "", fmt.Errorf("account must not be empty")
+	}
+	alias, err := getAlias(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	if alias != nil {
+		return alias.AccountID, nil
+	}
+	_, err = getAccount(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("%s is neither a bound user ID nor a registered account", ref)
+	}
+	return ref, nil
+}
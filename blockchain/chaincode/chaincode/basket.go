@@ -0,0 +1,261 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 世界状态命名空间
+const basketPrefix = "basket"
+
+/*
+定义批次篮子，篮子代币由存入的合格批次信用 1:1 支持，可以与 STABLE 创建交易对进入 AMM
+不同批次不可互换，池子只接受篮子代币，因此只有符合篮子准入规则的批次才能进入对应的池子
+存入的批次托管在篮子账户下，持有篮子代币的账户可以指定批次赎回
+*/
+type Basket struct {
+	Symbol     string          `json:"symbol"`
+	Rule       EligibilityRule `json:"rule"`
+	Supply     uint64          `json:"supply"` // 篮子代币流通量，等于托管的批次总量
+	CreateTime string          `json:"createTime"`
+}
+
+// EligibilityRule 定义篮子的准入规则，列表为空或省略表示不限，年份为0表示不限
+type EligibilityRule struct {
+	ProjectIDs    []string `json:"projectIDs" metadata:",optional"`
+	Methodologies []string `json:"methodologies" metadata:",optional"`
+	Countries     []string `json:"countries" metadata:",optional"`
+	MinVintage    int      `json:"minVintage"`
+	MaxVintage    int      `json:"maxVintage"`
+}
+
+// LotDepositEvent 批次存入或赎回事件
+type LotDepositEvent struct {
+	Basket  string `json:"basket"`
+	LotID   string `json:"lotID"`
+	Account string `json:"account"`
+	Amount  uint64 `json:"amount"`
+}
+
+// CreateBasket 监管机构创建批次篮子，篮子代币符号不能与已有代币重复
+func (e *Exchange) CreateBasket(ctx contractapi.TransactionContextInterface, symbol string, rule EligibilityRule) (*Basket, error) {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if symbol == "" || strings.Contains(symbol, pairSeparator) || strings.HasPrefix(symbol, lotSymbolPrefix) {
+		return nil, fmt.Errorf("invalid basket symbol %q", symbol)
+	}
+	if symbol == cctSymbol || symbol == stableSymbol {
+		return nil, fmt.Errorf("basket symbol %s is reserved", symbol)
+	}
+	if rule.MinVintage < 0 || rule.MaxVintage < 0 || (rule.MaxVintage > 0 && rule.MinVintage > rule.MaxVintage) {
+		return nil, fmt.Errorf("invalid vintage range %d-%d", rule.MinVintage, rule.MaxVintage)
+	}
+	existing, err := readBasket(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	supply, err := readTotalSupply(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil || supply > 0 {
+		return nil, fmt.Errorf("the token %s already exists", symbol)
+	}
+
+	createTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 未传入的列表按空列表保存，查询结果中不出现 null
+	for _, list := range []*[]string{&rule.ProjectIDs, &rule.Methodologies, &rule.Countries} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	basket := &Basket{
+		Symbol:     symbol,
+		Rule:       rule,
+		CreateTime: createTime,
+	}
+	err = putBasket(ctx, basket)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "BasketCreated", basket)
+	if err != nil {
+		return nil, err
+	}
+	return basket, nil
+}
+
+// DepositLot 调用者将符合准入规则的批次信用存入篮子，获得等量的篮子代币
+func (e *Exchange) DepositLot(ctx contractapi.TransactionContextInterface, symbol string, lotID string, amount uint64) error {
+	basket, err := getBasket(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	lot, err := getLot(ctx, lotID)
+	if err != nil {
+		return err
+	}
+	if !basket.Rule.accepts(lot) {
+		return fmt.Errorf("lot %s is not eligible for basket %s", lotID, symbol)
+	}
+	account, err := getCallerAccount(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = transferLot(ctx, lotID, account, basketAccount(symbol), amount)
+	if err != nil {
+		return err
+	}
+	err = mint(ctx, symbol, account, amount)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "LotDeposited", LotDepositEvent{Basket: symbol, LotID: lotID, Account: account, Amount: amount})
+}
+
+// RedeemLot 调用者销毁篮子代币，从篮子中赎回指定批次的等量信用
+func (e *Exchange) RedeemLot(ctx contractapi.TransactionContextInterface, symbol string, lotID string, amount uint64) error {
+	_, err := getBasket(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	account, err := getCallerAccount(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = burn(ctx, symbol, account, amount)
+	if err != nil {
+		return err
+	}
+	err = transferLot(ctx, lotID, basketAccount(symbol), account, amount)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "LotRedeemed", LotDepositEvent{Basket: symbol, LotID: lotID, Account: account, Amount: amount})
+}
+
+// GetBasket 查询篮子的准入规则与流通量
+func (e *Exchange) GetBasket(ctx contractapi.TransactionContextInterface, symbol string) (*Basket, error) {
+	return getBasket(ctx, symbol)
+}
+
+// GetBasketLots 查询篮子托管的各批次数量
+func (e *Exchange) GetBasketLots(ctx contractapi.TransactionContextInterface, symbol string) ([]*LotBalance, error) {
+	_, err := getBasket(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	return lotBalances(ctx, basketAccount(symbol))
+}
+
+// IsLotEligible 查询批次是否符合篮子的准入规则
+func (e *Exchange) IsLotEligible(ctx contractapi.TransactionContextInterface, symbol string, lotID string) (bool, error) {
+	basket, err := getBasket(ctx, symbol)
+	if err != nil {
+		return false, err
+	}
+	lot, err := getLot(ctx, lotID)
+	if err != nil {
+		return false, err
+	}
+	return basket.Rule.accepts(lot), nil
+}
+
+// accepts 判断批次是否符合准入规则
+func (r *EligibilityRule) accepts(lot *Lot) bool {
+	if r.MinVintage > 0 && lot.Vintage < r.MinVintage {
+		return false
+	}
+	if r.MaxVintage > 0 && lot.Vintage > r.MaxVintage {
+		return false
+	}
+	return allowed(r.ProjectIDs, lot.ProjectID) && allowed(r.Methodologies, lot.Methodology) && allowed(r.Countries, lot.Country)
+}
+
+// allowed 列表为空或包含 value 时返回 true
+func allowed(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// basketAccount 篮子托管批次信用的账户
+func basketAccount(symbol string) string {
+	return "basket:" + symbol
+}
+
+// getBasket 读取篮子并补充流通量
+func getBasket(ctx contractapi.TransactionContextInterface, symbol string) (*Basket, error) {
+	basket, err := readBasket(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if basket == nil {
+		return nil, fmt.Errorf("the basket %s does not exist", symbol)
+	}
+	basket.Supply, err = readTotalSupply(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	return basket, nil
+}
+
+// readBasket 读取篮子，不存在时返回nil
+func readBasket(ctx contractapi.TransactionContextInterface, symbol string) (*Basket, error) {
+	basketKey, err := ctx.GetStub().CreateCompositeKey(basketPrefix, []string{symbol})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create basket key: %v", err)
+	}
+	basketBytes, err := ctx.GetStub().GetState(basketKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if basketBytes == nil {
+		return nil, nil
+	}
+
+	var basket Basket
+	err = json.Unmarshal(basketBytes, &basket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal basket: %v", err)
+	}
+	return &basket, nil
+}
+
+// putBasket 写入篮子，流通量由账本记录，不随篮子保存
+func putBasket(ctx contractapi.TransactionContextInterface, basket *Basket) error {
+	basketKey, err := ctx.GetStub().CreateCompositeKey(basketPrefix, []string{basket.Symbol})
+	if err != nil {
+		return fmt.Errorf("failed to create basket key: %v", err)
+	}
+	stored := *basket
+	stored.Supply = 0
+	basketBytes, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal basket: %v", err)
+	}
+	err = ctx.GetStub().PutState(basketKey, basketBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put basket: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,682 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 世界状态命名空间
+// 待结算意向索引为 batchPending~交易对~批次号~意向ID，批次号补零后按键排序即为批次先后
+const (
+	swapIntentPrefix       = "swapIntent"
+	batchPendingIndex      = "batchPending"
+	swapIntentAccountIndex = "swapIntentAccount"
+	batchResultPrefix      = "batchResult"
+)
+
+// 兑换意向状态
+const (
+	IntentStatusPending   = "PENDING"
+	IntentStatusSettled   = "SETTLED"  // 全部或部分成交，未成交部分已退回
+	IntentStatusRefunded  = "REFUNDED" // 未成交，全部退回
+	IntentStatusCancelled = "CANCELLED"
+)
+
+// SwapIntent 定义批量拍卖中的兑换意向，提交时输入代币托管在意向自己的托管账户下，
+// 并发提交的意向互不读写同一余额，结算时才汇集到批量拍卖账户
+type SwapIntent struct {
+	IntentID     string `json:"intentId"`
+	PairID       string `json:"pairId"`
+	BatchID      int64  `json:"batchId"` // 批次截止时间（Unix 秒），同时作为批次号
+	Owner        string `json:"owner"`
+	TokenIn      string `json:"tokenIn"`
+	AmountIn     string `json:"amountIn"`
+	MinAmountOut string `json:"minAmountOut"` // 部分成交时按成交比例折算
+	Status       string `json:"status"`
+	AmountSold   string `json:"amountSold"` // 成交的输入数量
+	AmountOut    string `json:"amountOut"`
+	Refund       string `json:"refund"`
+	SubmitTime   string `json:"submitTime"`
+	Escrow       string `json:"escrow,omitempty" metadata:",optional"` // 托管账户，为空时为升级前提交、托管在批量拍卖账户下的意向
+}
+
+// BatchResult 定义一个批次的清算结果，同时作为结算事件的内容
+type BatchResult struct {
+	TxID          string   `json:"txId"`
+	PairID        string   `json:"pairId"`
+	BatchID       int64    `json:"batchId"`
+	Price         string   `json:"price"`         // 统一成交价格，每单位 Token0 可换得的 Token1，没有成交时为空
+	PoolTokenIn   string   `json:"poolTokenIn"`   // 两侧轧差后与池子成交的输入代币，没有与池子成交时为空
+	PoolAmountIn  string   `json:"poolAmountIn"`  // 与池子成交的输入数量
+	PoolAmountOut string   `json:"poolAmountOut"` // 从池子得到的输出数量
+	IntentIDs     []string `json:"intentIds"`
+}
+
+// batchClearing 定义批次的统一清算结果
+// 轧差后仍有剩余的一方为净卖出方，按 fillRatio 成交，剩余部分与池子成交；对手方全部成交
+type batchClearing struct {
+	netToken  string   // 净卖出方的输入代币
+	price     *big.Rat // 每单位 netToken 可换得的对手代币
+	fillRatio *big.Rat
+	poolIn    *big.Int // 净卖出方与池子成交的数量
+}
+
+// SetBatchAuction 监管机构设置交易对的批量拍卖周期，intervalSeconds 为0时恢复连续兑换
+// 批量拍卖模式下交易对不接受直接兑换，兑换意向在每个周期结束后以统一价格清算；存在待结算的意向时不能修改周期
+func (e *Exchange) SetBatchAuction(ctx contractapi.TransactionContextInterface, pairID string, intervalSeconds int64) error {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return err
+	}
+	if intervalSeconds < 0 {
+		return fmt.Errorf("intervalSeconds must not be negative")
+	}
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return err
+	}
+
+	pending, err := ctx.GetStub().GetStateByPartialCompositeKey(batchPendingIndex, []string{pool.PairID})
+	if err != nil {
+		return fmt.Errorf("failed to read pending swap intents: %v", err)
+	}
+	hasPending := pending.HasNext()
+	pending.Close()
+	if hasPending {
+		return fmt.Errorf("the pool %s has pending swap intents", pool.PairID)
+	}
+
+	pool.BatchInterval = intervalSeconds
+	err = putPool(ctx, pool)
+	if err != nil {
+		return err
+	}
+	return emitEvent(ctx, "SetBatchAuction", newPair(pool))
+}
+
+// SubmitSwapIntent 在批量拍卖模式的交易对中提交兑换意向，卖出 amountIn 个 tokenIn，输入代币托管到批次结算
+// 成交价格低于 minAmountOut/amountIn 时意向不成交并全部退回
+func (e *Exchange) SubmitSwapIntent(ctx contractapi.TransactionContextInterface, pairID string, tokenIn string, amountIn string, minAmountOut string) (*SwapIntent, error) {
+	amount, err := parsePositiveAmount(amountIn, "amountIn")
+	if err != nil {
+		return nil, err
+	}
+	minOut, err := parseAmount(minAmountOut, "minAmountOut")
+	if err != nil {
+		return nil, err
+	}
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return nil, err
+	}
+	if pool.BatchInterval == 0 {
+		return nil, fmt.Errorf("the pool %s is not in batch auction mode", pool.PairID)
+	}
+	err = requirePoolOpen(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err = pool.sides(tokenIn); err != nil {
+		return nil, err
+	}
+	owner, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	submitTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := toUint64(amount)
+	if err != nil {
+		return nil, err
+	}
+	escrow := intentAccount(pool.PairID, ctx.GetStub().GetTxID())
+	err = transfer(ctx, tokenIn, owner, escrow, value)
+	if err != nil {
+		return nil, err
+	}
+
+	intent := &SwapIntent{
+		IntentID:     ctx.GetStub().GetTxID(),
+		PairID:       pool.PairID,
+		BatchID:      (txtime.Seconds/pool.BatchInterval + 1) * pool.BatchInterval,
+		Owner:        owner,
+		TokenIn:      tokenIn,
+		AmountIn:     amount.String(),
+		MinAmountOut: minOut.String(),
+		Status:       IntentStatusPending,
+		SubmitTime:   submitTime,
+		Escrow:       escrow,
+	}
+	err = putSwapIntent(ctx, intent)
+	if err != nil {
+		return nil, err
+	}
+	err = putIndex(ctx, batchPendingIndex, pendingAttributes(intent))
+	if err != nil {
+		return nil, err
+	}
+	err = putIndex(ctx, swapIntentAccountIndex, []string{intent.Owner, intent.IntentID})
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "SwapIntentSubmitted", intent)
+	if err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+// CancelSwapIntent 在批次截止前撤销兑换意向并退回托管的代币，只能由提交者撤销
+func (e *Exchange) CancelSwapIntent(ctx contractapi.TransactionContextInterface, intentID string) (*SwapIntent, error) {
+	intent, err := getSwapIntent(ctx, intentID)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Status != IntentStatusPending {
+		return nil, fmt.Errorf("swap intent %s is already %s", intentID, intent.Status)
+	}
+	caller, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Owner != caller {
+		return nil, fmt.Errorf("caller is not the owner of swap intent %s", intentID)
+	}
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	if txtime.Seconds >= intent.BatchID {
+		return nil, fmt.Errorf("batch %d is closed", intent.BatchID)
+	}
+
+	amount, _ := new(big.Int).SetString(intent.AmountIn, 10)
+	value, err := toUint64(amount)
+	if err != nil {
+		return nil, err
+	}
+	err = transfer(ctx, intent.TokenIn, intent.escrow(), intent.Owner, value)
+	if err != nil {
+		return nil, err
+	}
+	err = delIndex(ctx, batchPendingIndex, pendingAttributes(intent))
+	if err != nil {
+		return nil, err
+	}
+	intent.Status = IntentStatusCancelled
+	intent.AmountSold, intent.AmountOut, intent.Refund = "0", "0", intent.AmountIn
+	err = putSwapIntent(ctx, intent)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "SwapIntentCancelled", intent)
+	if err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+// SettleBatch 结算交易对最早一个已截止的批次，任何人都可以触发
+// 两个方向的意向先按统一价格相互成交，轧差后的剩余部分作为一笔兑换与池子成交，统一价格即为该笔兑换的均价；
+// 池子在熔断区间内无法吸收全部剩余时，净卖出方按比例成交，未成交部分退回
+func (e *Exchange) SettleBatch(ctx contractapi.TransactionContextInterface, pairID string) (*BatchResult, error) {
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return nil, err
+	}
+	err = requireTradable(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	batchID, intents, err := closedBatch(ctx, pool.PairID, txtime.Seconds)
+	if err != nil {
+		return nil, err
+	}
+	protocolFee, err := getProtocolFee(ctx)
+	if err != nil {
+		return nil, err
+	}
+	breaker, err := getCircuitBreaker(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reference, err := referencePrice(ctx, pool, breaker)
+	if err != nil {
+		return nil, err
+	}
+	var low, high *big.Rat
+	if reference != nil {
+		low, high = priceBand(reference, breaker)
+	}
+
+	// 统一价格不满足最低输出的意向退出本批次后重新清算，退出的意向越多价格对剩余意向越有利，直到全部满足
+	active := intents
+	var clearing *batchClearing
+	for len(active) > 0 {
+		clearing, err = clearBatch(pool, active, low, high)
+		if err != nil {
+			return nil, err
+		}
+		accepted := []*SwapIntent{}
+		for _, intent := range active {
+			if clearing != nil && clearing.meetsLimit(intent) {
+				accepted = append(accepted, intent)
+			}
+		}
+		if len(accepted) == len(active) {
+			break
+		}
+		active = accepted
+	}
+	filled := make(map[string]bool)
+	for _, intent := range active {
+		filled[intent.IntentID] = true
+	}
+
+	result := &BatchResult{TxID: ctx.GetStub().GetTxID(), PairID: pool.PairID, BatchID: batchID, IntentIDs: []string{}}
+	settle := newSettlement()
+	account := batchAccount(pool.PairID)
+	// 本批次托管代币的变动，结算后的余数为取整产生的零头
+	remainder := map[string]*big.Int{pool.Token0: big.NewInt(0), pool.Token1: big.NewInt(0)}
+
+	if len(active) == 0 {
+		clearing = nil
+	}
+	if clearing != nil && clearing.poolIn.Sign() > 0 {
+		in, out, amountOut, err := applySwap(ctx, pool, clearing.netToken, clearing.poolIn, protocolFee, breaker)
+		if err != nil {
+			return nil, err
+		}
+		settle.move(in.token, account, poolAccount(pool.PairID), clearing.poolIn)
+		settle.move(out.token, poolAccount(pool.PairID), account, amountOut)
+		remainder[in.token].Sub(remainder[in.token], clearing.poolIn)
+		remainder[out.token].Add(remainder[out.token], amountOut)
+		result.PoolTokenIn, result.PoolAmountIn, result.PoolAmountOut = in.token, clearing.poolIn.String(), amountOut.String()
+	}
+	// applySwap 在计算参考价格之后、储备变动之前已记录观测，同一时间戳内 observe 不会重复累加；
+	// 这里为没有池子成交的批次补记观测，不能提前调用，否则 applySwap 计算 TWAP 时会读到尚未写入的观测
+	err = observe(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	if clearing != nil {
+		price0 := clearing.price
+		if clearing.netToken != pool.Token0 {
+			price0 = new(big.Rat).Inv(price0)
+		}
+		result.Price = price0.FloatString(priceDecimals)
+	}
+
+	for _, intent := range intents {
+		amountIn, _ := new(big.Int).SetString(intent.AmountIn, 10)
+		// 意向托管的代币先汇集到批量拍卖账户，再按清算结果分配
+		settle.move(intent.TokenIn, intent.escrow(), account, amountIn)
+		tokenOut := pool.Token0
+		if intent.TokenIn == pool.Token0 {
+			tokenOut = pool.Token1
+		}
+		sold, amountOut := big.NewInt(0), big.NewInt(0)
+		if filled[intent.IntentID] {
+			sold, amountOut = clearing.fill(intent.TokenIn, amountIn)
+		}
+		refund := new(big.Int).Sub(amountIn, sold)
+
+		settle.move(tokenOut, account, intent.Owner, amountOut)
+		settle.move(intent.TokenIn, account, intent.Owner, refund)
+		remainder[intent.TokenIn].Add(remainder[intent.TokenIn], sold)
+		remainder[tokenOut].Sub(remainder[tokenOut], amountOut)
+
+		intent.Status = IntentStatusSettled
+		if sold.Sign() == 0 {
+			intent.Status = IntentStatusRefunded
+		}
+		intent.AmountSold, intent.AmountOut, intent.Refund = sold.String(), amountOut.String(), refund.String()
+		err = putSwapIntent(ctx, intent)
+		if err != nil {
+			return nil, err
+		}
+		err = delIndex(ctx, batchPendingIndex, pendingAttributes(intent))
+		if err != nil {
+			return nil, err
+		}
+		result.IntentIDs = append(result.IntentIDs, intent.IntentID)
+	}
+
+	// 取整零头计入池子储备，批量拍卖账户的净变动为0，只有升级前提交的意向仍托管在该账户下
+	for _, side := range []*poolSide{{token: pool.Token0, reserve: pool.Reserve0}, {token: pool.Token1, reserve: pool.Reserve1}} {
+		dust := remainder[side.token]
+		if dust.Sign() < 0 {
+			return nil, fmt.Errorf("batch %d of %s is short of %s", batchID, pool.PairID, side.token)
+		}
+		settle.move(side.token, account, poolAccount(pool.PairID), dust)
+		side.reserve.Add(side.reserve, dust)
+	}
+
+	err = settle.apply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = putPool(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	err = putBatchResult(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "BatchSettled", result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetSwapIntent 查询兑换意向
+func (e *Exchange) GetSwapIntent(ctx contractapi.TransactionContextInterface, intentID string) (*SwapIntent, error) {
+	return getSwapIntent(ctx, intentID)
+}
+
+// GetSwapIntentsByAccount 查询账户的全部兑换意向，account 可以是账户ID或后端用户ID
+func (e *Exchange) GetSwapIntentsByAccount(ctx contractapi.TransactionContextInterface, account string) ([]*SwapIntent, error) {
+	account, err := resolveAccount(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	intentIDs, err := queryIndex(ctx, swapIntentAccountIndex, account)
+	if err != nil {
+		return nil, err
+	}
+
+	intents := []*SwapIntent{}
+	for _, intentID := range intentIDs {
+		intent, err := getSwapIntent(ctx, intentID)
+		if err != nil {
+			return nil, err
+		}
+		intents = append(intents, intent)
+	}
+	return intents, nil
+}
+
+// GetBatchResult 查询已结算批次的清算结果
+func (e *Exchange) GetBatchResult(ctx contractapi.TransactionContextInterface, pairID string, batchID int64) (*BatchResult, error) {
+	key, err := batchResultKey(ctx, pairID, batchID)
+	if err != nil {
+		return nil, err
+	}
+	resultBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if resultBytes == nil {
+		return nil, fmt.Errorf("batch %d of %s has not been settled", batchID, pairID)
+	}
+
+	var result BatchResult
+	err = json.Unmarshal(resultBytes, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch result: %v", err)
+	}
+	return &result, nil
+}
+
+// requireContinuous 批量拍卖模式的交易对只能通过 SettleBatch 与池子成交
+func requireContinuous(pool *Pool) error {
+	if pool.BatchInterval > 0 {
+		return fmt.Errorf("the pool %s only accepts swaps through batch auctions", pool.PairID)
+	}
+	return nil
+}
+
+// closedBatch 读取交易对最早一个待结算批次的全部意向，该批次必须已经截止
+func closedBatch(ctx contractapi.TransactionContextInterface, pairID string, now int64) (int64, []*SwapIntent, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(batchPendingIndex, []string{pairID})
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read pending swap intents: %v", err)
+	}
+	defer iterator.Close()
+
+	var batchID int64
+	intents := []*SwapIntent{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return 0, nil, err
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to split index key: %v", err)
+		}
+		intent, err := getSwapIntent(ctx, attributes[len(attributes)-1])
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(intents) > 0 && intent.BatchID != batchID {
+			break
+		}
+		batchID = intent.BatchID
+		intents = append(intents, intent)
+	}
+
+	if len(intents) == 0 {
+		return 0, nil, fmt.Errorf("the pool %s has no pending swap intents", pairID)
+	}
+	if now < batchID {
+		return 0, nil, fmt.Errorf("batch %d of %s is still open", batchID, pairID)
+	}
+	return batchID, intents, nil
+}
+
+// clearBatch 计算意向的统一清算价格，没有可以成交的意向时返回 nil
+// 净卖出方向由池子扣除手续费后的价格与两侧意向直接相互成交的价格比较得出；
+// 与池子成交 poolIn 后，对手方全部输入按池子均价换得的净卖出代币加上 poolIn 不能超过净卖出方的输入总额
+func clearBatch(pool *Pool, intents []*SwapIntent, low *big.Rat, high *big.Rat) (*batchClearing, error) {
+	totals := map[string]*big.Int{pool.Token0: big.NewInt(0), pool.Token1: big.NewInt(0)}
+	for _, intent := range intents {
+		amount, _ := new(big.Int).SetString(intent.AmountIn, 10)
+		totals[intent.TokenIn].Add(totals[intent.TokenIn], amount)
+	}
+
+	for _, netToken := range []string{pool.Token0, pool.Token1} {
+		in, out, err := pool.sides(netToken)
+		if err != nil {
+			return nil, err
+		}
+		total, counter := totals[in.token], totals[out.token]
+		if total.Sign() == 0 || in.reserve.Sign() == 0 || out.reserve.Sign() == 0 {
+			continue
+		}
+		// 池子扣除手续费后的价格 reserveOut/reserveIn*(1-fee) 优于直接成交的价格 counter/total 时，该方向为净卖出方
+		poolPrice := new(big.Rat).SetFrac(out.reserve, in.reserve)
+		poolPrice.Mul(poolPrice, big.NewRat(int64(pool.SwapFeeDenom-pool.SwapFeeNum), int64(pool.SwapFeeDenom)))
+		if poolPrice.Cmp(new(big.Rat).SetFrac(counter, total)) <= 0 {
+			continue
+		}
+
+		feasible := func(amount *big.Int) bool {
+			if amount.Sign() == 0 {
+				return true
+			}
+			if low != nil && !priceWithinBand(pool, in, out, amount, low, high) {
+				return false
+			}
+			amountOut, _ := getAmountOut(amount, in.reserve, out.reserve, pool.SwapFeeNum, pool.SwapFeeDenom)
+			lhs := new(big.Int).Mul(amountOut, new(big.Int).Sub(total, amount))
+			return amountOut.Sign() > 0 && lhs.Cmp(new(big.Int).Mul(amount, counter)) >= 0
+		}
+		lo, hi := big.NewInt(0), new(big.Int).Set(total)
+		for lo.Cmp(hi) < 0 {
+			mid := new(big.Int).Add(lo, hi)
+			mid.Add(mid, big.NewInt(1)).Rsh(mid, 1)
+			if feasible(mid) {
+				lo = mid
+			} else {
+				hi = mid.Sub(mid, big.NewInt(1))
+			}
+		}
+
+		// 池子无法成交时按池子扣除手续费后的价格与对手方成交
+		clearing := &batchClearing{netToken: in.token, price: poolPrice, poolIn: lo}
+		if lo.Sign() > 0 {
+			amountOut, _ := getAmountOut(lo, in.reserve, out.reserve, pool.SwapFeeNum, pool.SwapFeeDenom)
+			clearing.price = new(big.Rat).SetFrac(amountOut, lo)
+		}
+		sold := new(big.Rat).Quo(new(big.Rat).SetInt(counter), clearing.price)
+		sold.Add(sold, new(big.Rat).SetInt(lo))
+		clearing.fillRatio = sold.Quo(sold, new(big.Rat).SetInt(total))
+		if clearing.fillRatio.Cmp(big.NewRat(1, 1)) > 0 {
+			clearing.fillRatio = big.NewRat(1, 1)
+		}
+		return clearing, nil
+	}
+
+	// 两侧价格都在池子的手续费区间内，意向直接相互成交，不经过池子
+	total0, total1 := totals[pool.Token0], totals[pool.Token1]
+	if total0.Sign() == 0 || total1.Sign() == 0 {
+		return nil, nil
+	}
+	return &batchClearing{
+		netToken:  pool.Token0,
+		price:     new(big.Rat).SetFrac(total1, total0),
+		fillRatio: big.NewRat(1, 1),
+		poolIn:    big.NewInt(0),
+	}, nil
+}
+
+// fill 计算卖出 amountIn 个 tokenIn 的意向的成交数量与输出，输出向下取整，零头在结算时计入池子储备
+func (c *batchClearing) fill(tokenIn string, amountIn *big.Int) (*big.Int, *big.Int) {
+	if tokenIn != c.netToken {
+		amountOut := new(big.Rat).Quo(new(big.Rat).SetInt(amountIn), c.price)
+		return new(big.Int).Set(amountIn), ratFloor(amountOut)
+	}
+
+	sold := new(big.Rat).Mul(new(big.Rat).SetInt(amountIn), c.fillRatio)
+	amountOut := new(big.Rat).Mul(sold, c.price)
+	// 成交数量向上取整，退回数量相应向下取整
+	soldAmount := ratFloor(sold)
+	if !sold.IsInt() {
+		soldAmount.Add(soldAmount, big.NewInt(1))
+	}
+	return soldAmount, ratFloor(amountOut)
+}
+
+// meetsLimit 意向在统一价格下的输出是否不低于其最低输出
+func (c *batchClearing) meetsLimit(intent *SwapIntent) bool {
+	amountIn, _ := new(big.Rat).SetString(intent.AmountIn)
+	minOut, _ := new(big.Rat).SetString(intent.MinAmountOut)
+	price := c.price
+	if intent.TokenIn != c.netToken {
+		price = new(big.Rat).Inv(price)
+	}
+	return amountIn.Mul(amountIn, price).Cmp(minOut) >= 0
+}
+
+// ratFloor 有理数向下取整
+func ratFloor(value *big.Rat) *big.Int {
+	return new(big.Int).Quo(value.Num(), value.Denom())
+}
+
+// batchAccount 批量拍卖账户，结算时汇集本批次意向托管的代币
+func batchAccount(pairID string) string {
+	return "batch:" + pairID
+}
+
+// intentAccount 兑换意向的托管账户
+func intentAccount(pairID string, intentID string) string {
+	return batchAccount(pairID) + ":" + intentID
+}
+
+// escrow 返回意向的托管账户，升级前提交的意向托管在批量拍卖账户下
+func (intent *SwapIntent) escrow() string {
+	if intent.Escrow == "" {
+		return batchAccount(intent.PairID)
+	}
+	return intent.Escrow
+}
+
+// pendingAttributes 意向在待结算索引中的属性，批次号补零以便按键排序
+func pendingAttributes(intent *SwapIntent) []string {
+	return []string{intent.PairID, fmt.Sprintf("%019d", intent.BatchID), intent.IntentID}
+}
+
+// getSwapIntent 读取兑换意向
+func getSwapIntent(ctx contractapi.TransactionContextInterface, intentID string) (*SwapIntent, error) {
+	intentKey, err := ctx.GetStub().CreateCompositeKey(swapIntentPrefix, []string{intentID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create swap intent key: %v", err)
+	}
+	intentBytes, err := ctx.GetStub().GetState(intentKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if intentBytes == nil {
+		return nil, fmt.Errorf("the swap intent %s does not exist", intentID)
+	}
+
+	var intent SwapIntent
+	err = json.Unmarshal(intentBytes, &intent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal swap intent: %v", err)
+	}
+	return &intent, nil
+}
+
+// putSwapIntent 写入兑换意向
+func putSwapIntent(ctx contractapi.TransactionContextInterface, intent *SwapIntent) error {
+	intentKey, err := ctx.GetStub().CreateCompositeKey(swapIntentPrefix, []string{intent.IntentID})
+	if err != nil {
+		return fmt.Errorf("failed to create swap intent key: %v", err)
+	}
+	intentBytes, err := json.Marshal(intent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal swap intent: %v", err)
+	}
+	err = ctx.GetStub().PutState(intentKey, intentBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put swap intent: %v", err)
+	}
+	return nil
+}
+
+// batchResultKey 批次清算结果在世界状态中的键
+func batchResultKey(ctx contractapi.TransactionContextInterface, pairID string, batchID int64) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(batchResultPrefix, []string{pairID, fmt.Sprintf("%019d", batchID)})
+	if err != nil {
+		return "", fmt.Errorf("failed to create batch result key: %v", err)
+	}
+	return key, nil
+}
+
+// putBatchResult 写入批次清算结果
+func putBatchResult(ctx contractapi.TransactionContextInterface, result *BatchResult) error {
+	key, err := batchResultKey(ctx, result.PairID, result.BatchID)
+	if err != nil {
+		return err
+	}
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch result: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, resultBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put batch result: %v", err)
+	}
+	return nil
+}
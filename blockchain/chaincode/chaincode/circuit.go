@@ -0,0 +1,355 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 全局暂停与熔断参数存放在交易所全局配置下
+const (
+	pauseKey          = "pause"
+	circuitBreakerKey = "circuitBreaker"
+	basisPoints       = 10000
+)
+
+// 默认熔断参数：兑换后价格偏离最近一小时 TWAP 超过 20% 时熔断，暂停兑换十分钟
+var defaultCircuitBreaker = CircuitBreaker{MaxMoveBps: 2000, WindowSeconds: 3600, CooldownSeconds: 600}
+
+// CircuitBreaker 定义熔断参数
+type CircuitBreaker struct {
+	MaxMoveBps      uint64 `json:"maxMoveBps"`      // 兑换后价格相对参考价格允许的最大偏离（基点），0 表示关闭熔断
+	WindowSeconds   int64  `json:"windowSeconds"`   // 参考价格为该窗口内的 TWAP，观测不足一个窗口时为兑换前的价格
+	CooldownSeconds int64  `json:"cooldownSeconds"` // 熔断后暂停兑换的时长
+}
+
+// PauseState 定义全局暂停状态
+type PauseState struct {
+	Paused bool `json:"paused"`
+}
+
+// TradingStatus 定义交易对的交易状态
+type TradingStatus struct {
+	PairID       string `json:"pairId"`
+	GlobalPaused bool   `json:"globalPaused"`
+	PoolPaused   bool   `json:"poolPaused"`
+	HaltedUntil  int64  `json:"haltedUntil"` // 熔断结束时间（Unix 秒），0 表示未熔断
+	Tradable     bool   `json:"tradable"`
+}
+
+// PauseEvent 暂停/恢复交易事件，PairID 为空表示全局
+type PauseEvent struct {
+	PairID string `json:"pairId"`
+	Paused bool   `json:"paused"`
+}
+
+// CircuitBreakerEvent 熔断事件
+type CircuitBreakerEvent struct {
+	PairID         string `json:"pairId"`
+	ReferencePrice string `json:"referencePrice"` // 每单位 Token0 可换得的 Token1
+	Price          string `json:"price"`          // 触发熔断的兑换完成后的价格
+	HaltedUntil    int64  `json:"haltedUntil"`
+}
+
+// circuitBreakerTrip 兑换触发熔断，调用方需放弃本次兑换，改为调用 tripCircuitBreaker 记录熔断
+type circuitBreakerTrip struct {
+	pairID    string
+	reference *big.Rat
+	price     *big.Rat
+}
+
+func (t *circuitBreakerTrip) Error() string {
+	return fmt.Sprintf("swap moves the price of %s beyond the circuit breaker limit", t.pairID)
+}
+
+// SetGlobalPause 监管机构暂停或恢复所有交易对的兑换、添加流动性与订单簿下单，暂停期间仍可移除流动性、领取手续费与撤单
+func (e *Exchange) SetGlobalPause(ctx contractapi.TransactionContextInterface, paused bool) error {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return err
+	}
+	err = putExchangeConfig(ctx, pauseKey, PauseState{Paused: paused})
+	if err != nil {
+		return err
+	}
+	return emitEvent(ctx, "TradingPaused", PauseEvent{Paused: paused})
+}
+
+// SetPoolPaused 监管机构暂停或恢复单个交易对，恢复时同时解除熔断
+func (e *Exchange) SetPoolPaused(ctx contractapi.TransactionContextInterface, pairID string, paused bool) error {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return err
+	}
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return err
+	}
+
+	pool.Paused = paused
+	if !paused {
+		pool.HaltedUntil = 0
+	}
+	err = putPool(ctx, pool)
+	if err != nil {
+		return err
+	}
+	return emitEvent(ctx, "TradingPaused", PauseEvent{PairID: pool.PairID, Paused: paused})
+}
+
+// SetCircuitBreaker 监管机构设置熔断参数，maxMoveBps 须小于10000，为0时关闭熔断
+func (e *Exchange) SetCircuitBreaker(ctx contractapi.TransactionContextInterface, maxMoveBps uint64, windowSeconds int64, cooldownSeconds int64) error {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return err
+	}
+	if maxMoveBps >= basisPoints {
+		return fmt.Errorf("maxMoveBps must be less than %d", basisPoints)
+	}
+	if maxMoveBps > 0 && (windowSeconds <= 0 || cooldownSeconds <= 0) {
+		return fmt.Errorf("windowSeconds and cooldownSeconds must be greater than 0")
+	}
+
+	config := CircuitBreaker{MaxMoveBps: maxMoveBps, WindowSeconds: windowSeconds, CooldownSeconds: cooldownSeconds}
+	err = putExchangeConfig(ctx, circuitBreakerKey, config)
+	if err != nil {
+		return err
+	}
+	return emitEvent(ctx, "SetCircuitBreaker", config)
+}
+
+// GetCircuitBreaker 查询熔断参数，未设置时为默认参数
+func (e *Exchange) GetCircuitBreaker(ctx contractapi.TransactionContextInterface) (*CircuitBreaker, error) {
+	return getCircuitBreaker(ctx)
+}
+
+// GetTradingStatus 查询交易对当前是否可以交易
+func (e *Exchange) GetTradingStatus(ctx contractapi.TransactionContextInterface, pairID string) (*TradingStatus, error) {
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return nil, err
+	}
+	pause, err := getPauseState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+
+	status := &TradingStatus{
+		PairID:       pool.PairID,
+		GlobalPaused: pause.Paused,
+		PoolPaused:   pool.Paused,
+	}
+	if pool.HaltedUntil > txtime.Seconds {
+		status.HaltedUntil = pool.HaltedUntil
+	}
+	status.Tradable = !status.GlobalPaused && !status.PoolPaused && status.HaltedUntil == 0
+	return status, nil
+}
+
+// requireNotPaused 全局暂停时返回错误
+func requireNotPaused(ctx contractapi.TransactionContextInterface) error {
+	pause, err := getPauseState(ctx)
+	if err != nil {
+		return err
+	}
+	if pause.Paused {
+		return fmt.Errorf("trading is paused")
+	}
+	return nil
+}
+
+// requirePoolOpen 全局或交易对暂停时返回错误，熔断不影响添加流动性
+func requirePoolOpen(ctx contractapi.TransactionContextInterface, pool *Pool) error {
+	err := requireNotPaused(ctx)
+	if err != nil {
+		return err
+	}
+	if pool.Paused {
+		return fmt.Errorf("the pool %s is paused", pool.PairID)
+	}
+	return nil
+}
+
+// requireTradable 交易对暂停或处于熔断冷却期时返回错误
+func requireTradable(ctx contractapi.TransactionContextInterface, pool *Pool) error {
+	err := requirePoolOpen(ctx, pool)
+	if err != nil {
+		return err
+	}
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	if txtime.Seconds < pool.HaltedUntil {
+		return fmt.Errorf("the pool %s is halted by the circuit breaker until %d", pool.PairID, pool.HaltedUntil)
+	}
+	return nil
+}
+
+// referencePrice 熔断的参考价格（每单位 Token0 可换得的 Token1），需在储备变动前调用
+// 观测覆盖整个窗口时取窗口内的 TWAP，否则取当前价格；熔断关闭或池子没有流动性时返回 nil
+func referencePrice(ctx contractapi.TransactionContextInterface, pool *Pool, config *CircuitBreaker) (*big.Rat, error) {
+	if config.MaxMoveBps == 0 || pool.Reserve0.Sign() == 0 || pool.Reserve1.Sign() == 0 {
+		return nil, nil
+	}
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+
+	if pool.ObservationCount > 0 {
+		first, err := getObservation(ctx, pool.PairID, oldestObservation(pool))
+		if err != nil {
+			return nil, err
+		}
+		if first.Timestamp <= txtime.Seconds-config.WindowSeconds {
+			price0, _, err := twap(ctx, pool, txtime.Seconds, config.WindowSeconds)
+			if err != nil {
+				return nil, err
+			}
+			if price0.Sign() > 0 {
+				return price0, nil
+			}
+		}
+	}
+	return new(big.Rat).SetFrac(pool.Reserve1, pool.Reserve0), nil
+}
+
+// checkPriceMove 校验兑换后的价格相对参考价格的偏离，超过熔断阈值时返回 *circuitBreakerTrip
+func checkPriceMove(pool *Pool, reference *big.Rat, config *CircuitBreaker) error {
+	if reference == nil || pool.Reserve0.Sign() == 0 {
+		return nil
+	}
+	low, high := priceBand(reference, config)
+	price := new(big.Rat).SetFrac(pool.Reserve1, pool.Reserve0)
+	if price.Cmp(low) < 0 || price.Cmp(high) > 0 {
+		return &circuitBreakerTrip{pairID: pool.PairID, reference: reference, price: price}
+	}
+	return nil
+}
+
+// priceBand 参考价格上下 MaxMoveBps 的价格区间
+func priceBand(reference *big.Rat, config *CircuitBreaker) (*big.Rat, *big.Rat) {
+	move := new(big.Rat).SetFrac64(int64(config.MaxMoveBps), basisPoints)
+	one := big.NewRat(1, 1)
+	low := new(big.Rat).Mul(reference, new(big.Rat).Sub(one, move))
+	high := new(big.Rat).Mul(reference, new(big.Rat).Add(one, move))
+	return low, high
+}
+
+// priceWithinBand 在池子中卖出 amountIn 个输入代币后的价格是否仍在 [low, high] 内，按 applySwap 相同的方式计算成交后的储备
+func priceWithinBand(pool *Pool, in *poolSide, out *poolSide, amountIn *big.Int, low *big.Rat, high *big.Rat) bool {
+	amountOut, fee := getAmountOut(amountIn, in.reserve, out.reserve, pool.SwapFeeNum, pool.SwapFeeDenom)
+	reserveIn := new(big.Int).Add(in.reserve, amountIn)
+	reserveIn.Sub(reserveIn, fee)
+	reserveOut := new(big.Int).Sub(out.reserve, amountOut)
+	if reserveIn.Sign() <= 0 || reserveOut.Sign() <= 0 {
+		return false
+	}
+
+	reserve0, reserve1 := reserveIn, reserveOut
+	if in.token != pool.Token0 {
+		reserve0, reserve1 = reserveOut, reserveIn
+	}
+	price := new(big.Rat).SetFrac(reserve1, reserve0)
+	return price.Cmp(low) >= 0 && price.Cmp(high) <= 0
+}
+
+// tripCircuitBreaker 记录熔断：重新读取未被本次兑换修改的池子，设置冷却结束时间并发出熔断事件
+// 链码返回错误时交易不会写入账本，因此触发熔断的交易以成功提交的方式记录熔断，兑换本身不执行
+func tripCircuitBreaker(ctx contractapi.TransactionContextInterface, trip *circuitBreakerTrip) (int64, error) {
+	config, err := getCircuitBreaker(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pool, err := getPool(ctx, trip.pairID)
+	if err != nil {
+		return 0, err
+	}
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+
+	pool.HaltedUntil = txtime.Seconds + config.CooldownSeconds
+	err = putPool(ctx, pool)
+	if err != nil {
+		return 0, err
+	}
+	err = emitEvent(ctx, "CircuitBreakerTripped", CircuitBreakerEvent{
+		PairID:         pool.PairID,
+		ReferencePrice: trip.reference.FloatString(priceDecimals),
+		Price:          trip.price.FloatString(priceDecimals),
+		HaltedUntil:    pool.HaltedUntil,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return pool.HaltedUntil, nil
+}
+
+// getPauseState 读取全局暂停状态
+func getPauseState(ctx contractapi.TransactionContextInterface) (*PauseState, error) {
+	var pause PauseState
+	found, err := getExchangeConfig(ctx, pauseKey, &pause)
+	if err != nil || !found {
+		return &PauseState{}, err
+	}
+	return &pause, nil
+}
+
+// getCircuitBreaker 读取熔断参数
+func getCircuitBreaker(ctx contractapi.TransactionContextInterface) (*CircuitBreaker, error) {
+	var config CircuitBreaker
+	found, err := getExchangeConfig(ctx, circuitBreakerKey, &config)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		config = defaultCircuitBreaker
+	}
+	return &config, nil
+}
+
+// getExchangeConfig 读取交易所全局配置，未设置时返回 false
+func getExchangeConfig(ctx contractapi.TransactionContextInterface, name string, config interface{}) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(exchangeConfigPrefix, []string{name})
+	if err != nil {
+		return false, fmt.Errorf("failed to create config key: %v", err)
+	}
+	configBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	if configBytes == nil {
+		return false, nil
+	}
+	err = json.Unmarshal(configBytes, config)
+	if err != nil {
+		return false, fmt.Errorf("failed to unmarshal %s: %v", name, err)
+	}
+	return true, nil
+}
+
+// putExchangeConfig 写入交易所全局配置
+func putExchangeConfig(ctx contractapi.TransactionContextInterface, name string, config interface{}) error {
+	key, err := ctx.GetStub().CreateCompositeKey(exchangeConfigPrefix, []string{name})
+	if err != nil {
+		return fmt.Errorf("failed to create config key: %v", err)
+	}
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %v", name, err)
+	}
+	err = ctx.GetStub().PutState(key, configBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put %s: %v", name, err)
+	}
+	return nil
+}
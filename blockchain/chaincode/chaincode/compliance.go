@@ -0,0 +1,457 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 履约期状态
+const (
+	PeriodStatusOpen   = "OPEN"
+	PeriodStatusClosed = "CLOSED"
+)
+
+// 企业履约状态，履约期关闭前为 PENDING
+const (
+	ComplianceStatusPending      = "PENDING"
+	ComplianceStatusCompliant    = "COMPLIANT"
+	ComplianceStatusNonCompliant = "NON_COMPLIANT"
+)
+
+// 世界状态命名空间
+const (
+	compliancePeriodPrefix     = "compliancePeriod"
+	complianceObligationPrefix = "complianceObligation"
+	complianceAccountIndex     = "complianceAccount"
+)
+
+// Compliance 定义碳排放权履约合约：监管机构开启履约期并核定各企业的排放量与免费配额，
+// 企业在截止时间前清缴 CCT 抵扣履约缺口，履约期关闭时计算盈余或缺口并标记未履约企业
+type Compliance struct {
+	contractapi.Contract
+}
+
+// CompliancePeriod 定义履约期
+type CompliancePeriod struct {
+	PeriodID     string `json:"periodId"`
+	Name         string `json:"name"`
+	Deadline     int64  `json:"deadline"` // 清缴截止时间（Unix 秒）
+	Status       string `json:"status"`
+	CreateTime   string `json:"createTime"`
+	CloseTime    string `json:"closeTime,omitempty" metadata:",optional"`
+	Enterprises  int    `json:"enterprises"`  // 关闭时纳入核算的企业数
+	Compliant    int    `json:"compliant"`    // 关闭时已履约的企业数
+	NonCompliant int    `json:"nonCompliant"` // 关闭时未履约的企业数
+}
+
+// Obligation 定义企业在一个履约期内的履约义务
+// 履约要求为核定排放量，免费配额与清缴的 CCT 共同抵扣，超出部分为盈余，不足部分为缺口
+type Obligation struct {
+	PeriodID          string `json:"periodId"`
+	Account           string `json:"account"`
+	VerifiedEmissions uint64 `json:"verifiedEmissions"` // 核定排放量（吨 CO2e）
+	FreeAllocation    uint64 `json:"freeAllocation"`    // 免费配额
+	Surrendered       uint64 `json:"surrendered"`       // 已清缴的 CCT
+	Surplus           uint64 `json:"surplus"`
+	Deficit           uint64 `json:"deficit"`
+	Status            string `json:"status"`
+	UpdateTime        string `json:"updateTime"`
+}
+
+// OpenPeriod 监管机构开启履约期，deadline 为清缴截止时间（Unix 秒）
+func (c *Compliance) OpenPeriod(ctx contractapi.TransactionContextInterface, periodID string, name string, deadline int64) (*CompliancePeriod, error) {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if periodID == "" {
+		return nil, fmt.Errorf("period ID must not be empty")
+	}
+	now, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	if deadline <= now.Seconds {
+		return nil, fmt.Errorf("invalid deadline")
+	}
+	existing, err := readPeriod(ctx, periodID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("the compliance period %s already exists", periodID)
+	}
+	createTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	period := &CompliancePeriod{
+		PeriodID:   periodID,
+		Name:       name,
+		Deadline:   deadline,
+		Status:     PeriodStatusOpen,
+		CreateTime: createTime,
+	}
+	err = putPeriod(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "PeriodOpened", period)
+	if err != nil {
+		return nil, err
+	}
+	return period, nil
+}
+
+// SetObligation 监管机构核定企业在履约期内的排放量与免费配额，履约期关闭前可以重新核定
+// enterprise 可以是账户ID或后端用户ID，须已登记账户
+func (c *Compliance) SetObligation(ctx contractapi.TransactionContextInterface, periodID string, enterprise string, verifiedEmissions uint64, freeAllocation uint64) (*Obligation, error) {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	period, err := getOpenPeriod(ctx, periodID)
+	if err != nil {
+		return nil, err
+	}
+	enterprise, err = resolveAccount(ctx, enterprise)
+	if err != nil {
+		return nil, err
+	}
+	_, err = getAccount(ctx, enterprise)
+	if err != nil {
+		return nil, err
+	}
+
+	obligation, err := readObligation(ctx, period.PeriodID, enterprise)
+	if err != nil {
+		return nil, err
+	}
+	if obligation == nil {
+		obligation = &Obligation{PeriodID: period.PeriodID, Account: enterprise, Status: ComplianceStatusPending}
+		err = putIndex(ctx, complianceAccountIndex, []string{enterprise, period.PeriodID})
+		if err != nil {
+			return nil, err
+		}
+	}
+	obligation.VerifiedEmissions = verifiedEmissions
+	obligation.FreeAllocation = freeAllocation
+	err = updateObligation(ctx, obligation)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "ObligationSet", obligation)
+	if err != nil {
+		return nil, err
+	}
+	return obligation, nil
+}
+
+// Surrender 企业在截止时间前清缴 amount 个 CCT 抵扣履约缺口，清缴的 CCT 被销毁
+// 清缴数量不能超过当前缺口，避免多缴的 CCT 无法取回
+func (c *Compliance) Surrender(ctx contractapi.TransactionContextInterface, periodID string, amount uint64) (*Obligation, error) {
+	if amount == 0 {
+		return nil, fmt.Errorf("amount must be greater than 0")
+	}
+	period, err := getOpenPeriod(ctx, periodID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkDeadline(ctx, period.Deadline)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	obligation, err := getObligation(ctx, period.PeriodID, caller)
+	if err != nil {
+		return nil, err
+	}
+	if amount > obligation.Deficit {
+		return nil, fmt.Errorf("amount %d exceeds the outstanding obligation %d", amount, obligation.Deficit)
+	}
+
+	err = burn(ctx, cctSymbol, caller, amount)
+	if err != nil {
+		return nil, err
+	}
+	_, err = surrenderSerials(ctx, cctSymbol, caller, amount, period.PeriodID)
+	if err != nil {
+		return nil, err
+	}
+	obligation.Surrendered += amount
+	err = updateObligation(ctx, obligation)
+	if err != nil {
+		return nil, err
+	}
+
+	// 清缴事件覆盖 burn 发出的 Transfer 事件
+	err = emitEvent(ctx, "Surrendered", obligation)
+	if err != nil {
+		return nil, err
+	}
+	return obligation, nil
+}
+
+// ClosePeriod 监管机构在截止时间之后关闭履约期，核算每家企业的盈余或缺口，存在缺口的企业标记为未履约
+func (c *Compliance) ClosePeriod(ctx contractapi.TransactionContextInterface, periodID string) (*CompliancePeriod, error) {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	period, err := getOpenPeriod(ctx, periodID)
+	if err != nil {
+		return nil, err
+	}
+	now, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	if now.Seconds <= period.Deadline {
+		return nil, fmt.Errorf("the compliance period %s cannot be closed before its deadline", periodID)
+	}
+
+	obligations, err := periodObligations(ctx, periodID)
+	if err != nil {
+		return nil, err
+	}
+	for _, obligation := range obligations {
+		obligation.Status = ComplianceStatusCompliant
+		if obligation.Deficit > 0 {
+			obligation.Status = ComplianceStatusNonCompliant
+			period.NonCompliant++
+		} else {
+			period.Compliant++
+		}
+		err = updateObligation(ctx, obligation)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	period.Enterprises = len(obligations)
+	period.Status = PeriodStatusClosed
+	period.CloseTime, err = getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = putPeriod(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "PeriodClosed", period)
+	if err != nil {
+		return nil, err
+	}
+	return period, nil
+}
+
+// GetPeriod 查询履约期
+func (c *Compliance) GetPeriod(ctx contractapi.TransactionContextInterface, periodID string) (*CompliancePeriod, error) {
+	return getPeriod(ctx, periodID)
+}
+
+// GetObligation 查询企业在履约期内的履约义务，enterprise 可以是账户ID或后端用户ID
+func (c *Compliance) GetObligation(ctx contractapi.TransactionContextInterface, periodID string, enterprise string) (*Obligation, error) {
+	enterprise, err := resolveAccount(ctx, enterprise)
+	if err != nil {
+		return nil, err
+	}
+	return getObligation(ctx, periodID, enterprise)
+}
+
+// GetObligationsByPeriod 查询履约期内全部企业的履约义务
+func (c *Compliance) GetObligationsByPeriod(ctx contractapi.TransactionContextInterface, periodID string) ([]*Obligation, error) {
+	_, err := getPeriod(ctx, periodID)
+	if err != nil {
+		return nil, err
+	}
+	return periodObligations(ctx, periodID)
+}
+
+// GetObligationsByAccount 查询企业在各履约期的履约义务，enterprise 可以是账户ID或后端用户ID
+func (c *Compliance) GetObligationsByAccount(ctx contractapi.TransactionContextInterface, enterprise string) ([]*Obligation, error) {
+	enterprise, err := resolveAccount(ctx, enterprise)
+	if err != nil {
+		return nil, err
+	}
+	periodIDs, err := queryIndex(ctx, complianceAccountIndex, enterprise)
+	if err != nil {
+		return nil, err
+	}
+
+	obligations := []*Obligation{}
+	for _, periodID := range periodIDs {
+		obligation, err := getObligation(ctx, periodID, enterprise)
+		if err != nil {
+			return nil, err
+		}
+		obligations = append(obligations, obligation)
+	}
+	return obligations, nil
+}
+
+// updateObligation 按排放量、免费配额与已清缴数量重新计算盈余与缺口后写入
+func updateObligation(ctx contractapi.TransactionContextInterface, obligation *Obligation) error {
+	covered, err := addUint64(obligation.FreeAllocation, obligation.Surrendered)
+	if err != nil {
+		return err
+	}
+	obligation.Surplus, obligation.Deficit = 0, 0
+	if covered >= obligation.VerifiedEmissions {
+		obligation.Surplus = covered - obligation.VerifiedEmissions
+	} else {
+		obligation.Deficit = obligation.VerifiedEmissions - covered
+	}
+	obligation.UpdateTime, err = getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	return putObligation(ctx, obligation)
+}
+
+// periodObligations 读取履约期内全部企业的履约义务
+func periodObligations(ctx contractapi.TransactionContextInterface, periodID string) ([]*Obligation, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(complianceObligationPrefix, []string{periodID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	obligations := []*Obligation{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var obligation Obligation
+		err = json.Unmarshal(queryResponse.Value, &obligation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal obligation: %v", err)
+		}
+		obligations = append(obligations, &obligation)
+	}
+	return obligations, nil
+}
+
+// getOpenPeriod 读取履约期并确认尚未关闭
+func getOpenPeriod(ctx contractapi.TransactionContextInterface, periodID string) (*CompliancePeriod, error) {
+	period, err := getPeriod(ctx, periodID)
+	if err != nil {
+		return nil, err
+	}
+	if period.Status != PeriodStatusOpen {
+		return nil, fmt.Errorf("the compliance period %s is already %s", periodID, period.Status)
+	}
+	return period, nil
+}
+
+// getPeriod 读取履约期，不存在时返回错误
+func getPeriod(ctx contractapi.TransactionContextInterface, periodID string) (*CompliancePeriod, error) {
+	period, err := readPeriod(ctx, periodID)
+	if err != nil {
+		return nil, err
+	}
+	if period == nil {
+		return nil, fmt.Errorf("the compliance period %s does not exist", periodID)
+	}
+	return period, nil
+}
+
+// readPeriod 读取履约期，不存在时返回nil
+func readPeriod(ctx contractapi.TransactionContextInterface, periodID string) (*CompliancePeriod, error) {
+	periodKey, err := ctx.GetStub().CreateCompositeKey(compliancePeriodPrefix, []string{periodID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create compliance period key: %v", err)
+	}
+	periodBytes, err := ctx.GetStub().GetState(periodKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if periodBytes == nil {
+		return nil, nil
+	}
+
+	var period CompliancePeriod
+	err = json.Unmarshal(periodBytes, &period)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal compliance period: %v", err)
+	}
+	return &period, nil
+}
+
+// putPeriod 写入履约期
+func putPeriod(ctx contractapi.TransactionContextInterface, period *CompliancePeriod) error {
+	periodKey, err := ctx.GetStub().CreateCompositeKey(compliancePeriodPrefix, []string{period.PeriodID})
+	if err != nil {
+		return fmt.Errorf("failed to create compliance period key: %v", err)
+	}
+	periodBytes, err := json.Marshal(period)
+	if err != nil {
+		return fmt.Errorf("failed to marshal compliance period: %v", err)
+	}
+	err = ctx.GetStub().PutState(periodKey, periodBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put compliance period: %v", err)
+	}
+	return nil
+}
+
+// getObligation 读取履约义务，不存在时返回错误
+func getObligation(ctx contractapi.TransactionContextInterface, periodID string, account string) (*Obligation, error) {
+	obligation, err := readObligation(ctx, periodID, account)
+	if err != nil {
+		return nil, err
+	}
+	if obligation == nil {
+		return nil, fmt.Errorf("account %s has no obligation in compliance period %s", account, periodID)
+	}
+	return obligation, nil
+}
+
+// readObligation 读取履约义务，不存在时返回nil
+func readObligation(ctx contractapi.TransactionContextInterface, periodID string, account string) (*Obligation, error) {
+	obligationKey, err := ctx.GetStub().CreateCompositeKey(complianceObligationPrefix, []string{periodID, account})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create obligation key: %v", err)
+	}
+	obligationBytes, err := ctx.GetStub().GetState(obligationKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if obligationBytes == nil {
+		return nil, nil
+	}
+
+	var obligation Obligation
+	err = json.Unmarshal(obligationBytes, &obligation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal obligation: %v", err)
+	}
+	return &obligation, nil
+}
+
+// putObligation 写入履约义务
+func putObligation(ctx contractapi.TransactionContextInterface, obligation *Obligation) error {
+	obligationKey, err := ctx.GetStub().CreateCompositeKey(complianceObligationPrefix, []string{obligation.PeriodID, obligation.Account})
+	if err != nil {
+		return fmt.Errorf("failed to create obligation key: %v", err)
+	}
+	obligationBytes, err := json.Marshal(obligation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal obligation: %v", err)
+	}
+	err = ctx.GetStub().PutState(obligationKey, obligationBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put obligation: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package chaincode
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 交易对ID中两个代币符号之间的分隔符
+const pairSeparator = "-"
+
+// Pair 定义交易对查询结果
+type Pair struct {
+	PairID        string `json:"pairId"`
+	Token0        string `json:"token0"`
+	Token1        string `json:"token1"`
+	Reserve0      string `json:"reserve0"`
+	Reserve1      string `json:"reserve1"`
+	TotalShares   string `json:"totalShares"`
+	SwapFeeNum    uint64 `json:"swapFeeNum"`
+	SwapFeeDenom  uint64 `json:"swapFeeDenom"`
+	Paused        bool   `json:"paused"`
+	HaltedUntil   int64  `json:"haltedUntil"`   // 熔断冷却结束时间（Unix 秒）
+	BatchInterval int64  `json:"batchInterval"` // 批量拍卖周期（秒），0 表示连续兑换
+}
+
+// PairCreatedEvent 创建交易对事件
+type PairCreatedEvent struct {
+	PairID       string `json:"pairId"`
+	Token0       string `json:"token0"`
+	Token1       string `json:"token1"`
+	SwapFeeNum   uint64 `json:"swapFeeNum"`
+	SwapFeeDenom uint64 `json:"swapFeeDenom"`
+}
+
+// CreatePair 监管机构为两种代币创建交易对，返回交易对ID
+// 两个代币按符号排序，tokenA/tokenB 的顺序不影响结果，同一对代币只能创建一个池子
+func (e *Exchange) CreatePair(ctx contractapi.TransactionContextInterface, tokenA string, tokenB string, feeNum uint64, feeDenom uint64) (string, error) {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return "", err
+	}
+	if feeDenom == 0 || feeNum >= feeDenom {
+		return "", fmt.Errorf("invalid swap fee %d/%d", feeNum, feeDenom)
+	}
+
+	token0, token1, err := sortTokens(tokenA, tokenB)
+	if err != nil {
+		return "", err
+	}
+	pairID := token0 + pairSeparator + token1
+	exists, err := poolExists(ctx, pairID)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", fmt.Errorf("the pool %s already exists", pairID)
+	}
+
+	pool := Pool{
+		PairID:       pairID,
+		Token0:       token0,
+		Token1:       token1,
+		Reserve0:     big.NewInt(0),
+		Reserve1:     big.NewInt(0),
+		FeeReserve0:  big.NewInt(0),
+		FeeReserve1:  big.NewInt(0),
+		FeeGrowth0:   big.NewInt(0),
+		FeeGrowth1:   big.NewInt(0),
+		ProtocolFee0: big.NewInt(0),
+		ProtocolFee1: big.NewInt(0),
+		SwapFeeNum:   feeNum,
+		SwapFeeDenom: feeDenom,
+		TotalShares:  big.NewInt(0),
+
+		PriceCumulative0: big.NewInt(0),
+		PriceCumulative1: big.NewInt(0),
+	}
+	err = putPool(ctx, &pool)
+	if err != nil {
+		return "", err
+	}
+
+	err = emitEvent(ctx, "PairCreated", PairCreatedEvent{
+		PairID:       pairID,
+		Token0:       token0,
+		Token1:       token1,
+		SwapFeeNum:   feeNum,
+		SwapFeeDenom: feeDenom,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return pairID, nil
+}
+
+// GetPair 通过交易对ID查询交易对
+func (e *Exchange) GetPair(ctx contractapi.TransactionContextInterface, pairID string) (*Pair, error) {
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return nil, err
+	}
+	return newPair(pool), nil
+}
+
+// GetPairByTokens 通过两个代币符号查询交易对，顺序不限
+func (e *Exchange) GetPairByTokens(ctx contractapi.TransactionContextInterface, tokenA string, tokenB string) (*Pair, error) {
+	token0, token1, err := sortTokens(tokenA, tokenB)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetPair(ctx, token0+pairSeparator+token1)
+}
+
+// ListPairs 查询所有交易对
+func (e *Exchange) ListPairs(ctx contractapi.TransactionContextInterface) ([]*Pair, error) {
+	return queryPairs(ctx, "")
+}
+
+// GetPairsByToken 查询包含指定代币的所有交易对
+func (e *Exchange) GetPairsByToken(ctx contractapi.TransactionContextInterface, token string) ([]*Pair, error) {
+	if token == "" {
+		return nil, fmt.Errorf("token must not be empty")
+	}
+	return queryPairs(ctx, token)
+}
+
+// queryPairs 遍历所有池子，token 不为空时只返回包含该代币的交易对
+func queryPairs(ctx contractapi.TransactionContextInterface, token string) ([]*Pair, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(poolPrefix, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	pairs := []*Pair{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split pool key: %v", err)
+		}
+
+		pool, err := unmarshalPool(attributes[0], queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+		if token != "" && pool.Token0 != token && pool.Token1 != token {
+			continue
+		}
+		pairs = append(pairs, newPair(pool))
+	}
+
+	return pairs, nil
+}
+
+// newPair 由池子生成交易对查询结果
+func newPair(pool *Pool) *Pair {
+	return &Pair{
+		PairID:        pool.PairID,
+		Token0:        pool.Token0,
+		Token1:        pool.Token1,
+		Reserve0:      pool.Reserve0.String(),
+		Reserve1:      pool.Reserve1.String(),
+		TotalShares:   pool.TotalShares.String(),
+		SwapFeeNum:    pool.SwapFeeNum,
+		SwapFeeDenom:  pool.SwapFeeDenom,
+		Paused:        pool.Paused,
+		HaltedUntil:   pool.HaltedUntil,
+		BatchInterval: pool.BatchInterval,
+	}
+}
+
+// sortTokens 校验并排序两个代币符号，符号中不能包含交易对分隔符，也不能是批次信用
+func sortTokens(tokenA string, tokenB string) (string, string, error) {
+	if tokenA == "" || tokenB == "" {
+		return "", "", fmt.Errorf("token symbol must not be empty")
+	}
+	if strings.Contains(tokenA, pairSeparator) || strings.Contains(tokenB, pairSeparator) {
+		return "", "", fmt.Errorf("token symbol must not contain %q", pairSeparator)
+	}
+	// 批次信用须经篮子准入后以篮子代币上池，池子转账不会随余额移动批次序列号
+	if strings.HasPrefix(tokenA, lotSymbolPrefix) || strings.HasPrefix(tokenB, lotSymbolPrefix) {
+		return "", "", fmt.Errorf("lot credits cannot be pooled directly, deposit them into a basket instead")
+	}
+	if tokenA == tokenB {
+		return "", "", fmt.Errorf("identical tokens %s", tokenA)
+	}
+	if tokenA > tokenB {
+		return tokenB, tokenA, nil
+	}
+	return tokenA, tokenB, nil
+}
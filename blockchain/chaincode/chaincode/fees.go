@@ -0,0 +1,271 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 交易所全局配置存放在 exchangeConfig~配置名 组合键下
+const (
+	exchangeConfigPrefix = "exchangeConfig"
+	protocolFeeKey       = "protocolFee"
+)
+
+// 每份额累计手续费按 2^128 放大保存，避免份额远大于单笔手续费时被取整为0
+var feeGrowthScale = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// ProtocolFee 定义协议费配置，FeeNum/FeeDenom 为从每笔交易手续费中划给国库的比例
+type ProtocolFee struct {
+	Treasury string `json:"treasury"`
+	FeeNum   uint64 `json:"feeNum"`
+	FeeDenom uint64 `json:"feeDenom"`
+}
+
+// FeeClaim 定义手续费领取结果
+type FeeClaim struct {
+	TxID    string `json:"txId"`
+	PairID  string `json:"pairId"`
+	Account string `json:"account"`
+	Amount0 string `json:"amount0"`
+	Amount1 string `json:"amount1"`
+}
+
+// FeeEvent 领取手续费/归集协议费事件
+type FeeEvent struct {
+	PairID  string `json:"pairId"`
+	Account string `json:"account"`
+	Amount0 string `json:"amount0"`
+	Amount1 string `json:"amount1"`
+}
+
+// SetProtocolFee 监管机构设置协议费比例与国库账户，feeNum 为0时关闭协议费
+func (e *Exchange) SetProtocolFee(ctx contractapi.TransactionContextInterface, treasury string, feeNum uint64, feeDenom uint64) error {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return err
+	}
+	if feeDenom == 0 || feeNum >= feeDenom {
+		return fmt.Errorf("invalid protocol fee %d/%d", feeNum, feeDenom)
+	}
+
+	config := ProtocolFee{FeeNum: feeNum, FeeDenom: feeDenom}
+	if treasury != "" {
+		config.Treasury, err = resolveAccount(ctx, treasury)
+		if err != nil {
+			return err
+		}
+	}
+	if feeNum > 0 && config.Treasury == "" {
+		return fmt.Errorf("treasury is required when the protocol fee is enabled")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(exchangeConfigPrefix, []string{protocolFeeKey})
+	if err != nil {
+		return fmt.Errorf("failed to create config key: %v", err)
+	}
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal protocol fee: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, configBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put protocol fee: %v", err)
+	}
+
+	return emitEvent(ctx, "SetProtocolFee", config)
+}
+
+// GetProtocolFee 查询协议费配置，未设置时比例为0
+func (e *Exchange) GetProtocolFee(ctx contractapi.TransactionContextInterface) (*ProtocolFee, error) {
+	return getProtocolFee(ctx)
+}
+
+// ClaimFees 领取调用者在交易对中累计的手续费，份额保持不变
+func (e *Exchange) ClaimFees(ctx contractapi.TransactionContextInterface, pairID string) (*FeeClaim, error) {
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	position, err := getPosition(ctx, pool.PairID, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	fee0, fee1 := takeFees(pool, position)
+	if fee0.Sign() == 0 && fee1.Sign() == 0 {
+		return nil, fmt.Errorf("no fees to claim")
+	}
+	err = payFromPool(ctx, pool, pool.Token0, owner, fee0)
+	if err != nil {
+		return nil, err
+	}
+	err = payFromPool(ctx, pool, pool.Token1, owner, fee1)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putPosition(ctx, position)
+	if err != nil {
+		return nil, err
+	}
+	err = putPool(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFeeClaim(ctx, "ClaimFees", pool, owner, fee0, fee1)
+}
+
+// CollectProtocolFees 将交易对中累计的协议费转入国库，任何人都可以触发
+func (e *Exchange) CollectProtocolFees(ctx contractapi.TransactionContextInterface, pairID string) (*FeeClaim, error) {
+	config, err := getProtocolFee(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config.Treasury == "" {
+		return nil, fmt.Errorf("treasury is not set")
+	}
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return nil, err
+	}
+
+	fee0, fee1 := pool.ProtocolFee0, pool.ProtocolFee1
+	if fee0.Sign() == 0 && fee1.Sign() == 0 {
+		return nil, fmt.Errorf("no protocol fees to collect")
+	}
+	err = payFromPool(ctx, pool, pool.Token0, config.Treasury, fee0)
+	if err != nil {
+		return nil, err
+	}
+	err = payFromPool(ctx, pool, pool.Token1, config.Treasury, fee1)
+	if err != nil {
+		return nil, err
+	}
+	pool.ProtocolFee0 = big.NewInt(0)
+	pool.ProtocolFee1 = big.NewInt(0)
+
+	err = putPool(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFeeClaim(ctx, "CollectProtocolFees", pool, config.Treasury, fee0, fee1)
+}
+
+// newFeeClaim 发出手续费事件并组装领取结果
+func newFeeClaim(ctx contractapi.TransactionContextInterface, event string, pool *Pool, account string, fee0 *big.Int, fee1 *big.Int) (*FeeClaim, error) {
+	err := emitEvent(ctx, event, FeeEvent{
+		PairID:  pool.PairID,
+		Account: account,
+		Amount0: fee0.String(),
+		Amount1: fee1.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FeeClaim{
+		TxID:    ctx.GetStub().GetTxID(),
+		PairID:  pool.PairID,
+		Account: account,
+		Amount0: fee0.String(),
+		Amount1: fee1.String(),
+	}, nil
+}
+
+// getProtocolFee 读取协议费配置
+func getProtocolFee(ctx contractapi.TransactionContextInterface) (*ProtocolFee, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(exchangeConfigPrefix, []string{protocolFeeKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config key: %v", err)
+	}
+	configBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read protocol fee: %v", err)
+	}
+	if configBytes == nil {
+		return &ProtocolFee{FeeNum: 0, FeeDenom: 1}, nil
+	}
+
+	var config ProtocolFee
+	err = json.Unmarshal(configBytes, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal protocol fee: %v", err)
+	}
+	return &config, nil
+}
+
+// accrueFees 将一笔交易手续费按协议费比例拆分，LP 部分计入每份额累计手续费
+func accrueFees(pool *Pool, in *poolSide, fee *big.Int, config *ProtocolFee) {
+	protocolFee := new(big.Int).Mul(fee, new(big.Int).SetUint64(config.FeeNum))
+	protocolFee.Div(protocolFee, new(big.Int).SetUint64(config.FeeDenom))
+	lpFee := new(big.Int).Sub(fee, protocolFee)
+
+	in.protocolFee.Add(in.protocolFee, protocolFee)
+	in.feeReserve.Add(in.feeReserve, lpFee)
+	if pool.TotalShares.Sign() > 0 {
+		growth := new(big.Int).Mul(lpFee, feeGrowthScale)
+		in.feeGrowth.Add(in.feeGrowth, growth.Div(growth, pool.TotalShares))
+	}
+}
+
+// pendingFees 计算 LP 当前可领取的手续费，包括已结算未领取的部分
+func pendingFees(pool *Pool, position *Position) (*big.Int, *big.Int) {
+	fee0 := new(big.Int).Add(position.Owed0, earned(position.Shares, pool.FeeGrowth0, position.Growth0))
+	fee1 := new(big.Int).Add(position.Owed1, earned(position.Shares, pool.FeeGrowth1, position.Growth1))
+	return fee0, fee1
+}
+
+// settleFees 在 LP 份额变动前结算手续费并更新检查点
+func settleFees(pool *Pool, position *Position) {
+	position.Owed0, position.Owed1 = pendingFees(pool, position)
+	position.Growth0 = new(big.Int).Set(pool.FeeGrowth0)
+	position.Growth1 = new(big.Int).Set(pool.FeeGrowth1)
+}
+
+// takeFees 结算并取出 LP 的全部手续费，调用方负责转账
+func takeFees(pool *Pool, position *Position) (*big.Int, *big.Int) {
+	settleFees(pool, position)
+	fee0, fee1 := position.Owed0, position.Owed1
+	position.Owed0 = big.NewInt(0)
+	position.Owed1 = big.NewInt(0)
+
+	pool.FeeReserve0.Sub(pool.FeeReserve0, fee0)
+	pool.FeeReserve1.Sub(pool.FeeReserve1, fee1)
+	return fee0, fee1
+}
+
+// earned 计算份额在两个累计值之间获得的手续费
+func earned(shares *big.Int, growth *big.Int, lastGrowth *big.Int) *big.Int {
+	amount := new(big.Int).Sub(growth, lastGrowth)
+	amount.Mul(amount, shares)
+	return amount.Div(amount, feeGrowthScale)
+}
+
+// initFees 补齐旧记录缺失的手续费字段，升级前费用池中的手续费按当前份额分配给现有 LP
+func initFees(pool *Pool) {
+	pool.FeeReserve0 = orZero(pool.FeeReserve0)
+	pool.FeeReserve1 = orZero(pool.FeeReserve1)
+	pool.ProtocolFee0 = orZero(pool.ProtocolFee0)
+	pool.ProtocolFee1 = orZero(pool.ProtocolFee1)
+	if pool.FeeGrowth0 != nil && pool.FeeGrowth1 != nil {
+		return
+	}
+
+	pool.FeeGrowth0 = big.NewInt(0)
+	pool.FeeGrowth1 = big.NewInt(0)
+	if pool.TotalShares.Sign() > 0 {
+		pool.FeeGrowth0.Mul(pool.FeeReserve0, feeGrowthScale)
+		pool.FeeGrowth0.Div(pool.FeeGrowth0, pool.TotalShares)
+		pool.FeeGrowth1.Mul(pool.FeeReserve1, feeGrowthScale)
+		pool.FeeGrowth1.Div(pool.FeeGrowth1, pool.TotalShares)
+	}
+}
@@ -0,0 +1,323 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 世界狀態命名空間
+const (
+	creditLotPrefix = "creditLot"
+	lotHoldingIndex = "lotHolding"
+	lotProjectIndex = "lotProject"
+	lotIDSeparator  = ":"
+	lotSymbolPrefix = cctSymbol + "#"
+)
+
+/*
+定義碳信用批次（lot），同一項目、方法學、年份、國家與簽發批次的信用可以互換，不同批次之間不可互換
+批次餘額與 CCT、STABLE 共用記賬邏輯，代幣符號為 CCT#批次ID，類似 ERC-1155 中以 ID 區分的代幣
+*/
+type Lot struct {
+	LotID         string `json:"lotID"` // 項目ID:年份:簽發批次
+	ProjectID     string `json:"projectID"`
+	Methodology   string `json:"methodology"`
+	Vintage       int    `json:"vintage"` // 減排發生的年份
+	Country       string `json:"country"`
+	IssuanceBatch string `json:"issuanceBatch"`
+	Issued        uint64 `json:"issued"`  // 累計簽發量
+	Supply        uint64 `json:"supply"`  // 當前流通量
+	Retired       uint64 `json:"retired"` // 累計註銷量
+	IssueTime     string `json:"issueTime"`
+}
+
+// LotBalance 定義賬戶持有的某一批次的餘額
+type LotBalance struct {
+	LotID   string `json:"lotID"`
+	Balance uint64 `json:"balance"`
+}
+
+// LotIssuedEvent 批次簽發事件
+type LotIssuedEvent struct {
+	LotID   string `json:"lotID"`
+	Owner   string `json:"owner"`
+	Amount  uint64 `json:"amount"`
+	Serials string `json:"serials"` // 本次簽發的序列號區間
+}
+
+// IssueLot 監管機構簽發碳信用批次給 to，同一批次可以多次增發，屬性必須與首次簽發一致
+// 每次簽發分配一段連續的序列號
+func (c *CarbonCoinToken) IssueLot(ctx contractapi.TransactionContextInterface, projectID string, methodology string, vintage int, country string, issuanceBatch string, to string, amount uint64) (*Lot, error) {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if methodology == "" || country == "" {
+		return nil, fmt.Errorf("methodology and country must not be empty")
+	}
+	lotID, err := newLotID(projectID, vintage, issuanceBatch)
+	if err != nil {
+		return nil, err
+	}
+	to, err = resolveAccount(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	lot, err := readLot(ctx, lotID)
+	if err != nil {
+		return nil, err
+	}
+	if lot == nil {
+		issueTime, err := getTxTime(ctx)
+		if err != nil {
+			return nil, err
+		}
+		lot = &Lot{
+			LotID:         lotID,
+			ProjectID:     projectID,
+			Methodology:   methodology,
+			Vintage:       vintage,
+			Country:       country,
+			IssuanceBatch: issuanceBatch,
+			IssueTime:     issueTime,
+		}
+		err = putIndex(ctx, lotProjectIndex, []string{projectID, lotID})
+		if err != nil {
+			return nil, err
+		}
+	} else if lot.Methodology != methodology || lot.Country != country {
+		return nil, fmt.Errorf("lot %s was issued with different attributes", lotID)
+	}
+
+	lot.Issued, err = addUint64(lot.Issued, amount)
+	if err != nil {
+		return nil, err
+	}
+	err = putLot(ctx, lot)
+	if err != nil {
+		return nil, err
+	}
+	err = putIndex(ctx, lotHoldingIndex, []string{to, lotID})
+	if err != nil {
+		return nil, err
+	}
+
+	// 本交易寫入的流通量讀不到，按增發前的數量計算
+	err = fillLotSupply(ctx, lot)
+	if err != nil {
+		return nil, err
+	}
+	lot.Supply += amount
+	err = mint(ctx, lotSymbol(lotID), to, amount)
+	if err != nil {
+		return nil, err
+	}
+	serials, err := issueSerials(ctx, lotID, to, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	// 簽發事件覆蓋 mint 發出的 Transfer 事件，附帶本次分配的序列號區間
+	err = emitEvent(ctx, "LotIssued", LotIssuedEvent{LotID: lotID, Owner: to, Amount: amount, Serials: formatSerialRange(serials)})
+	if err != nil {
+		return nil, err
+	}
+
+	return lot, nil
+}
+
+// TransferLot 從調用者賬戶轉出指定批次的信用給 to
+func (c *CarbonCoinToken) TransferLot(ctx contractapi.TransactionContextInterface, lotID string, to string, amount uint64) error {
+	_, err := getLot(ctx, lotID)
+	if err != nil {
+		return err
+	}
+	from, err := getCallerAccount(ctx)
+	if err != nil {
+		return err
+	}
+	to, err = resolveAccount(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	return transferLot(ctx, lotID, from, to, amount)
+}
+
+// RetireLot 註銷調用者持有的指定批次信用，證書中記錄批次屬性
+func (c *CarbonCoinToken) RetireLot(ctx contractapi.TransactionContextInterface, lotID string, amount uint64, beneficiary string, purpose string, reportingYear int) (*RetirementCertificate, error) {
+	lot, err := getLot(ctx, lotID)
+	if err != nil {
+		return nil, err
+	}
+	return retire(ctx, lot, amount, beneficiary, purpose, reportingYear)
+}
+
+// GetLot 查詢批次屬性與簽發、流通、註銷數量
+func (c *CarbonCoinToken) GetLot(ctx contractapi.TransactionContextInterface, lotID string) (*Lot, error) {
+	return getLot(ctx, lotID)
+}
+
+// GetLotsByProject 查詢項目的全部批次
+func (c *CarbonCoinToken) GetLotsByProject(ctx contractapi.TransactionContextInterface, projectID string) ([]*Lot, error) {
+	lotIDs, err := queryIndex(ctx, lotProjectIndex, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	lots := []*Lot{}
+	for _, lotID := range lotIDs {
+		lot, err := getLot(ctx, lotID)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+	return lots, nil
+}
+
+// BalanceOfLot 查詢賬戶持有的指定批次餘額，owner 可以是賬戶ID或後端用戶ID
+func (c *CarbonCoinToken) BalanceOfLot(ctx contractapi.TransactionContextInterface, owner string, lotID string) (uint64, error) {
+	return queryBalance(ctx, lotSymbol(lotID), owner)
+}
+
+// GetLotBalances 查詢賬戶持有的全部批次餘額，不含已轉空的批次
+func (c *CarbonCoinToken) GetLotBalances(ctx contractapi.TransactionContextInterface, owner string) ([]*LotBalance, error) {
+	owner, err := resolveAccount(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return lotBalances(ctx, owner)
+}
+
+// transferLot 轉移批次信用及其序列號，並為收款方登記持有索引
+func transferLot(ctx contractapi.TransactionContextInterface, lotID string, from string, to string, amount uint64) error {
+	err := transfer(ctx, lotSymbol(lotID), from, to, amount)
+	if err != nil || from == to {
+		return err
+	}
+	err = moveSerials(ctx, lotID, from, to, amount)
+	if err != nil {
+		return err
+	}
+	return putIndex(ctx, lotHoldingIndex, []string{to, lotID})
+}
+
+// lotBalances 讀取賬戶持有的批次餘額，持有索引只增不減，餘額為0的批次在此過濾
+func lotBalances(ctx contractapi.TransactionContextInterface, owner string) ([]*LotBalance, error) {
+	lotIDs, err := queryIndex(ctx, lotHoldingIndex, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := []*LotBalance{}
+	for _, lotID := range lotIDs {
+		balance, err := readBalance(ctx, lotSymbol(lotID), owner)
+		if err != nil {
+			return nil, err
+		}
+		if balance > 0 {
+			balances = append(balances, &LotBalance{LotID: lotID, Balance: balance})
+		}
+	}
+	return balances, nil
+}
+
+// newLotID 由項目ID、年份與簽發批次生成批次ID，各部分不能包含分隔符
+func newLotID(projectID string, vintage int, issuanceBatch string) (string, error) {
+	if vintage <= 0 {
+		return "", fmt.Errorf("invalid vintage %d", vintage)
+	}
+	for _, part := range []string{projectID, issuanceBatch} {
+		if part == "" {
+			return "", fmt.Errorf("project ID and issuance batch must not be empty")
+		}
+		if strings.Contains(part, lotIDSeparator) || strings.Contains(part, pairSeparator) {
+			return "", fmt.Errorf("%q must not contain %q or %q", part, lotIDSeparator, pairSeparator)
+		}
+	}
+	return strings.Join([]string{projectID, strconv.Itoa(vintage), issuanceBatch}, lotIDSeparator), nil
+}
+
+// lotSymbol 批次在賬本中的代幣符號
+func lotSymbol(lotID string) string {
+	return lotSymbolPrefix + lotID
+}
+
+// getLot 讀取批次並補充流通量與註銷量
+func getLot(ctx contractapi.TransactionContextInterface, lotID string) (*Lot, error) {
+	lot, err := readLot(ctx, lotID)
+	if err != nil {
+		return nil, err
+	}
+	if lot == nil {
+		return nil, fmt.Errorf("the lot %s does not exist", lotID)
+	}
+
+	err = fillLotSupply(ctx, lot)
+	if err != nil {
+		return nil, err
+	}
+	return lot, nil
+}
+
+// fillLotSupply 從賬本讀取批次的流通量與註銷量
+func fillLotSupply(ctx contractapi.TransactionContextInterface, lot *Lot) error {
+	var err error
+	lot.Supply, err = readTotalSupply(ctx, lotSymbol(lot.LotID))
+	if err != nil {
+		return err
+	}
+	lot.Retired, err = readRetiredSupply(ctx, lotSymbol(lot.LotID))
+	return err
+}
+
+// readLot 讀取批次記錄，不存在時返回nil
+func readLot(ctx contractapi.TransactionContextInterface, lotID string) (*Lot, error) {
+	lotKey, err := ctx.GetStub().CreateCompositeKey(creditLotPrefix, []string{lotID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lot key: %v", err)
+	}
+
+	lotBytes, err := ctx.GetStub().GetState(lotKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if lotBytes == nil {
+		return nil, nil
+	}
+
+	var lot Lot
+	err = json.Unmarshal(lotBytes, &lot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lot: %v", err)
+	}
+
+	return &lot, nil
+}
+
+// putLot 寫入批次記錄，流通量與註銷量由賬本記錄，不隨批次保存
+func putLot(ctx contractapi.TransactionContextInterface, lot *Lot) error {
+	lotKey, err := ctx.GetStub().CreateCompositeKey(creditLotPrefix, []string{lot.LotID})
+	if err != nil {
+		return fmt.Errorf("failed to create lot key: %v", err)
+	}
+
+	stored := *lot
+	stored.Supply, stored.Retired = 0, 0
+	lotBytes, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lot: %v", err)
+	}
+	err = ctx.GetStub().PutState(lotKey, lotBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put lot: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package chaincode
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// LP 份额代币符号前缀，交易对 CCT-STABLE 的份额代币为 LP-CCT-STABLE
+// 代币符号中不能包含分隔符，因此份额代币不会与普通代币重名
+const lpTokenPrefix = "LP"
+
+// TotalSupply 查询交易对份额代币的总量，包括永久锁定的份额
+func (e *Exchange) TotalSupply(ctx contractapi.TransactionContextInterface, pairID string) (string, error) {
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return "", err
+	}
+	return pool.TotalShares.String(), nil
+}
+
+// BalanceOf 查询账户持有的交易对份额代币，owner 可以是账户ID或后端用户ID
+func (e *Exchange) BalanceOf(ctx contractapi.TransactionContextInterface, pairID string, owner string) (string, error) {
+	owner, err := resolveAccount(ctx, owner)
+	if err != nil {
+		return "", err
+	}
+	position, err := getPosition(ctx, pairID, owner)
+	if err != nil {
+		return "", err
+	}
+	return position.Shares.String(), nil
+}
+
+// Transfer 将调用者的份额代币转给 to，转让的份额尚未结算的手续费随份额一并转移
+func (e *Exchange) Transfer(ctx contractapi.TransactionContextInterface, pairID string, to string, amount string) error {
+	value, err := parsePositiveAmount(amount, "amount")
+	if err != nil {
+		return err
+	}
+	from, err := getCallerAccount(ctx)
+	if err != nil {
+		return err
+	}
+	to, err = resolveAccount(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	return transferShares(ctx, pairID, from, to, value)
+}
+
+// Approve 授权 spender 代表调用者转出最多 amount 个份额代币，覆盖原有额度
+func (e *Exchange) Approve(ctx contractapi.TransactionContextInterface, pairID string, spender string, amount string) error {
+	value, err := parseAmount(amount, "amount")
+	if err != nil {
+		return err
+	}
+	allowance, err := toUint64(value)
+	if err != nil {
+		return err
+	}
+
+	// 授权不读取池子记录，避免与同一区块内的兑换产生读写冲突
+	return callerApprove(ctx, lpSymbol(pairID), spender, allowance)
+}
+
+// Allowance 查询 owner 授权给 spender 的剩余份额代币额度
+func (e *Exchange) Allowance(ctx contractapi.TransactionContextInterface, pairID string, owner string, spender string) (string, error) {
+	allowance, err := queryAllowance(ctx, lpSymbol(pairID), owner, spender)
+	if err != nil {
+		return "", err
+	}
+	return new(big.Int).SetUint64(allowance).String(), nil
+}
+
+// TransferFrom 调用者使用 from 的授权额度将份额代币转给 to
+func (e *Exchange) TransferFrom(ctx contractapi.TransactionContextInterface, pairID string, from string, to string, amount string) error {
+	value, err := parsePositiveAmount(amount, "amount")
+	if err != nil {
+		return err
+	}
+	spender, err := getCallerAccount(ctx)
+	if err != nil {
+		return err
+	}
+	from, err = resolveAccount(ctx, from)
+	if err != nil {
+		return err
+	}
+	to, err = resolveAccount(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	symbol := lpSymbol(pairID)
+	allowance, err := readAllowance(ctx, symbol, from, spender)
+	if err != nil {
+		return err
+	}
+	if new(big.Int).SetUint64(allowance).Cmp(value) < 0 {
+		return fmt.Errorf("spender %s has insufficient allowance from %s", spender, from)
+	}
+
+	err = transferShares(ctx, pairID, from, to, value)
+	if err != nil {
+		return err
+	}
+
+	// 扣减授权额度
+	return writeAllowance(ctx, symbol, from, spender, allowance-value.Uint64())
+}
+
+// transferShares 在两个账户之间转移份额代币，只读写双方的持仓，不读取池子记录
+func transferShares(ctx contractapi.TransactionContextInterface, pairID string, from string, to string, amount *big.Int) error {
+	if from == to {
+		return fmt.Errorf("cannot transfer to self")
+	}
+	if to == poolAccount(pairID) {
+		return fmt.Errorf("invalid recipient")
+	}
+	value, err := toUint64(amount)
+	if err != nil {
+		return err
+	}
+	fromPosition, err := getPosition(ctx, pairID, from)
+	if err != nil {
+		return err
+	}
+	if fromPosition.Shares.Cmp(amount) < 0 {
+		return fmt.Errorf("account %s has insufficient %s balance", from, lpSymbol(pairID))
+	}
+	toPosition, err := getPosition(ctx, pairID, to)
+	if err != nil {
+		return err
+	}
+
+	moveShares(fromPosition, toPosition, amount)
+	err = putPosition(ctx, fromPosition)
+	if err != nil {
+		return err
+	}
+	err = putPosition(ctx, toPosition)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "Transfer", TransferEvent{Token: lpSymbol(pairID), From: from, To: to, Value: value})
+}
+
+// lpSymbol 交易对份额代币的符号
+func lpSymbol(pairID string) string {
+	return lpTokenPrefix + pairSeparator + pairID
+}
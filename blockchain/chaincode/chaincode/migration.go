@@ -0,0 +1,658 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	migrationPrefix    = "migration"
+	namespaceMigration = "namespace-v1"
+	poolSplitMigration = "pool-split-v1"
+	cctSerialMigration = "cct-serials-v1"
+
+	legacyOwnerMapping = "owner"
+
+	legacyPoolKey        = "pool"
+	legacyTotalSupplyKey = "totalSupply"
+)
+
+// MigrationResult 记录一次迁移改写的数据条数
+type MigrationResult struct {
+	Balances   int `json:"balances"`
+	Allowances int `json:"allowances"`
+	Users      int `json:"users"`
+	Lots       int `json:"lots"`
+	Pools      int `json:"pools"`
+	Positions  int `json:"positions"`
+	Supply     int `json:"supply"`
+	Serials    int `json:"serials"`
+	Skipped    int `json:"skipped"`
+}
+
+// MapLegacyOwner 监管机构登记旧版余额与授权记录的持有者对应的账户，account 可以是账户ID或后端用户ID
+// 旧版持有者本身是已绑定的后端用户ID或已登记的账户ID时无需登记，MigrateState 会报告无法对应的持有者
+func (s *SmartContract) MapLegacyOwner(ctx contractapi.TransactionContextInterface, legacyOwner string, account string) error {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return err
+	}
+	if legacyOwner == "" {
+		return fmt.Errorf("legacy owner must not be empty")
+	}
+	account, err = resolveAccount(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	mappingKey, err := ctx.GetStub().CreateCompositeKey(migrationPrefix, []string{legacyOwnerMapping, legacyOwner})
+	if err != nil {
+		return fmt.Errorf("failed to create migration key: %v", err)
+	}
+	err = ctx.GetStub().PutState(mappingKey, []byte(account))
+	if err != nil {
+		return fmt.Errorf("failed to put legacy owner mapping: %v", err)
+	}
+	return nil
+}
+
+// MigrateState 将旧版直接以业务ID为键的数据迁移到组合键命名空间，只能由监管机构执行一次
+// 余额与授权的持有者改写为账户ID，存在无法对应到账户的持有者时迁移失败并列出这些持有者
+func (s *SmartContract) MigrateState(ctx contractapi.TransactionContextInterface) (*MigrationResult, error) {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	markerKey, err := migrationMarker(ctx, namespaceMigration)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MigrationResult{}
+	owners := newLegacyOwners()
+	err = migrateSimpleKeys(ctx, owners, result)
+	if err != nil {
+		return nil, err
+	}
+	err = migrateAllowances(ctx, owners, result)
+	if err != nil {
+		return nil, err
+	}
+	err = owners.check()
+	if err != nil {
+		return nil, err
+	}
+
+	err = putMigrationMarker(ctx, markerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// MigratePools 将池子记录中的 LP 份额与手续费检查点拆分为独立的持仓记录，只能由监管机构执行一次
+// 单池版本按 ETH/Token 记录的默认交易对同时转换为按交易对记录的格式，需在 MigrateState 之后执行
+// 单池版本的储备没有对应的托管余额，LP 以调用者证书字符串记录，无法对应到账户，
+// 因此升级为空池，原记录另存在迁移命名空间下供监管机构线下核对，丢弃的 LP 计入 Skipped
+func (e *Exchange) MigratePools(ctx contractapi.TransactionContextInterface) (*MigrationResult, error) {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	markerKey, err := migrationMarker(ctx, poolSplitMigration)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先收集再改写，避免边遍历边修改
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(poolPrefix, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	type storedPool struct {
+		pairID string
+		value  []byte
+	}
+	var pools []storedPool
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split pool key: %v", err)
+		}
+		pools = append(pools, storedPool{pairID: attributes[0], value: queryResponse.Value})
+	}
+
+	result := &MigrationResult{}
+	for _, stored := range pools {
+		pool, positions, dropped, err := splitPool(stored.pairID, stored.value)
+		if err != nil {
+			return nil, err
+		}
+		if pool == nil {
+			result.Skipped++
+			continue
+		}
+		if dropped > 0 {
+			err = archiveLegacyPool(ctx, stored.pairID, stored.value)
+			if err != nil {
+				return nil, err
+			}
+			result.Skipped += dropped
+		}
+
+		for _, position := range positions {
+			err = putPosition(ctx, position)
+			if err != nil {
+				return nil, err
+			}
+			result.Positions++
+		}
+		err = putPool(ctx, pool)
+		if err != nil {
+			return nil, err
+		}
+		result.Pools++
+	}
+
+	err = putMigrationMarker(ctx, markerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// MigrateSerials 为升级前铸造、尚无序列号的 CCT 补发序列号，只能由监管机构执行一次
+// 逐个持有 CCT 的账户（含池子与托管账户）按余额减去已持有序列号的数量补发，序列号归属该账户
+func (c *CarbonCoinToken) MigrateSerials(ctx contractapi.TransactionContextInterface) (*MigrationResult, error) {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	markerKey, err := migrationMarker(ctx, cctSerialMigration)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(balancePrefix, []string{cctSymbol})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	// 序列号在一笔交易中只能分配一次，先统计各账户缺少的数量
+	var owners []string
+	var missing []uint64
+	var total uint64
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var token Token
+		err = json.Unmarshal(queryResponse.Value, &token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal token: %v", err)
+		}
+		held, err := heldSerialRanges(ctx, token.Owner, cctSymbol)
+		if err != nil {
+			return nil, err
+		}
+		var serialized uint64
+		for _, serialRange := range held {
+			serialized += serialRange.End - serialRange.Start + 1
+		}
+		if token.Balance <= serialized {
+			continue
+		}
+		owners = append(owners, token.Owner)
+		missing = append(missing, token.Balance-serialized)
+		total, err = addUint64(total, token.Balance-serialized)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &MigrationResult{}
+	if total > 0 {
+		start, err := allocateSerials(ctx, total)
+		if err != nil {
+			return nil, err
+		}
+		for i, owner := range owners {
+			serialRange := &SerialRange{Start: start, End: start + missing[i] - 1, LotID: cctSymbol, Owner: owner, Status: SerialStatusActive}
+			err = putSerialRange(ctx, serialRange)
+			if err != nil {
+				return nil, err
+			}
+			start += missing[i]
+		}
+		result.Serials = int(total)
+	}
+
+	err = putMigrationMarker(ctx, markerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// migrationMarker 返回迁移标记的键，迁移已执行过时返回错误
+func migrationMarker(ctx contractapi.TransactionContextInterface, migration string) (string, error) {
+	markerKey, err := ctx.GetStub().CreateCompositeKey(migrationPrefix, []string{migration})
+	if err != nil {
+		return "", fmt.Errorf("failed to create migration key: %v", err)
+	}
+	markerBytes, err := ctx.GetStub().GetState(markerKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if markerBytes != nil {
+		return "", fmt.Errorf("migration %s has already been applied", migration)
+	}
+	return markerKey, nil
+}
+
+// putMigrationMarker 以交易时间写入迁移标记
+func putMigrationMarker(ctx contractapi.TransactionContextInterface, markerKey string) error {
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(markerKey, []byte(txTime))
+	if err != nil {
+		return fmt.Errorf("failed to put migration marker: %v", err)
+	}
+	return nil
+}
+
+// legacyOwners 缓存旧版持有者对应的账户，并收集无法对应的持有者
+type legacyOwners struct {
+	accounts map[string]string
+	unmapped map[string]bool
+}
+
+// newLegacyOwners 创建空的持有者映射
+func newLegacyOwners() *legacyOwners {
+	return &legacyOwners{accounts: make(map[string]string), unmapped: make(map[string]bool)}
+}
+
+// resolve 依次按监管机构登记的映射、别名与已登记账户查找旧版持有者的账户，找不到时记为无法对应并返回空串
+func (o *legacyOwners) resolve(ctx contractapi.TransactionContextInterface, owner string) (string, error) {
+	if account, ok := o.accounts[owner]; ok {
+		return account, nil
+	}
+	if o.unmapped[owner] {
+		return "", nil
+	}
+
+	mappingKey, err := ctx.GetStub().CreateCompositeKey(migrationPrefix, []string{legacyOwnerMapping, owner})
+	if err != nil {
+		return "", fmt.Errorf("failed to create migration key: %v", err)
+	}
+	mappingBytes, err := ctx.GetStub().GetState(mappingKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from world state: %v", err)
+	}
+	account := string(mappingBytes)
+	if account == "" {
+		account, err = resolveAccount(ctx, owner)
+		if err != nil {
+			o.unmapped[owner] = true
+			return "", nil
+		}
+	}
+	o.accounts[owner] = account
+	return account, nil
+}
+
+// check 存在无法对应的持有者时返回按字典序列出的错误
+func (o *legacyOwners) check() error {
+	if len(o.unmapped) == 0 {
+		return nil
+	}
+	owners := make([]string, 0, len(o.unmapped))
+	for owner := range o.unmapped {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	return fmt.Errorf("legacy owners without an account, map them with MapLegacyOwner first: %s", strings.Join(owners, ", "))
+}
+
+// migrateSimpleKeys 遍历所有非组合键，按记录内容判断类型后改写到对应命名空间
+// 余额按持有者对应的账户汇总后写入，多个旧版持有者对应同一账户时余额合并
+func migrateSimpleKeys(ctx contractapi.TransactionContextInterface, owners *legacyOwners, result *MigrationResult) error {
+	// 范围查询不会返回组合键，先收集再改写，避免边遍历边修改
+	iterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	type legacyRecord struct {
+		key   string
+		value []byte
+	}
+	var records []legacyRecord
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return err
+		}
+		records = append(records, legacyRecord{key: queryResponse.Key, value: queryResponse.Value})
+	}
+
+	balances := make(map[string]uint64)
+	for _, record := range records {
+		var newKey string
+		switch record.key {
+		case legacyPoolKey:
+			newKey, err = ctx.GetStub().CreateCompositeKey(poolPrefix, []string{defaultPairID})
+			result.Pools++
+		case legacyTotalSupplyKey:
+			newKey, err = ctx.GetStub().CreateCompositeKey(supplyPrefix, []string{cctSymbol})
+			result.Supply++
+		default:
+			var fields map[string]json.RawMessage
+			if json.Unmarshal(record.value, &fields) != nil {
+				result.Skipped++
+				continue
+			}
+			switch {
+			case fields["traceability_code"] != nil:
+				newKey, err = lotKey(ctx, record.key)
+				result.Lots++
+			case fields["userID"] != nil && fields["userType"] != nil:
+				newKey, err = userKey(ctx, record.key)
+				result.Users++
+			case fields["owner"] != nil && fields["balance"] != nil:
+				err = collectLegacyBalance(ctx, owners, balances, record.key, record.value)
+				if err != nil {
+					return err
+				}
+				result.Balances++
+				continue
+			default:
+				result.Skipped++
+				continue
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create key for %s: %v", record.key, err)
+		}
+
+		err = ctx.GetStub().PutState(newKey, record.value)
+		if err != nil {
+			return fmt.Errorf("failed to put %s: %v", record.key, err)
+		}
+		err = ctx.GetStub().DelState(record.key)
+		if err != nil {
+			return fmt.Errorf("failed to delete %s: %v", record.key, err)
+		}
+	}
+
+	// 按账户排序，保证各背书节点写入顺序一致
+	accounts := make([]string, 0, len(balances))
+	for account := range balances {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	for _, account := range accounts {
+		balance, err := readBalance(ctx, cctSymbol, account)
+		if err != nil {
+			return err
+		}
+		balance, err = addUint64(balance, balances[account])
+		if err != nil {
+			return err
+		}
+		err = writeBalance(ctx, cctSymbol, account, balance)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collectLegacyBalance 删除旧版余额记录，并把余额累加到持有者对应的账户，持有者无法对应时只记录
+func collectLegacyBalance(ctx contractapi.TransactionContextInterface, owners *legacyOwners, balances map[string]uint64, key string, value []byte) error {
+	var token Token
+	err := json.Unmarshal(value, &token)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal legacy balance %s: %v", key, err)
+	}
+	account, err := owners.resolve(ctx, key)
+	if err != nil || account == "" {
+		return err
+	}
+	balances[account], err = addUint64(balances[account], token.Balance)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %v", key, err)
+	}
+	return nil
+}
+
+// migrateAllowances 将 allowance~owner~spender 改写为 allowance~CCT~owner~spender，授权双方改写为账户ID
+// 多条旧版授权对应同一对账户时额度合并
+func migrateAllowances(ctx contractapi.TransactionContextInterface, owners *legacyOwners, result *MigrationResult) error {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(allowancePrefix, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	type legacyAllowance struct {
+		key        string
+		attributes []string
+		value      []byte
+	}
+	var allowances []legacyAllowance
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return err
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return fmt.Errorf("failed to split allowance key: %v", err)
+		}
+		if len(attributes) == 2 {
+			allowances = append(allowances, legacyAllowance{key: queryResponse.Key, attributes: attributes, value: queryResponse.Value})
+		}
+	}
+
+	type allowancePair struct {
+		owner   string
+		spender string
+	}
+	values := make(map[allowancePair]uint64)
+	for _, allowance := range allowances {
+		owner, err := owners.resolve(ctx, allowance.attributes[0])
+		if err != nil {
+			return err
+		}
+		spender, err := owners.resolve(ctx, allowance.attributes[1])
+		if err != nil {
+			return err
+		}
+		if owner == "" || spender == "" {
+			continue
+		}
+		var legacy Allowance
+		err = json.Unmarshal(allowance.value, &legacy)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal allowance: %v", err)
+		}
+		pair := allowancePair{owner: owner, spender: spender}
+		values[pair], err = addUint64(values[pair], legacy.Value)
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().DelState(allowance.key)
+		if err != nil {
+			return fmt.Errorf("failed to delete allowance: %v", err)
+		}
+		result.Allowances++
+	}
+
+	pairs := make([]allowancePair, 0, len(values))
+	for pair := range values {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].owner != pairs[j].owner {
+			return pairs[i].owner < pairs[j].owner
+		}
+		return pairs[i].spender < pairs[j].spender
+	})
+	for _, pair := range pairs {
+		err = writeAllowance(ctx, cctSymbol, pair.owner, pair.spender, values[pair])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// legacyPool 单池版本的池子记录，ETH 一侧为 STABLE，Token 一侧为 CCT
+type legacyPool struct {
+	ETHReserve      *big.Int            `json:"ethReserve"`
+	TokenReserve    *big.Int            `json:"tokenReserve"`
+	ETHFeeReserve   *big.Int            `json:"ethFeeReserve"`
+	TokenFeeReserve *big.Int            `json:"tokenFeeReserve"`
+	SwapFeeNum      uint64              `json:"swapFeeNum"`
+	SwapFeeDenom    uint64              `json:"swapFeeDenom"`
+	TotalShares     *big.Int            `json:"totalShares"`
+	LPShares        map[string]*big.Int `json:"lpShares"`
+}
+
+// legacyFeeCheckpoint 持仓拆分前保存在池子记录中的 LP 手续费检查点
+type legacyFeeCheckpoint struct {
+	Growth0 *big.Int `json:"growth0"`
+	Growth1 *big.Int `json:"growth1"`
+	Owed0   *big.Int `json:"owed0"`
+	Owed1   *big.Int `json:"owed1"`
+}
+
+// splitPool 解析持仓拆分前的池子记录，返回新格式的池子、各 LP 的持仓与丢弃的单池版本 LP 数量，已是新格式时返回 nil
+func splitPool(pairID string, poolBytes []byte) (*Pool, []*Position, int, error) {
+	var stored struct {
+		Pool
+		LPShares map[string]*big.Int             `json:"lpShares"`
+		LPFees   map[string]*legacyFeeCheckpoint `json:"lpFees"`
+	}
+	err := json.Unmarshal(poolBytes, &stored)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to unmarshal pool: %v", err)
+	}
+
+	pool := &stored.Pool
+	if stored.Token0 == "" {
+		legacy, dropped, err := upgradeLegacyPool(pairID, poolBytes)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		initFees(legacy)
+		return legacy, []*Position{}, dropped, nil
+	} else if stored.LPShares == nil {
+		return nil, nil, 0, nil
+	}
+	pool.TotalShares = orZero(pool.TotalShares)
+	initFees(pool)
+
+	positions := []*Position{}
+	for owner, shares := range stored.LPShares {
+		position := newPosition(pool.PairID, owner)
+		position.Shares = orZero(shares)
+		if checkpoint := stored.LPFees[owner]; checkpoint != nil {
+			position.Growth0 = orZero(checkpoint.Growth0)
+			position.Growth1 = orZero(checkpoint.Growth1)
+			position.Owed0 = orZero(checkpoint.Owed0)
+			position.Owed1 = orZero(checkpoint.Owed1)
+		}
+		positions = append(positions, position)
+	}
+	// 份额已全部转出但仍有未领取手续费的 LP
+	for owner, checkpoint := range stored.LPFees {
+		if _, ok := stored.LPShares[owner]; ok {
+			continue
+		}
+		position := newPosition(pool.PairID, owner)
+		position.Growth0 = orZero(checkpoint.Growth0)
+		position.Growth1 = orZero(checkpoint.Growth1)
+		position.Owed0 = orZero(checkpoint.Owed0)
+		position.Owed1 = orZero(checkpoint.Owed1)
+		positions = append(positions, position)
+	}
+	// 按账户排序，保证各背书节点写入顺序一致
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Owner < positions[j].Owner })
+
+	return pool, positions, 0, nil
+}
+
+// upgradeLegacyPool 将单池版本的记录转换为按交易对记录的空池，保留手续费设置，返回丢弃的 LP 数量
+func upgradeLegacyPool(pairID string, poolBytes []byte) (*Pool, int, error) {
+	if pairID != defaultPairID {
+		return nil, 0, fmt.Errorf("the pool %s has an unknown format", pairID)
+	}
+
+	var legacy legacyPool
+	err := json.Unmarshal(poolBytes, &legacy)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal legacy pool: %v", err)
+	}
+
+	pool := &Pool{
+		PairID:       defaultPairID,
+		Token0:       cctSymbol,
+		Token1:       stableSymbol,
+		Reserve0:     big.NewInt(0),
+		Reserve1:     big.NewInt(0),
+		SwapFeeNum:   legacy.SwapFeeNum,
+		SwapFeeDenom: legacy.SwapFeeDenom,
+		TotalShares:  big.NewInt(0),
+	}
+
+	return pool, len(legacy.LPShares), nil
+}
+
+// archiveLegacyPool 将单池版本的原始记录另存在迁移命名空间下
+func archiveLegacyPool(ctx contractapi.TransactionContextInterface, pairID string, poolBytes []byte) error {
+	archiveKey, err := ctx.GetStub().CreateCompositeKey(migrationPrefix, []string{poolSplitMigration, pairID})
+	if err != nil {
+		return fmt.Errorf("failed to create migration key: %v", err)
+	}
+	err = ctx.GetStub().PutState(archiveKey, poolBytes)
+	if err != nil {
+		return fmt.Errorf("failed to archive legacy pool: %v", err)
+	}
+	return nil
+}
+
+// orZero 旧记录中缺失的金额按0处理
+func orZero(value *big.Int) *big.Int {
+	if value == nil {
+		return big.NewInt(0)
+	}
+	return value
+}
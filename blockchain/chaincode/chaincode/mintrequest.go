@@ -0,0 +1,269 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 鑄幣申請狀態
+const (
+	MintStatusPending  = "PENDING"
+	MintStatusApproved = "APPROVED"
+	MintStatusRejected = "REJECTED"
+
+	mintRequestPrefix          = "mintRequest"
+	mintRequestStatusIndex     = "mintRequestStatus"
+	mintRequestEnterpriseIndex = "mintRequestEnterprise"
+)
+
+// MintRequest 定義鑄幣申請結構
+type MintRequest struct {
+	RequestID    string `json:"requestID"`
+	Enterprise   string `json:"enterprise"`   // 申請企業賬戶
+	Amount       uint64 `json:"amount"`       // 申請鑄造的 CCT 數量
+	EvidenceHash string `json:"evidenceHash"` // 綠色活動證明材料的哈希
+	Status       string `json:"status"`
+	Reason       string `json:"reason"`   // 駁回原因
+	Reviewer     string `json:"reviewer"` // 審核的監管機構賬戶
+	RequestTime  string `json:"requestTime"`
+	ReviewTime   string `json:"reviewTime"`
+	Serials      string `json:"serials,omitempty" metadata:",optional"` // 批准時分配的序列號區間
+}
+
+// MintRequestEvent 鑄幣申請狀態變更事件
+type MintRequestEvent struct {
+	RequestID  string `json:"requestID"`
+	Enterprise string `json:"enterprise"`
+	Amount     uint64 `json:"amount"`
+	Status     string `json:"status"`
+}
+
+// RequestMint 企業提交鑄幣申請，返回申請編號
+func (c *CarbonCoinToken) RequestMint(ctx contractapi.TransactionContextInterface, amount uint64, evidenceHash string) (string, error) {
+	if amount == 0 {
+		return "", fmt.Errorf("mint amount must be greater than 0")
+	}
+	if evidenceHash == "" {
+		return "", fmt.Errorf("evidence hash must not be empty")
+	}
+
+	enterprise, err := getCallerAccount(ctx)
+	if err != nil {
+		return "", err
+	}
+	requestTime, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// 以交易ID作為申請編號，保證唯一
+	request := MintRequest{
+		RequestID:    ctx.GetStub().GetTxID(),
+		Enterprise:   enterprise,
+		Amount:       amount,
+		EvidenceHash: evidenceHash,
+		Status:       MintStatusPending,
+		RequestTime:  requestTime,
+	}
+	err = putMintRequest(ctx, &request)
+	if err != nil {
+		return "", err
+	}
+	err = putIndex(ctx, mintRequestStatusIndex, []string{request.Status, request.RequestID})
+	if err != nil {
+		return "", err
+	}
+	err = putIndex(ctx, mintRequestEnterpriseIndex, []string{request.Enterprise, request.RequestID})
+	if err != nil {
+		return "", err
+	}
+
+	err = emitEvent(ctx, "MintRequested", MintRequestEvent{
+		RequestID:  request.RequestID,
+		Enterprise: request.Enterprise,
+		Amount:     request.Amount,
+		Status:     request.Status,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return request.RequestID, nil
+}
+
+// ApproveMint 監管機構批准鑄幣申請，並為企業鑄造代幣，每次鑄幣為企業分配一段連續的序列號並記錄在申請中
+func (c *CarbonCoinToken) ApproveMint(ctx contractapi.TransactionContextInterface, requestID string) error {
+	request, err := c.reviewMintRequest(ctx, requestID, MintStatusApproved, "")
+	if err != nil {
+		return err
+	}
+
+	serials, err := issueCCTSerials(ctx, request.Enterprise, request.Amount)
+	if err != nil {
+		return err
+	}
+	request.Serials = formatSerialRange(serials)
+	err = putMintRequest(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	// mint 會發出 Transfer 事件，與申請狀態一同記錄
+	return mint(ctx, cctSymbol, request.Enterprise, request.Amount)
+}
+
+// RejectMint 監管機構駁回鑄幣申請
+func (c *CarbonCoinToken) RejectMint(ctx contractapi.TransactionContextInterface, requestID string, reason string) error {
+	if reason == "" {
+		return fmt.Errorf("reject reason must not be empty")
+	}
+
+	request, err := c.reviewMintRequest(ctx, requestID, MintStatusRejected, reason)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "MintRejected", MintRequestEvent{
+		RequestID:  request.RequestID,
+		Enterprise: request.Enterprise,
+		Amount:     request.Amount,
+		Status:     request.Status,
+	})
+}
+
+// GetMintRequest 查詢單個鑄幣申請
+func (c *CarbonCoinToken) GetMintRequest(ctx contractapi.TransactionContextInterface, requestID string) (*MintRequest, error) {
+	return getMintRequest(ctx, requestID)
+}
+
+// GetMintRequestsByStatus 按狀態查詢鑄幣申請
+func (c *CarbonCoinToken) GetMintRequestsByStatus(ctx contractapi.TransactionContextInterface, status string) ([]*MintRequest, error) {
+	return queryMintRequests(ctx, mintRequestStatusIndex, status)
+}
+
+// GetMintRequestsByEnterprise 按企業查詢鑄幣申請
+func (c *CarbonCoinToken) GetMintRequestsByEnterprise(ctx contractapi.TransactionContextInterface, enterprise string) ([]*MintRequest, error) {
+	enterprise, err := resolveAccount(ctx, enterprise)
+	if err != nil {
+		return nil, err
+	}
+	return queryMintRequests(ctx, mintRequestEnterpriseIndex, enterprise)
+}
+
+// reviewMintRequest 監管機構審核待處理的申請並更新狀態索引
+func (c *CarbonCoinToken) reviewMintRequest(ctx contractapi.TransactionContextInterface, requestID string, status string, reason string) (*MintRequest, error) {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := getMintRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != MintStatusPending {
+		return nil, fmt.Errorf("mint request %s is already %s", requestID, request.Status)
+	}
+
+	reviewer, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reviewTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = delIndex(ctx, mintRequestStatusIndex, []string{request.Status, request.RequestID})
+	if err != nil {
+		return nil, err
+	}
+	request.Status = status
+	request.Reason = reason
+	request.Reviewer = reviewer
+	request.ReviewTime = reviewTime
+	err = putMintRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	err = putIndex(ctx, mintRequestStatusIndex, []string{request.Status, request.RequestID})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// getMintRequest 讀取鑄幣申請
+func getMintRequest(ctx contractapi.TransactionContextInterface, requestID string) (*MintRequest, error) {
+	requestKey, err := ctx.GetStub().CreateCompositeKey(mintRequestPrefix, []string{requestID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mint request key: %v", err)
+	}
+
+	requestBytes, err := ctx.GetStub().GetState(requestKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if requestBytes == nil {
+		return nil, fmt.Errorf("the mint request %s does not exist", requestID)
+	}
+
+	var request MintRequest
+	err = json.Unmarshal(requestBytes, &request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal mint request: %v", err)
+	}
+
+	return &request, nil
+}
+
+// putMintRequest 寫入鑄幣申請
+func putMintRequest(ctx contractapi.TransactionContextInterface, request *MintRequest) error {
+	requestKey, err := ctx.GetStub().CreateCompositeKey(mintRequestPrefix, []string{request.RequestID})
+	if err != nil {
+		return fmt.Errorf("failed to create mint request key: %v", err)
+	}
+
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal mint request: %v", err)
+	}
+	err = ctx.GetStub().PutState(requestKey, requestBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put mint request: %v", err)
+	}
+
+	return nil
+}
+
+// queryMintRequests 通過索引查詢鑄幣申請，索引的最後一個屬性為申請編號
+func queryMintRequests(ctx contractapi.TransactionContextInterface, index string, value string) ([]*MintRequest, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{value})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	requests := []*MintRequest{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split index key: %v", err)
+		}
+
+		request, err := getMintRequest(ctx, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
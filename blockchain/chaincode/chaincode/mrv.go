@@ -0,0 +1,579 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 排放报告状态
+const (
+	ReportStatusSubmitted      = "SUBMITTED"       // 已提交，等待核查
+	ReportStatusFindingsRaised = "FINDINGS_RAISED" // 核查机构提出问题，企业修改后重新提交
+	ReportStatusVerified       = "VERIFIED"        // 核查机构出具核查声明，等待监管机构审定
+	ReportStatusApproved       = "APPROVED"        // 监管机构审定，报告锁定
+)
+
+// 世界状态与私有数据命名空间
+const (
+	reportPrefix       = "mrvReport"
+	reportAccountIndex = "mrvReportAccount"
+	reportDataPrefix   = "mrvData"
+)
+
+// 排放明细通过 transient 传入，不进入交易提案的参数，也不会写入区块
+const transientReport = "report"
+
+// 排放范围：范围一为直接排放，范围二为外购电力与热力的间接排放，范围三为价值链上的其他间接排放
+const (
+	minEmissionScope = 1
+	maxEmissionScope = 3
+)
+
+// MRV 定义排放监测、报告与核查合约：企业提交年度排放报告，核查机构核查后出具声明或提出问题，监管机构审定后报告锁定
+// 排放明细保存在企业所在机构的隐式私有数据集合中，公开账本只记录明细的哈希与证据文件的哈希
+type MRV struct {
+	contractapi.Contract
+}
+
+// EmissionsReport 定义排放报告的公开部分，每个账户每个年度一份，修改与审批都写在同一个键上，可通过 GetReportHistory 追溯
+type EmissionsReport struct {
+	ReportID       string   `json:"reportId"`
+	Account        string   `json:"account"`
+	MSPID          string   `json:"mspId"`
+	Year           int      `json:"year"`
+	Collection     string   `json:"collection"`
+	DataHash       string   `json:"dataHash"`       // 私有排放明细的 SHA-256
+	EvidenceHashes []string `json:"evidenceHashes"` // 监测记录、发票等证据文件的 SHA-256
+	Version        int      `json:"version"`        // 每次修改后递增
+	Status         string   `json:"status"`
+	Verifier       string   `json:"verifier,omitempty" metadata:",optional"`
+	VerifierMSPID  string   `json:"verifierMspId,omitempty" metadata:",optional"`
+	Statement      string   `json:"statement,omitempty" metadata:",optional"` // 核查声明
+	Findings       []string `json:"findings,omitempty" metadata:",optional"`  // 核查发现的问题
+	SubmitTime     string   `json:"submitTime"`
+	UpdateTime     string   `json:"updateTime"`
+	ApproveTime    string   `json:"approveTime,omitempty" metadata:",optional"`
+}
+
+// EmissionLine 定义一条排放明细，Emissions 单位为吨 CO2e
+type EmissionLine struct {
+	Scope     int    `json:"scope"`
+	Source    string `json:"source"` // 排放源，如燃煤锅炉、外购电力
+	Emissions uint64 `json:"emissions"`
+}
+
+// EmissionsData 定义私有的排放明细，包含报告ID与版本，哈希只对应报告的某一版本，Salt 防止通过穷举排放量反推哈希
+type EmissionsData struct {
+	ReportID string          `json:"reportId"`
+	Version  int             `json:"version"`
+	Lines    []*EmissionLine `json:"lines"`
+	Total    uint64          `json:"total"`
+	Salt     string          `json:"salt"`
+}
+
+// ReportHistoryRecord 定义排放报告的一条历史记录
+type ReportHistoryRecord struct {
+	Record    *EmissionsReport `json:"record"`
+	TxId      string           `json:"txId"`
+	Timestamp string           `json:"timestamp"`
+	IsDelete  bool             `json:"isDelete"`
+}
+
+// SubmitReport 企业提交年度排放报告，排放明细通过 transient 的 report 以 EmissionLine 数组传入，随机盐值通过 transient 的 salt 传入
+// evidenceHashes 为证据文件的 SHA-256 十六进制摘要
+func (m *MRV) SubmitReport(ctx contractapi.TransactionContextInterface, year int, evidenceHashes []string) (*EmissionsReport, error) {
+	now, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	if year <= 0 || year > time.Unix(now.Seconds, 0).UTC().Year() {
+		return nil, fmt.Errorf("invalid reporting year %d", year)
+	}
+	err = checkEvidenceHashes(evidenceHashes)
+	if err != nil {
+		return nil, err
+	}
+	account, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+
+	reportID := fmt.Sprintf("%s:%04d", account, year)
+	existing, err := readReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("the report for %d already exists, amend report %s instead", year, reportID)
+	}
+	submitTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &EmissionsReport{
+		ReportID:       reportID,
+		Account:        account,
+		MSPID:          mspID,
+		Year:           year,
+		Collection:     "_implicit_org_" + mspID,
+		EvidenceHashes: evidenceHashes,
+		Version:        1,
+		Status:         ReportStatusSubmitted,
+		SubmitTime:     submitTime,
+		UpdateTime:     submitTime,
+	}
+	report.DataHash, err = putEmissionsData(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+	err = putReport(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+	err = putIndex(ctx, reportAccountIndex, []string{account, reportID})
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "ReportSubmitted", report)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// AmendReport 企业在核查前或核查机构提出问题后修改报告，新的排放明细与盐值同样通过 transient 的 report、salt 传入
+// 修改后报告回到待核查状态，上一版本的核查结论保留在历史记录中
+func (m *MRV) AmendReport(ctx contractapi.TransactionContextInterface, reportID string, evidenceHashes []string) (*EmissionsReport, error) {
+	report, err := getReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if caller != report.Account {
+		return nil, fmt.Errorf("caller is not the submitter of report %s", reportID)
+	}
+	if report.Status != ReportStatusSubmitted && report.Status != ReportStatusFindingsRaised {
+		return nil, fmt.Errorf("report %s is already %s", reportID, report.Status)
+	}
+	err = checkEvidenceHashes(evidenceHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Version++
+	report.EvidenceHashes = evidenceHashes
+	report.Status = ReportStatusSubmitted
+	report.Verifier, report.VerifierMSPID, report.Statement, report.Findings = "", "", "", nil
+	report.DataHash, err = putEmissionsData(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+	err = updateReport(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "ReportSubmitted", report)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// AttestReport 核查机构对当前版本的报告出具核查声明，核查机构不能核查自己提交的报告
+func (m *MRV) AttestReport(ctx contractapi.TransactionContextInterface, reportID string, statement string) (*EmissionsReport, error) {
+	if statement == "" {
+		return nil, fmt.Errorf("statement must not be empty")
+	}
+	report, err := getVerifiableReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Status = ReportStatusVerified
+	report.Statement = statement
+	err = updateReport(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "ReportAttested", report)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// RaiseFindings 核查机构对报告提出问题，企业须修改后重新提交
+func (m *MRV) RaiseFindings(ctx contractapi.TransactionContextInterface, reportID string, findings []string) (*EmissionsReport, error) {
+	if len(findings) == 0 {
+		return nil, fmt.Errorf("findings must not be empty")
+	}
+	report, err := getVerifiableReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Status = ReportStatusFindingsRaised
+	report.Findings = findings
+	err = updateReport(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "FindingsRaised", report)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ApproveReport 监管机构审定已核查的报告，审定后报告锁定，不能再修改
+func (m *MRV) ApproveReport(ctx contractapi.TransactionContextInterface, reportID string) (*EmissionsReport, error) {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report, err := getReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	if report.Status != ReportStatusVerified {
+		return nil, fmt.Errorf("report %s is %s, only verified reports can be approved", reportID, report.Status)
+	}
+
+	report.Status = ReportStatusApproved
+	report.ApproveTime, err = getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = updateReport(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "ReportApproved", report)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// AccreditVerifier 监管机构认可核查机构所在的 MSP，该 MSP 下持有 role=verifier 属性的身份才能核查报告
+func (m *MRV) AccreditVerifier(ctx contractapi.TransactionContextInterface, mspID string) error {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return err
+	}
+	return setVerifierAccreditation(ctx, mspID, true)
+}
+
+// RevokeVerifier 监管机构撤销对核查机构 MSP 的认可
+func (m *MRV) RevokeVerifier(ctx contractapi.TransactionContextInterface, mspID string) error {
+	err := requireRegulator(ctx)
+	if err != nil {
+		return err
+	}
+	return setVerifierAccreditation(ctx, mspID, false)
+}
+
+// GetReport 查询排放报告的公开部分
+func (m *MRV) GetReport(ctx contractapi.TransactionContextInterface, reportID string) (*EmissionsReport, error) {
+	return getReport(ctx, reportID)
+}
+
+// GetReportsByAccount 查询账户提交的全部排放报告，account 可以是账户ID或后端用户ID
+func (m *MRV) GetReportsByAccount(ctx contractapi.TransactionContextInterface, account string) ([]*EmissionsReport, error) {
+	account, err := resolveAccount(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	reportIDs, err := queryIndex(ctx, reportAccountIndex, account)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []*EmissionsReport{}
+	for _, reportID := range reportIDs {
+		report, err := getReport(ctx, reportID)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// GetReportData 查询报告当前版本的排放明细，只有提交企业、核查机构与监管机构可以查询，且须由企业所在机构的节点背书
+func (m *MRV) GetReportData(ctx contractapi.TransactionContextInterface, reportID string) (*EmissionsData, error) {
+	report, err := getReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if caller != report.Account && requireVerifier(ctx) != nil && requireRegulator(ctx) != nil {
+		return nil, fmt.Errorf("caller is not allowed to read the data of report %s", reportID)
+	}
+	return getEmissionsData(ctx, report)
+}
+
+// GetReportHistory 查询排放报告的提交、修改、核查与审定历史
+func (m *MRV) GetReportHistory(ctx contractapi.TransactionContextInterface, reportID string) ([]*ReportHistoryRecord, error) {
+	reportKey, err := ctx.GetStub().CreateCompositeKey(reportPrefix, []string{reportID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report key: %v", err)
+	}
+	iterator, err := ctx.GetStub().GetHistoryForKey(reportKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report history: %v", err)
+	}
+	defer iterator.Close()
+
+	timeLocation, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load time location: %v", err)
+	}
+	records := []*ReportHistoryRecord{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		report := &EmissionsReport{ReportID: reportID}
+		if len(response.Value) > 0 {
+			err = json.Unmarshal(response.Value, report)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal report: %v", err)
+			}
+		}
+		timestamp, err := ptypes.Timestamp(response.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, &ReportHistoryRecord{
+			Record:    report,
+			TxId:      response.TxId,
+			Timestamp: timestamp.In(timeLocation).Format("2006-01-02 15:04:05"),
+			IsDelete:  response.IsDelete,
+		})
+	}
+	return records, nil
+}
+
+// getVerifiableReport 读取待核查的报告并记录核查机构，调用者须为核查机构且与提交企业不属于同一 MSP
+func getVerifiableReport(ctx contractapi.TransactionContextInterface, reportID string) (*EmissionsReport, error) {
+	err := requireVerifier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report, err := getReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	if report.Status != ReportStatusSubmitted {
+		return nil, fmt.Errorf("report %s is %s, only submitted reports can be verified", reportID, report.Status)
+	}
+	verifier, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	verifierMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	if verifier == report.Account || verifierMSPID == report.MSPID {
+		return nil, fmt.Errorf("verifier must not verify a report submitted by its own organization")
+	}
+
+	report.Verifier = verifier
+	report.VerifierMSPID = verifierMSPID
+	return report, nil
+}
+
+// checkEvidenceHashes 校验证据文件哈希为 SHA-256 十六进制摘要
+func checkEvidenceHashes(evidenceHashes []string) error {
+	if len(evidenceHashes) == 0 {
+		return fmt.Errorf("at least one evidence hash is required")
+	}
+	for _, evidenceHash := range evidenceHashes {
+		digest, err := hex.DecodeString(evidenceHash)
+		if err != nil || len(digest) != sha256.Size {
+			return fmt.Errorf("invalid evidence hash %q", evidenceHash)
+		}
+	}
+	return nil
+}
+
+// readReportTransient 读取并校验 transient 中的排放明细与盐值
+func readReportTransient(ctx contractapi.TransactionContextInterface) ([]*EmissionLine, string, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get transient: %v", err)
+	}
+	reportBytes, ok := transientMap[transientReport]
+	if !ok {
+		return nil, "", fmt.Errorf("transient field %s is required", transientReport)
+	}
+	salt := string(transientMap[transientSalt])
+	if salt == "" {
+		return nil, "", fmt.Errorf("transient field %s must not be empty", transientSalt)
+	}
+	var lines []*EmissionLine
+	err = json.Unmarshal(reportBytes, &lines)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal emission lines: %v", err)
+	}
+	if len(lines) == 0 {
+		return nil, "", fmt.Errorf("at least one emission line is required")
+	}
+	for _, line := range lines {
+		if line == nil || line.Scope < minEmissionScope || line.Scope > maxEmissionScope {
+			return nil, "", fmt.Errorf("emission scope must be between %d and %d", minEmissionScope, maxEmissionScope)
+		}
+		if line.Source == "" {
+			return nil, "", fmt.Errorf("emission source must not be empty")
+		}
+	}
+	return lines, salt, nil
+}
+
+// getEmissionsData 从私有数据集合读取排放明细，并校验与公开的哈希一致
+func getEmissionsData(ctx contractapi.TransactionContextInterface, report *EmissionsReport) (*EmissionsData, error) {
+	dataKey, err := ctx.GetStub().CreateCompositeKey(reportDataPrefix, []string{report.ReportID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report data key: %v", err)
+	}
+	dataBytes, err := ctx.GetStub().GetPrivateData(report.Collection, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from collection %s: %v", report.Collection, err)
+	}
+	if dataBytes == nil {
+		return nil, fmt.Errorf("the data of report %s is not available on this peer", report.ReportID)
+	}
+	if reportDataHash(dataBytes) != report.DataHash {
+		return nil, fmt.Errorf("the data of report %s does not match the public hash", report.ReportID)
+	}
+
+	var data EmissionsData
+	err = json.Unmarshal(dataBytes, &data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal report data: %v", err)
+	}
+	return &data, nil
+}
+
+// putEmissionsData 读取 transient 中的排放明细，汇总后写入私有数据集合，返回其哈希
+func putEmissionsData(ctx contractapi.TransactionContextInterface, report *EmissionsReport) (string, error) {
+	lines, salt, err := readReportTransient(ctx)
+	if err != nil {
+		return "", err
+	}
+	data := &EmissionsData{ReportID: report.ReportID, Version: report.Version, Lines: lines, Salt: salt}
+	for _, line := range lines {
+		data.Total, err = addUint64(data.Total, line.Emissions)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	dataKey, err := ctx.GetStub().CreateCompositeKey(reportDataPrefix, []string{report.ReportID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create report data key: %v", err)
+	}
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal report data: %v", err)
+	}
+	err = ctx.GetStub().PutPrivateData(report.Collection, dataKey, dataBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to put report data: %v", err)
+	}
+	return reportDataHash(dataBytes), nil
+}
+
+// reportDataHash 计算排放明细的 SHA-256 摘要
+func reportDataHash(dataBytes []byte) string {
+	digest := sha256.Sum256(dataBytes)
+	return hex.EncodeToString(digest[:])
+}
+
+// updateReport 更新修改时间后写入报告
+func updateReport(ctx contractapi.TransactionContextInterface, report *EmissionsReport) error {
+	var err error
+	report.UpdateTime, err = getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	return putReport(ctx, report)
+}
+
+// getReport 读取排放报告，不存在时返回错误
+func getReport(ctx contractapi.TransactionContextInterface, reportID string) (*EmissionsReport, error) {
+	report, err := readReport(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, fmt.Errorf("the report %s does not exist", reportID)
+	}
+	return report, nil
+}
+
+// readReport 读取排放报告，不存在时返回nil
+func readReport(ctx contractapi.TransactionContextInterface, reportID string) (*EmissionsReport, error) {
+	reportKey, err := ctx.GetStub().CreateCompositeKey(reportPrefix, []string{reportID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report key: %v", err)
+	}
+	reportBytes, err := ctx.GetStub().GetState(reportKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if reportBytes == nil {
+		return nil, nil
+	}
+
+	var report EmissionsReport
+	err = json.Unmarshal(reportBytes, &report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal report: %v", err)
+	}
+	return &report, nil
+}
+
+// putReport 写入排放报告
+func putReport(ctx contractapi.TransactionContextInterface, report *EmissionsReport) error {
+	reportKey, err := ctx.GetStub().CreateCompositeKey(reportPrefix, []string{report.ReportID})
+	if err != nil {
+		return fmt.Errorf("failed to create report key: %v", err)
+	}
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %v", err)
+	}
+	err = ctx.GetStub().PutState(reportKey, reportBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put report: %v", err)
+	}
+	return nil
+}
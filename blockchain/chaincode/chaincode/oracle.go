@@ -0,0 +1,243 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 价格观测存放在 twapObservation~交易对~序号 组合键下，每个交易对最多保留 maxObservations 条，写满后循环覆盖最旧的观测
+const (
+	observationPrefix = "twapObservation"
+	maxObservations   = 1024
+)
+
+// 累计价格按 2^112 放大保存，避免储备比值被取整为0
+var priceScale = new(big.Int).Lsh(big.NewInt(1), 112)
+
+// Observation 定义一次价格观测，记录观测时刻两侧的累计价格
+type Observation struct {
+	Timestamp        int64    `json:"timestamp"`
+	PriceCumulative0 *big.Int `json:"priceCumulative0"`
+	PriceCumulative1 *big.Int `json:"priceCumulative1"`
+}
+
+// TWAP 定义时间加权平均价格查询结果
+type TWAP struct {
+	PairID      string `json:"pairId"`
+	Token0      string `json:"token0"`
+	Token1      string `json:"token1"`
+	Price0      string `json:"price0"` // 每单位 Token0 可换得的 Token1
+	Price1      string `json:"price1"` // 每单位 Token1 可换得的 Token0
+	WindowStart int64  `json:"windowStart"`
+	WindowEnd   int64  `json:"windowEnd"`
+}
+
+// GetTWAP 查询交易对最近 windowSeconds 秒的时间加权平均价格（不含手续费），窗口早于保留的最旧观测时查询失败
+func (e *Exchange) GetTWAP(ctx contractapi.TransactionContextInterface, pairID string, windowSeconds int64) (*TWAP, error) {
+	if windowSeconds <= 0 {
+		return nil, fmt.Errorf("windowSeconds must be greater than 0")
+	}
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return nil, err
+	}
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+
+	price0, price1, err := twap(ctx, pool, txtime.Seconds, windowSeconds)
+	if err != nil {
+		return nil, err
+	}
+	return &TWAP{
+		PairID:      pool.PairID,
+		Token0:      pool.Token0,
+		Token1:      pool.Token1,
+		Price0:      price0.FloatString(priceDecimals),
+		Price1:      price1.FloatString(priceDecimals),
+		WindowStart: txtime.Seconds - windowSeconds,
+		WindowEnd:   txtime.Seconds,
+	}, nil
+}
+
+// observe 在储备变动前累加价格并生成观测，需在修改储备之前调用，观测随 putPool 一起写入
+// 每个交易时间戳只有第一笔改动池子的交易累加，之前的价格按持续时间计入，同一时刻内的操纵不会进入累计值
+func observe(ctx contractapi.TransactionContextInterface, pool *Pool) error {
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	now := txtime.Seconds
+	if pool.ObservationCount > 0 && now <= pool.LastObserved {
+		return nil
+	}
+
+	pool.PriceCumulative0 = orZero(pool.PriceCumulative0)
+	pool.PriceCumulative1 = orZero(pool.PriceCumulative1)
+	if pool.ObservationCount > 0 {
+		elapsed := now - pool.LastObserved
+		pool.PriceCumulative0 = accumulatePrice(pool.PriceCumulative0, pool.Reserve0, pool.Reserve1, elapsed)
+		pool.PriceCumulative1 = accumulatePrice(pool.PriceCumulative1, pool.Reserve1, pool.Reserve0, elapsed)
+		pool.ObservationIndex = (pool.ObservationIndex + 1) % maxObservations
+	}
+	if pool.ObservationCount < maxObservations {
+		pool.ObservationCount++
+	}
+	pool.LastObserved = now
+
+	pool.observation = &Observation{
+		Timestamp:        now,
+		PriceCumulative0: new(big.Int).Set(pool.PriceCumulative0),
+		PriceCumulative1: new(big.Int).Set(pool.PriceCumulative1),
+	}
+	return nil
+}
+
+// twap 计算 [now-window, now] 内两侧的时间加权平均价格
+func twap(ctx contractapi.TransactionContextInterface, pool *Pool, now int64, window int64) (*big.Rat, *big.Rat, error) {
+	end0, end1, err := cumulativeAt(ctx, pool, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	start0, start1, err := cumulativeAt(ctx, pool, now-window)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	denom := new(big.Int).Mul(big.NewInt(window), priceScale)
+	price0 := new(big.Rat).SetFrac(end0.Sub(end0, start0), denom)
+	price1 := new(big.Rat).SetFrac(end1.Sub(end1, start1), denom)
+	return price0, price1, nil
+}
+
+// cumulativeAt 计算 target 时刻的累计价格
+// 相邻两次观测之间储备不变，累计价格随时间线性增长，可以按线性插值得到任意时刻的值
+func cumulativeAt(ctx contractapi.TransactionContextInterface, pool *Pool, target int64) (*big.Int, *big.Int, error) {
+	if pool.ObservationCount == 0 {
+		return nil, nil, fmt.Errorf("the pool %s has no price observations", pool.PairID)
+	}
+	if target >= pool.LastObserved {
+		// 最近一次观测之后价格为当前储备比值
+		elapsed := target - pool.LastObserved
+		return accumulatePrice(pool.PriceCumulative0, pool.Reserve0, pool.Reserve1, elapsed),
+			accumulatePrice(pool.PriceCumulative1, pool.Reserve1, pool.Reserve0, elapsed), nil
+	}
+
+	// 环形缓冲区中按时间顺序的第 i 条观测
+	oldest := oldestObservation(pool)
+	observationAt := func(i uint32) (*Observation, error) {
+		return getObservation(ctx, pool.PairID, (oldest+i)%maxObservations)
+	}
+
+	first, err := observationAt(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if target < first.Timestamp {
+		return nil, nil, fmt.Errorf("window starts before the oldest observation at %d", first.Timestamp)
+	}
+
+	// 二分查找不晚于 target 的最新观测 lo，hi 为其后一条观测，最新观测晚于 target
+	lo, hi := uint32(0), pool.ObservationCount-1
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		observation, err := observationAt(mid)
+		if err != nil {
+			return nil, nil, err
+		}
+		if observation.Timestamp <= target {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	before, err := observationAt(lo)
+	if err != nil {
+		return nil, nil, err
+	}
+	after, err := observationAt(hi)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	elapsed := big.NewInt(target - before.Timestamp)
+	span := big.NewInt(after.Timestamp - before.Timestamp)
+	interpolate := func(start *big.Int, end *big.Int) *big.Int {
+		delta := new(big.Int).Sub(end, start)
+		delta.Mul(delta, elapsed)
+		return delta.Add(delta.Div(delta, span), start)
+	}
+	return interpolate(before.PriceCumulative0, after.PriceCumulative0),
+		interpolate(before.PriceCumulative1, after.PriceCumulative1), nil
+}
+
+// oldestObservation 最旧观测在环形缓冲区中的位置
+func oldestObservation(pool *Pool) uint32 {
+	if pool.ObservationCount == maxObservations {
+		return (pool.ObservationIndex + 1) % maxObservations
+	}
+	return 0
+}
+
+// accumulatePrice 返回 cumulative + reserveOut/reserveIn * 2^112 * elapsed，任一侧储备为0时价格不累加
+func accumulatePrice(cumulative *big.Int, reserveIn *big.Int, reserveOut *big.Int, elapsed int64) *big.Int {
+	result := new(big.Int).Set(cumulative)
+	if elapsed <= 0 || reserveIn.Sign() == 0 || reserveOut.Sign() == 0 {
+		return result
+	}
+	price := new(big.Int).Mul(reserveOut, priceScale)
+	price.Div(price, reserveIn)
+	return result.Add(result, price.Mul(price, big.NewInt(elapsed)))
+}
+
+// observationKey 观测在世界状态中的键，序号补零以便按键排序
+func observationKey(ctx contractapi.TransactionContextInterface, pairID string, index uint32) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(observationPrefix, []string{pairID, fmt.Sprintf("%04d", index)})
+	if err != nil {
+		return "", fmt.Errorf("failed to create observation key: %v", err)
+	}
+	return key, nil
+}
+
+// getObservation 读取价格观测
+func getObservation(ctx contractapi.TransactionContextInterface, pairID string, index uint32) (*Observation, error) {
+	key, err := observationKey(ctx, pairID, index)
+	if err != nil {
+		return nil, err
+	}
+	observationBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read observation: %v", err)
+	}
+	if observationBytes == nil {
+		return nil, fmt.Errorf("the observation %d of %s does not exist", index, pairID)
+	}
+
+	var observation Observation
+	err = json.Unmarshal(observationBytes, &observation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal observation: %v", err)
+	}
+	return &observation, nil
+}
+
+// putObservation 写入价格观测，覆盖环形缓冲区中同一位置的旧观测
+func putObservation(ctx contractapi.TransactionContextInterface, pairID string, index uint32, observation *Observation) error {
+	key, err := observationKey(ctx, pairID, index)
+	if err != nil {
+		return err
+	}
+	observationBytes, err := json.Marshal(observation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal observation: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, observationBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put observation: %v", err)
+	}
+	return nil
+}
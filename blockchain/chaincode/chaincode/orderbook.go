@@ -0,0 +1,890 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 订单方向与状态
+const (
+	OrderSideBuy  = "BUY"
+	OrderSideSell = "SELL"
+
+	OrderStatusOpen      = "OPEN"
+	OrderStatusFilled    = "FILLED"
+	OrderStatusCancelled = "CANCELLED"
+	OrderStatusExpired   = "EXPIRED"
+)
+
+// 世界状态命名空间
+// 订单簿索引为 orderBook~方向~价格~下单时间~订单号，按键排序即为价格-时间优先级，买单价格取反后排序
+const (
+	orderPrefix       = "order"
+	orderBookIndex    = "orderBook"
+	orderAccountIndex = "orderAccount"
+	fillPrefix        = "orderFill"
+	fillAccountIndex  = "orderFillAccount"
+)
+
+// 订单簿交易 CCT，以 STABLE 计价，价格为每 1 CCT 的 STABLE 最小单位数量
+// 挂单冻结的资产托管在每张订单自己的托管账户下，并发挂单互不读写同一余额，
+// 升级前的挂单仍托管在订单簿账户下
+const orderBookAccount = "orderbook:" + defaultPairID
+
+// LimitOrderBook 定义限价订单簿合约结构，与 Exchange 的 CCT-STABLE 池并行运行
+type LimitOrderBook struct {
+	contractapi.Contract
+}
+
+// Order 定义订单，Amount 与 Filled 以 CCT 计，Price 为每 CCT 的 STABLE 数量
+type Order struct {
+	OrderID   string `json:"orderId"`
+	Owner     string `json:"owner"`
+	Side      string `json:"side"`
+	Price     uint64 `json:"price"` // 市价单为限价
+	Amount    uint64 `json:"amount"`
+	Filled    uint64 `json:"filled"`
+	Status    string `json:"status"`
+	Expiry    int64  `json:"expiry"`   // 过期时间（Unix 秒），0 表示长期有效
+	Sequence  int64  `json:"sequence"` // 下单时间（Unix 纳秒），同价位按此排序
+	PlaceTime string `json:"placeTime"`
+	Escrow    string `json:"escrow,omitempty" metadata:",optional"` // 挂单的托管账户，为空时为升级前托管在订单簿账户下的挂单
+}
+
+// Fill 定义一笔成交，与挂单成交时价格取挂单价格，与池子成交时为均价
+type Fill struct {
+	FillID       string `json:"fillId"`
+	MakerOrderID string `json:"makerOrderId"` // 与池子成交时为空
+	TakerOrderID string `json:"takerOrderId"`
+	Maker        string `json:"maker"` // 挂单账户或池子托管账户
+	Taker        string `json:"taker"`
+	TakerSide    string `json:"takerSide"`
+	Price        uint64 `json:"price"`
+	Amount       uint64 `json:"amount"` // 成交的 CCT 数量
+	Value        uint64 `json:"value"`  // 成交的 STABLE 金额
+	FillTime     string `json:"fillTime"`
+}
+
+// OrderResult 定义下单结果，同时作为下单事件的内容
+type OrderResult struct {
+	TxID          string  `json:"txId"`
+	Order         *Order  `json:"order"`
+	Fills         []*Fill `json:"fills"`
+	PoolAmountIn  string  `json:"poolAmountIn"`  // 路由到池子的输入数量
+	PoolAmountOut string  `json:"poolAmountOut"` // 从池子得到的输出数量
+}
+
+// PriceLevel 定义订单簿某一价位的挂单汇总
+type PriceLevel struct {
+	Price  uint64 `json:"price"`
+	Amount uint64 `json:"amount"` // 该价位未成交的 CCT 数量
+	Orders int    `json:"orders"`
+}
+
+// OrderBookDepth 定义订单簿深度，买单价格从高到低，卖单价格从低到高
+type OrderBookDepth struct {
+	Bids []*PriceLevel `json:"bids"`
+	Asks []*PriceLevel `json:"asks"`
+}
+
+// PlaceOrder 下限价单，先按价格-时间优先与对手挂单撮合，剩余部分挂单并冻结资产
+// 卖单冻结剩余的 CCT，买单冻结 price*剩余数量 的 STABLE；expiry 为过期时间（Unix 秒），0 表示长期有效
+func (o *LimitOrderBook) PlaceOrder(ctx contractapi.TransactionContextInterface, side string, price uint64, amount uint64, expiry int64) (*OrderResult, error) {
+	order, err := newOrder(ctx, side, price, amount)
+	if err != nil {
+		return nil, err
+	}
+	if expiry < 0 || (expiry > 0 && expiry <= order.Sequence/1e9) {
+		return nil, fmt.Errorf("invalid expiry")
+	}
+	order.Expiry = expiry
+
+	m := newMatcher(ctx, order)
+	err = m.match()
+	if err != nil {
+		return nil, err
+	}
+
+	if remaining := order.Amount - order.Filled; remaining > 0 {
+		// 剩余部分挂单，冻结对应资产
+		order.Escrow = orderAccount(order.OrderID)
+		m.settle.move(escrowSymbol(order.Side), order.Owner, order.Escrow, escrowAmount(order.Side, order.Price, remaining))
+		err = putIndex(ctx, orderBookIndex, bookAttributes(order))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		order.Status = OrderStatusFilled
+	}
+	err = putOrder(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	err = putIndex(ctx, orderAccountIndex, []string{order.Owner, order.OrderID})
+	if err != nil {
+		return nil, err
+	}
+
+	return m.finish("OrderPlaced")
+}
+
+// MarketOrder 以不差于 limitPrice 的价格立即成交最多 amount 个 CCT，未成交部分撤销，不挂单
+// usePool 为 true 时同时与 Exchange 的 CCT-STABLE 池比价，每一步从价格更优的一方成交
+func (o *LimitOrderBook) MarketOrder(ctx contractapi.TransactionContextInterface, side string, amount uint64, limitPrice uint64, usePool bool, deadline int64) (*OrderResult, error) {
+	err := checkDeadline(ctx, deadline)
+	if err != nil {
+		return nil, err
+	}
+	order, err := newOrder(ctx, side, limitPrice, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	m := newMatcher(ctx, order)
+	if usePool {
+		pool, err := getPool(ctx, defaultPairID)
+		if err != nil {
+			return nil, err
+		}
+		err = m.routeVia(pool)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = m.match()
+	if err != nil {
+		return nil, err
+	}
+	// 池子部分按实际兑换结果计入成交数量后才能确定订单状态
+	err = m.settlePool()
+	if err != nil {
+		return nil, err
+	}
+
+	if order.Filled == 0 {
+		return nil, fmt.Errorf("no liquidity at limit price %d", limitPrice)
+	}
+	order.Status = OrderStatusFilled
+	if order.Filled < order.Amount {
+		order.Status = OrderStatusCancelled
+	}
+	err = putOrder(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	err = putIndex(ctx, orderAccountIndex, []string{order.Owner, order.OrderID})
+	if err != nil {
+		return nil, err
+	}
+
+	return m.finish("OrderFilled")
+}
+
+// CancelOrder 撤销挂单并退回冻结的资产，只能由下单者撤销，已过期的挂单任何人都可以清理
+func (o *LimitOrderBook) CancelOrder(ctx contractapi.TransactionContextInterface, orderID string) (*Order, error) {
+	order, err := getOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != OrderStatusOpen {
+		return nil, fmt.Errorf("order %s is already %s", orderID, order.Status)
+	}
+	caller, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+
+	status := OrderStatusCancelled
+	if orderExpired(order, now.Seconds) {
+		status = OrderStatusExpired
+	} else if order.Owner != caller {
+		return nil, fmt.Errorf("caller is not the owner of order %s", orderID)
+	}
+
+	settle := newSettlement()
+	err = closeOrder(ctx, settle, order, status)
+	if err != nil {
+		return nil, err
+	}
+	err = settle.apply(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "OrderCancelled", order)
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetOrder 查询订单
+func (o *LimitOrderBook) GetOrder(ctx contractapi.TransactionContextInterface, orderID string) (*Order, error) {
+	return getOrder(ctx, orderID)
+}
+
+// GetOrdersByAccount 查询账户的全部限价单与市价单，account 可以是账户ID或后端用户ID
+func (o *LimitOrderBook) GetOrdersByAccount(ctx contractapi.TransactionContextInterface, account string) ([]*Order, error) {
+	account, err := resolveAccount(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	orderIDs, err := queryIndex(ctx, orderAccountIndex, account)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := []*Order{}
+	for _, orderID := range orderIDs {
+		order, err := getOrder(ctx, orderID)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// GetFillsByAccount 查询账户作为挂单方或吃单方的全部成交
+func (o *LimitOrderBook) GetFillsByAccount(ctx contractapi.TransactionContextInterface, account string) ([]*Fill, error) {
+	account, err := resolveAccount(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	fillIDs, err := queryIndex(ctx, fillAccountIndex, account)
+	if err != nil {
+		return nil, err
+	}
+
+	fills := []*Fill{}
+	for _, fillID := range fillIDs {
+		fill, err := getFill(ctx, fillID)
+		if err != nil {
+			return nil, err
+		}
+		fills = append(fills, fill)
+	}
+	return fills, nil
+}
+
+// GetOrderBook 查询买卖双方各 depth 个价位的挂单汇总，不含已过期的挂单
+func (o *LimitOrderBook) GetOrderBook(ctx contractapi.TransactionContextInterface, depth int) (*OrderBookDepth, error) {
+	if depth <= 0 || depth > maxPageSize {
+		return nil, fmt.Errorf("depth must be between 1 and %d", maxPageSize)
+	}
+	now, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+
+	bids, err := priceLevels(ctx, OrderSideBuy, depth, now.Seconds)
+	if err != nil {
+		return nil, err
+	}
+	asks, err := priceLevels(ctx, OrderSideSell, depth, now.Seconds)
+	if err != nil {
+		return nil, err
+	}
+	return &OrderBookDepth{Bids: bids, Asks: asks}, nil
+}
+
+// matcher 撮合一张吃单，资产变动汇总在 settle 中最后统一写入
+type matcher struct {
+	ctx       contractapi.TransactionContextInterface
+	taker     *Order
+	now       int64
+	settle    *settlement
+	fills     []*Fill
+	pool      *Pool     // 非 nil 时同时路由到 Exchange 池子
+	in        *poolSide // 池子的输入侧，买单输入 STABLE，卖单输入 CCT
+	out       *poolSide
+	poolUnits uint64 // 计划与池子成交的 CCT 数量
+	poolIn    *big.Int
+	poolOut   *big.Int
+	bandLow   *big.Rat // 启用熔断时池子成交后的价格不能超出 [bandLow, bandHigh]
+	bandHigh  *big.Rat
+}
+
+// newMatcher 为吃单创建撮合器
+func newMatcher(ctx contractapi.TransactionContextInterface, taker *Order) *matcher {
+	return &matcher{
+		ctx:     ctx,
+		taker:   taker,
+		now:     taker.Sequence / 1e9,
+		settle:  newSettlement(),
+		fills:   []*Fill{},
+		poolIn:  big.NewInt(0),
+		poolOut: big.NewInt(0),
+	}
+}
+
+// routeVia 启用池子路由，池子没有流动性、被暂停、处于熔断冷却期或处于批量拍卖模式时只与订单簿成交
+// 启用熔断时池子部分只成交到熔断区间的边界，不会触发熔断
+func (m *matcher) routeVia(pool *Pool) error {
+	if pool.Reserve0.Sign() == 0 || pool.Reserve1.Sign() == 0 || pool.Paused || m.now < pool.HaltedUntil || pool.BatchInterval > 0 {
+		return nil
+	}
+	tokenIn := cctSymbol
+	if m.taker.Side == OrderSideBuy {
+		tokenIn = stableSymbol
+	}
+	in, out, err := pool.sides(tokenIn)
+	if err != nil {
+		return err
+	}
+
+	breaker, err := getCircuitBreaker(m.ctx)
+	if err != nil {
+		return err
+	}
+	reference, err := referencePrice(m.ctx, pool, breaker)
+	if err != nil {
+		return err
+	}
+	if reference != nil {
+		m.bandLow, m.bandHigh = priceBand(reference, breaker)
+	}
+	m.pool, m.in, m.out = pool, in, out
+	return nil
+}
+
+// remaining 吃单尚未成交的数量
+func (m *matcher) remaining() uint64 {
+	return m.taker.Amount - m.taker.Filled
+}
+
+// match 按价格-时间优先遍历对手挂单，启用池子路由时在每个价位之前先从价格更优的池子成交
+func (m *matcher) match() error {
+	iterator, err := m.ctx.GetStub().GetStateByPartialCompositeKey(orderBookIndex, []string{oppositeSide(m.taker.Side)})
+	if err != nil {
+		return fmt.Errorf("failed to read order book: %v", err)
+	}
+	defer iterator.Close()
+
+	for m.remaining() > 0 && iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return err
+		}
+		_, attributes, err := m.ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return fmt.Errorf("failed to split index key: %v", err)
+		}
+		maker, err := getOrder(m.ctx, attributes[len(attributes)-1])
+		if err != nil {
+			return err
+		}
+
+		// 撮合时遇到的过期挂单直接清理，退回冻结的资产
+		if orderExpired(maker, m.now) {
+			err = closeOrder(m.ctx, m.settle, maker, OrderStatusExpired)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !crosses(m.taker, maker.Price) {
+			break
+		}
+		if maker.Owner == m.taker.Owner {
+			return fmt.Errorf("order would trade against own order %s", maker.OrderID)
+		}
+
+		if m.pool != nil {
+			m.routeToPool(maker.Price, false)
+			if m.remaining() == 0 {
+				break
+			}
+		}
+		err = m.fill(maker)
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.pool != nil && m.remaining() > 0 {
+		m.routeToPool(m.taker.Price, true)
+	}
+	return nil
+}
+
+// fill 与一张挂单成交，成交价为挂单价格
+func (m *matcher) fill(maker *Order) error {
+	amount := m.remaining()
+	if makerRemaining := maker.Amount - maker.Filled; makerRemaining < amount {
+		amount = makerRemaining
+	}
+	value, err := toUint64(orderValue(maker.Price, amount))
+	if err != nil {
+		return err
+	}
+
+	if m.taker.Side == OrderSideBuy {
+		// 买方向卖方支付 STABLE，卖方冻结的 CCT 转给买方
+		m.settle.move(stableSymbol, m.taker.Owner, maker.Owner, new(big.Int).SetUint64(value))
+		m.settle.move(cctSymbol, maker.escrow(), m.taker.Owner, new(big.Int).SetUint64(amount))
+	} else {
+		// 卖方向买方交付 CCT，买方冻结的 STABLE 转给卖方
+		m.settle.move(cctSymbol, m.taker.Owner, maker.Owner, new(big.Int).SetUint64(amount))
+		m.settle.move(stableSymbol, maker.escrow(), m.taker.Owner, new(big.Int).SetUint64(value))
+	}
+
+	m.taker.Filled += amount
+	maker.Filled += amount
+	if maker.Filled == maker.Amount {
+		maker.Status = OrderStatusFilled
+		err = delIndex(m.ctx, orderBookIndex, bookAttributes(maker))
+		if err != nil {
+			return err
+		}
+	}
+	err = putOrder(m.ctx, maker)
+	if err != nil {
+		return err
+	}
+
+	m.addFill(maker.OrderID, maker.Owner, maker.Price, amount, value)
+	return nil
+}
+
+// routeToPool 计算边际价格优于 price 的池子可成交数量，inclusive 为 true 时边际价格等于 price 也成交
+// 池子的边际价格随成交数量变差，用二分查找确定数量；池子部分在 finish 中合并为一次兑换
+func (m *matcher) routeToPool(price uint64, inclusive bool) {
+	limit := new(big.Int).SetUint64(price)
+	acceptable := func(units uint64) bool {
+		// 第 units 个 CCT 的边际价格
+		marginal := new(big.Int).Sub(m.poolValue(units), m.poolValue(units-1))
+		cmp := marginal.Cmp(limit)
+		if m.taker.Side == OrderSideBuy {
+			cmp = -cmp
+		}
+		return (cmp > 0 || (inclusive && cmp == 0)) && m.withinBand(units)
+	}
+
+	max := m.remaining()
+	if m.taker.Side == OrderSideBuy {
+		// 买入数量不能达到池子的全部 CCT 储备
+		available := new(big.Int).Sub(m.out.reserve, new(big.Int).SetUint64(m.poolUnits+1))
+		if available.Sign() <= 0 {
+			return
+		}
+		if available.IsUint64() && available.Uint64() < max {
+			max = available.Uint64()
+		}
+	}
+
+	var lo, hi uint64 = 0, max
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		if acceptable(m.poolUnits + mid) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	m.poolUnits += lo
+	m.taker.Filled += lo
+}
+
+// withinBand 与池子成交 units 个 CCT 后池子的价格是否仍在熔断区间内
+func (m *matcher) withinBand(units uint64) bool {
+	if m.bandLow == nil {
+		return true
+	}
+	amountIn := new(big.Int).SetUint64(units)
+	if m.taker.Side == OrderSideBuy {
+		amountIn = m.poolValue(units)
+	}
+	return priceWithinBand(m.pool, m.in, m.out, amountIn, m.bandLow, m.bandHigh)
+}
+
+// poolValue 与池子成交 units 个 CCT 的 STABLE 金额：买入时为需要支付的数量，卖出时为得到的数量
+func (m *matcher) poolValue(units uint64) *big.Int {
+	if units == 0 {
+		return big.NewInt(0)
+	}
+	amount := new(big.Int).SetUint64(units)
+	if m.taker.Side == OrderSideBuy {
+		amountIn, err := getAmountIn(amount, m.in.reserve, m.out.reserve, m.pool.SwapFeeNum, m.pool.SwapFeeDenom)
+		if err != nil {
+			// 超出池子储备时视为无穷大
+			return new(big.Int).Lsh(big.NewInt(1), 256)
+		}
+		return amountIn
+	}
+	amountOut, _ := getAmountOut(amount, m.in.reserve, m.out.reserve, m.pool.SwapFeeNum, m.pool.SwapFeeDenom)
+	return amountOut
+}
+
+// settlePool 将路由到池子的数量合并为一次兑换并更新池子，池子部分的均价不得差于吃单限价
+// 买入时按输入金额兑换得到的 CCT 可能因取整多于计划数量，吃单的成交数量按实际得到的数量记录
+func (m *matcher) settlePool() error {
+	if m.poolUnits == 0 || m.poolIn.Sign() > 0 {
+		return nil
+	}
+	units := new(big.Int).SetUint64(m.poolUnits)
+	amountIn := units
+	if m.taker.Side == OrderSideBuy {
+		amountIn = m.poolValue(m.poolUnits)
+	}
+	amountOut, fee := getAmountOut(amountIn, m.in.reserve, m.out.reserve, m.pool.SwapFeeNum, m.pool.SwapFeeDenom)
+
+	cct, value := amountOut, amountIn
+	if m.taker.Side == OrderSideSell {
+		cct, value = amountIn, amountOut
+	}
+	limitValue := orderValue(m.taker.Price, m.poolUnits)
+	if (m.taker.Side == OrderSideBuy && value.Cmp(limitValue) > 0) || (m.taker.Side == OrderSideSell && value.Cmp(limitValue) < 0) {
+		return fmt.Errorf("pool execution is worse than limit price %d", m.taker.Price)
+	}
+
+	err := observe(m.ctx, m.pool)
+	if err != nil {
+		return err
+	}
+	protocolFee, err := getProtocolFee(m.ctx)
+	if err != nil {
+		return err
+	}
+	m.settle.move(m.in.token, m.taker.Owner, poolAccount(m.pool.PairID), amountIn)
+	m.settle.move(m.out.token, poolAccount(m.pool.PairID), m.taker.Owner, amountOut)
+	updateReserves(m.pool, m.in, m.out, amountIn, fee, amountOut, protocolFee)
+	err = putPool(m.ctx, m.pool)
+	if err != nil {
+		return err
+	}
+	m.poolIn, m.poolOut = amountIn, amountOut
+
+	cctAmount, err := toUint64(cct)
+	if err != nil {
+		return err
+	}
+	m.taker.Filled = m.taker.Filled - m.poolUnits + cctAmount
+	stableValue, err := toUint64(value)
+	if err != nil {
+		return err
+	}
+	m.addFill("", poolAccount(m.pool.PairID), stableValue/cctAmount, cctAmount, stableValue)
+	return nil
+}
+
+// addFill 记录一笔成交
+func (m *matcher) addFill(makerOrderID string, maker string, price uint64, amount uint64, value uint64) {
+	m.fills = append(m.fills, &Fill{
+		FillID:       fmt.Sprintf("%s:%d", m.taker.OrderID, len(m.fills)),
+		MakerOrderID: makerOrderID,
+		TakerOrderID: m.taker.OrderID,
+		Maker:        maker,
+		Taker:        m.taker.Owner,
+		TakerSide:    m.taker.Side,
+		Price:        price,
+		Amount:       amount,
+		Value:        value,
+		FillTime:     m.taker.PlaceTime,
+	})
+}
+
+// finish 执行尚未执行的池子部分、写入余额与成交记录，并发出下单事件
+func (m *matcher) finish(event string) (*OrderResult, error) {
+	err := m.settlePool()
+	if err != nil {
+		return nil, err
+	}
+	err = m.settle.apply(m.ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, fill := range m.fills {
+		err = putFill(m.ctx, fill)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &OrderResult{
+		TxID:          m.ctx.GetStub().GetTxID(),
+		Order:         m.taker,
+		Fills:         m.fills,
+		PoolAmountIn:  m.poolIn.String(),
+		PoolAmountOut: m.poolOut.String(),
+	}
+	err = emitEvent(m.ctx, event, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// newOrder 校验参数并创建调用者的订单，以交易ID作为订单号，交易所全局暂停期间不能下单
+func newOrder(ctx contractapi.TransactionContextInterface, side string, price uint64, amount uint64) (*Order, error) {
+	err := requireNotPaused(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if side != OrderSideBuy && side != OrderSideSell {
+		return nil, fmt.Errorf("invalid order side %s", side)
+	}
+	if price == 0 {
+		return nil, fmt.Errorf("price must be greater than 0")
+	}
+	if amount == 0 {
+		return nil, fmt.Errorf("amount must be greater than 0")
+	}
+	if _, err := toUint64(orderValue(price, amount)); err != nil {
+		return nil, err
+	}
+
+	owner, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	placeTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Order{
+		OrderID:   ctx.GetStub().GetTxID(),
+		Owner:     owner,
+		Side:      side,
+		Price:     price,
+		Amount:    amount,
+		Status:    OrderStatusOpen,
+		Sequence:  txtime.Seconds*1e9 + int64(txtime.Nanos),
+		PlaceTime: placeTime,
+	}, nil
+}
+
+// closeOrder 撤销或清理挂单，退回剩余部分冻结的资产并移出订单簿
+func closeOrder(ctx contractapi.TransactionContextInterface, settle *settlement, order *Order, status string) error {
+	remaining := order.Amount - order.Filled
+	settle.move(escrowSymbol(order.Side), order.escrow(), order.Owner, escrowAmount(order.Side, order.Price, remaining))
+
+	err := delIndex(ctx, orderBookIndex, bookAttributes(order))
+	if err != nil {
+		return err
+	}
+	order.Status = status
+	return putOrder(ctx, order)
+}
+
+// priceLevels 汇总订单簿一侧的前 depth 个价位
+func priceLevels(ctx contractapi.TransactionContextInterface, side string, depth int, now int64) ([]*PriceLevel, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(orderBookIndex, []string{side})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read order book: %v", err)
+	}
+	defer iterator.Close()
+
+	levels := []*PriceLevel{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split index key: %v", err)
+		}
+		order, err := getOrder(ctx, attributes[len(attributes)-1])
+		if err != nil {
+			return nil, err
+		}
+		if orderExpired(order, now) {
+			continue
+		}
+
+		if len(levels) == 0 || levels[len(levels)-1].Price != order.Price {
+			if len(levels) == depth {
+				break
+			}
+			levels = append(levels, &PriceLevel{Price: order.Price})
+		}
+		level := levels[len(levels)-1]
+		level.Amount += order.Amount - order.Filled
+		level.Orders++
+	}
+	return levels, nil
+}
+
+// bookAttributes 订单簿索引的属性，价格与时间补零到固定宽度以保证按键排序
+func bookAttributes(order *Order) []string {
+	price := order.Price
+	if order.Side == OrderSideBuy {
+		price = math.MaxUint64 - price
+	}
+	return []string{order.Side, fmt.Sprintf("%020d", price), fmt.Sprintf("%019d", order.Sequence), order.OrderID}
+}
+
+// crosses 判断吃单能否与价格为 price 的挂单成交
+func crosses(taker *Order, price uint64) bool {
+	if taker.Side == OrderSideBuy {
+		return price <= taker.Price
+	}
+	return price >= taker.Price
+}
+
+// orderExpired 判断挂单在 now（Unix 秒）时是否已过期
+func orderExpired(order *Order, now int64) bool {
+	return order.Expiry > 0 && now > order.Expiry
+}
+
+// oppositeSide 返回对手方向
+func oppositeSide(side string) string {
+	if side == OrderSideBuy {
+		return OrderSideSell
+	}
+	return OrderSideBuy
+}
+
+// escrowSymbol 挂单冻结的代币：买单冻结 STABLE，卖单冻结 CCT
+func escrowSymbol(side string) string {
+	if side == OrderSideBuy {
+		return stableSymbol
+	}
+	return cctSymbol
+}
+
+// orderAccount 挂单的托管账户
+func orderAccount(orderID string) string {
+	return orderBookAccount + ":" + orderID
+}
+
+// escrow 返回挂单的托管账户，升级前的挂单托管在订单簿账户下
+func (order *Order) escrow() string {
+	if order.Escrow == "" {
+		return orderBookAccount
+	}
+	return order.Escrow
+}
+
+// escrowAmount 挂单剩余 amount 个 CCT 时冻结的数量
+func escrowAmount(side string, price uint64, amount uint64) *big.Int {
+	if side == OrderSideBuy {
+		return orderValue(price, amount)
+	}
+	return new(big.Int).SetUint64(amount)
+}
+
+// orderValue 计算 amount 个 CCT 按 price 成交的 STABLE 金额
+func orderValue(price uint64, amount uint64) *big.Int {
+	return new(big.Int).Mul(new(big.Int).SetUint64(price), new(big.Int).SetUint64(amount))
+}
+
+// getOrder 读取订单
+func getOrder(ctx contractapi.TransactionContextInterface, orderID string) (*Order, error) {
+	orderKey, err := ctx.GetStub().CreateCompositeKey(orderPrefix, []string{orderID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order key: %v", err)
+	}
+	orderBytes, err := ctx.GetStub().GetState(orderKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if orderBytes == nil {
+		return nil, fmt.Errorf("the order %s does not exist", orderID)
+	}
+
+	var order Order
+	err = json.Unmarshal(orderBytes, &order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal order: %v", err)
+	}
+	return &order, nil
+}
+
+// putOrder 写入订单
+func putOrder(ctx contractapi.TransactionContextInterface, order *Order) error {
+	orderKey, err := ctx.GetStub().CreateCompositeKey(orderPrefix, []string{order.OrderID})
+	if err != nil {
+		return fmt.Errorf("failed to create order key: %v", err)
+	}
+	orderBytes, err := json.Marshal(order)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order: %v", err)
+	}
+	err = ctx.GetStub().PutState(orderKey, orderBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put order: %v", err)
+	}
+	return nil
+}
+
+// getFill 读取成交记录
+func getFill(ctx contractapi.TransactionContextInterface, fillID string) (*Fill, error) {
+	fillKey, err := ctx.GetStub().CreateCompositeKey(fillPrefix, []string{fillID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fill key: %v", err)
+	}
+	fillBytes, err := ctx.GetStub().GetState(fillKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if fillBytes == nil {
+		return nil, fmt.Errorf("the fill %s does not exist", fillID)
+	}
+
+	var fill Fill
+	err = json.Unmarshal(fillBytes, &fill)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal fill: %v", err)
+	}
+	return &fill, nil
+}
+
+// putFill 写入成交记录，并为挂单方与吃单方建立索引
+func putFill(ctx contractapi.TransactionContextInterface, fill *Fill) error {
+	fillKey, err := ctx.GetStub().CreateCompositeKey(fillPrefix, []string{fill.FillID})
+	if err != nil {
+		return fmt.Errorf("failed to create fill key: %v", err)
+	}
+	fillBytes, err := json.Marshal(fill)
+	if err != nil {
+		return fmt.Errorf("failed to marshal fill: %v", err)
+	}
+	err = ctx.GetStub().PutState(fillKey, fillBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put fill: %v", err)
+	}
+
+	err = putIndex(ctx, fillAccountIndex, []string{fill.Maker, fill.FillID})
+	if err != nil {
+		return err
+	}
+	return putIndex(ctx, fillAccountIndex, []string{fill.Taker, fill.FillID})
+}
+
+// queryIndex 返回索引中 value 之下的记录ID，索引的最后一个属性为记录ID
+func queryIndex(ctx contractapi.TransactionContextInterface, index string, value string) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{value})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	ids := []string{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split index key: %v", err)
+		}
+		ids = append(ids, attributes[len(attributes)-1])
+	}
+	return ids, nil
+}
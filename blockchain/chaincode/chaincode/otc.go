@@ -0,0 +1,434 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 场外报价状态
+const (
+	OfferStatusOpen      = "OPEN"
+	OfferStatusAccepted  = "ACCEPTED"
+	OfferStatusCancelled = "CANCELLED"
+	OfferStatusExpired   = "EXPIRED"
+)
+
+// 世界状态与私有数据命名空间
+const (
+	offerPrefix       = "otcOffer"
+	offerAccountIndex = "otcOfferAccount"
+	offerTermsPrefix  = "otcTerms"
+)
+
+// 成交价格通过 transient 传入，不进入交易提案的参数，也不会写入区块
+const (
+	transientPrice = "price"
+	transientSalt  = "salt"
+)
+
+// 卖方挂出的 CCT 托管在场外账户下，直到对手方接受或报价撤销
+const otcAccount = "otc:" + cctSymbol
+
+// OTC 定义场外大宗交易合约，卖方向指定对手方报价，对手方接受后 CCT 与 STABLE 原子交割
+// 价格只在成交前保密：交割时 STABLE 按公开余额划转，成交后任何人都可以用 STABLE 变动除以公开的 Amount 得到价格
+type OTC struct {
+	contractapi.Contract
+}
+
+// Offer 定义场外报价的公开部分，报价期间价格只以哈希形式公开，明文保存在双方机构共享的私有数据集合中
+type Offer struct {
+	OfferID      string `json:"offerId"`
+	Seller       string `json:"seller"`
+	Buyer        string `json:"buyer"`
+	Amount       uint64 `json:"amount"` // 出售的 CCT 数量
+	Collection   string `json:"collection"`
+	TermsHash    string `json:"termsHash"` // 私有成交条款的 SHA-256，与 Fabric 记录的私有数据哈希一致
+	Expiry       int64  `json:"expiry"`    // 过期时间（Unix 秒）
+	Status       string `json:"status"`
+	CreateTime   string `json:"createTime"`
+	SettleTime   string `json:"settleTime,omitempty" metadata:",optional"`
+	SettleTxID   string `json:"settleTxId,omitempty" metadata:",optional"`
+	SellerMSPID  string `json:"sellerMspId"`
+	BuyerMSPID   string `json:"buyerMspId"`
+	CancelReason string `json:"cancelReason,omitempty" metadata:",optional"`
+}
+
+// OfferTerms 定义私有的成交条款，Price 为每 CCT 的 STABLE 数量，Salt 防止通过穷举价格反推哈希
+type OfferTerms struct {
+	OfferID string `json:"offerId"`
+	Price   uint64 `json:"price"`
+	Value   uint64 `json:"value"` // 成交的 STABLE 总额
+	Salt    string `json:"salt"`
+}
+
+// CreateOffer 卖方向 counterparty 报价出售 amount 个 CCT，并冻结这部分 CCT
+// 价格与盐值通过 transient 的 price、salt 传入；counterparty 可以是账户ID或后端用户ID，须已登记账户
+func (o *OTC) CreateOffer(ctx contractapi.TransactionContextInterface, counterparty string, amount uint64, expiry int64) (*Offer, error) {
+	if amount == 0 {
+		return nil, fmt.Errorf("amount must be greater than 0")
+	}
+	now, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	if expiry <= now.Seconds {
+		return nil, fmt.Errorf("invalid expiry")
+	}
+
+	price, salt, err := readOfferTransient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if salt == "" {
+		return nil, fmt.Errorf("transient field %s must not be empty", transientSalt)
+	}
+	value, err := toUint64(orderValue(price, amount))
+	if err != nil {
+		return nil, err
+	}
+
+	seller, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sellerMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	buyer, err := resolveAccount(ctx, counterparty)
+	if err != nil {
+		return nil, err
+	}
+	if buyer == seller {
+		return nil, fmt.Errorf("counterparty must not be the seller")
+	}
+	buyerAccount, err := getAccount(ctx, buyer)
+	if err != nil {
+		return nil, err
+	}
+	createTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	offer := &Offer{
+		OfferID:     ctx.GetStub().GetTxID(),
+		Seller:      seller,
+		Buyer:       buyer,
+		Amount:      amount,
+		Collection:  otcCollection(sellerMSPID, buyerAccount.MSPID),
+		Expiry:      expiry,
+		Status:      OfferStatusOpen,
+		CreateTime:  createTime,
+		SellerMSPID: sellerMSPID,
+		BuyerMSPID:  buyerAccount.MSPID,
+	}
+	terms := &OfferTerms{OfferID: offer.OfferID, Price: price, Value: value, Salt: salt}
+	offer.TermsHash, err = putOfferTerms(ctx, offer.Collection, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	err = transfer(ctx, cctSymbol, seller, otcAccount, amount)
+	if err != nil {
+		return nil, err
+	}
+	err = putOffer(ctx, offer)
+	if err != nil {
+		return nil, err
+	}
+	err = putIndex(ctx, offerAccountIndex, []string{seller, offer.OfferID})
+	if err != nil {
+		return nil, err
+	}
+	err = putIndex(ctx, offerAccountIndex, []string{buyer, offer.OfferID})
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "OfferCreated", offer)
+	if err != nil {
+		return nil, err
+	}
+	return offer, nil
+}
+
+// AcceptOffer 对手方接受报价，CCT 从托管账户交给对手方，STABLE 从对手方付给卖方，两笔转账在同一交易中完成
+// 对手方须通过 transient 的 price 确认成交价格，与私有条款不一致时拒绝交割；STABLE 转账是公开的，成交后价格不再保密
+func (o *OTC) AcceptOffer(ctx contractapi.TransactionContextInterface, offerID string) (*Offer, error) {
+	offer, err := getOffer(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+	if offer.Status != OfferStatusOpen {
+		return nil, fmt.Errorf("offer %s is already %s", offerID, offer.Status)
+	}
+	caller, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if caller != offer.Buyer {
+		return nil, fmt.Errorf("caller is not the counterparty of offer %s", offerID)
+	}
+	now, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	if offerExpired(offer, now.Seconds) {
+		return nil, fmt.Errorf("offer %s has expired", offerID)
+	}
+
+	price, _, err := readOfferTransient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	terms, err := getOfferTerms(ctx, offer)
+	if err != nil {
+		return nil, err
+	}
+	if terms.Price != price {
+		return nil, fmt.Errorf("confirmed price does not match the terms of offer %s", offerID)
+	}
+
+	settle := newSettlement()
+	settle.move(cctSymbol, otcAccount, offer.Buyer, new(big.Int).SetUint64(offer.Amount))
+	settle.move(stableSymbol, offer.Buyer, offer.Seller, new(big.Int).SetUint64(terms.Value))
+	err = settle.apply(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	offer.Status = OfferStatusAccepted
+	offer.SettleTxID = ctx.GetStub().GetTxID()
+	offer.SettleTime, err = getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = putOffer(ctx, offer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "OfferAccepted", offer)
+	if err != nil {
+		return nil, err
+	}
+	return offer, nil
+}
+
+// CancelOffer 撤销报价并退回冻结的 CCT，卖方可以随时撤销，对手方可以拒绝，已过期的报价任何人都可以清理
+func (o *OTC) CancelOffer(ctx contractapi.TransactionContextInterface, offerID string) (*Offer, error) {
+	offer, err := getOffer(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+	if offer.Status != OfferStatusOpen {
+		return nil, fmt.Errorf("offer %s is already %s", offerID, offer.Status)
+	}
+	caller, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+
+	switch {
+	case offerExpired(offer, now.Seconds):
+		offer.Status = OfferStatusExpired
+	case caller == offer.Seller:
+		offer.Status = OfferStatusCancelled
+		offer.CancelReason = "withdrawn by seller"
+	case caller == offer.Buyer:
+		offer.Status = OfferStatusCancelled
+		offer.CancelReason = "declined by counterparty"
+	default:
+		return nil, fmt.Errorf("caller is not a party to offer %s", offerID)
+	}
+
+	err = transfer(ctx, cctSymbol, otcAccount, offer.Seller, offer.Amount)
+	if err != nil {
+		return nil, err
+	}
+	err = putOffer(ctx, offer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, "OfferCancelled", offer)
+	if err != nil {
+		return nil, err
+	}
+	return offer, nil
+}
+
+// GetOffer 查询报价的公开部分
+func (o *OTC) GetOffer(ctx contractapi.TransactionContextInterface, offerID string) (*Offer, error) {
+	return getOffer(ctx, offerID)
+}
+
+// GetOffersByAccount 查询账户作为卖方或对手方的全部报价，account 可以是账户ID或后端用户ID
+func (o *OTC) GetOffersByAccount(ctx contractapi.TransactionContextInterface, account string) ([]*Offer, error) {
+	account, err := resolveAccount(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	offerIDs, err := queryIndex(ctx, offerAccountIndex, account)
+	if err != nil {
+		return nil, err
+	}
+
+	offers := []*Offer{}
+	for _, offerID := range offerIDs {
+		offer, err := getOffer(ctx, offerID)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, offer)
+	}
+	return offers, nil
+}
+
+// GetOfferTerms 查询报价的私有条款，只有交易双方可以查询，且须由私有数据集合成员的节点背书
+func (o *OTC) GetOfferTerms(ctx contractapi.TransactionContextInterface, offerID string) (*OfferTerms, error) {
+	offer, err := getOffer(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+	caller, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if caller != offer.Seller && caller != offer.Buyer {
+		return nil, fmt.Errorf("caller is not a party to offer %s", offerID)
+	}
+	return getOfferTerms(ctx, offer)
+}
+
+// otcCollection 返回交易双方机构共享的私有数据集合
+// 同一机构内的交易使用该机构的隐式集合，跨机构交易使用 collections_config.json 中按 MSP ID 排序命名的集合
+func otcCollection(sellerMSPID string, buyerMSPID string) string {
+	if sellerMSPID == buyerMSPID {
+		return "_implicit_org_" + sellerMSPID
+	}
+	orgs := []string{sellerMSPID, buyerMSPID}
+	sort.Strings(orgs)
+	return "otc_" + orgs[0] + "_" + orgs[1]
+}
+
+// offerExpired 判断报价在 now（Unix 秒）时是否已过期
+func offerExpired(offer *Offer, now int64) bool {
+	return now > offer.Expiry
+}
+
+// readOfferTransient 读取 transient 中的价格与盐值
+func readOfferTransient(ctx contractapi.TransactionContextInterface) (uint64, string, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get transient: %v", err)
+	}
+	priceBytes, ok := transientMap[transientPrice]
+	if !ok {
+		return 0, "", fmt.Errorf("transient field %s is required", transientPrice)
+	}
+	price, err := strconv.ParseUint(string(priceBytes), 10, 64)
+	if err != nil || price == 0 {
+		return 0, "", fmt.Errorf("invalid price")
+	}
+	return price, string(transientMap[transientSalt]), nil
+}
+
+// getOfferTerms 从私有数据集合读取成交条款，并校验与公开的哈希一致
+func getOfferTerms(ctx contractapi.TransactionContextInterface, offer *Offer) (*OfferTerms, error) {
+	termsKey, err := ctx.GetStub().CreateCompositeKey(offerTermsPrefix, []string{offer.OfferID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create offer terms key: %v", err)
+	}
+	termsBytes, err := ctx.GetStub().GetPrivateData(offer.Collection, termsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from collection %s: %v", offer.Collection, err)
+	}
+	if termsBytes == nil {
+		return nil, fmt.Errorf("the terms of offer %s are not available on this peer", offer.OfferID)
+	}
+	if termsHash(termsBytes) != offer.TermsHash {
+		return nil, fmt.Errorf("the terms of offer %s do not match the public hash", offer.OfferID)
+	}
+
+	var terms OfferTerms
+	err = json.Unmarshal(termsBytes, &terms)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal offer terms: %v", err)
+	}
+	return &terms, nil
+}
+
+// putOfferTerms 将成交条款写入私有数据集合，返回其哈希
+func putOfferTerms(ctx contractapi.TransactionContextInterface, collection string, terms *OfferTerms) (string, error) {
+	termsKey, err := ctx.GetStub().CreateCompositeKey(offerTermsPrefix, []string{terms.OfferID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create offer terms key: %v", err)
+	}
+	termsBytes, err := json.Marshal(terms)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal offer terms: %v", err)
+	}
+	err = ctx.GetStub().PutPrivateData(collection, termsKey, termsBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to put offer terms: %v", err)
+	}
+	return termsHash(termsBytes), nil
+}
+
+// termsHash 计算私有条款的 SHA-256 摘要
+func termsHash(termsBytes []byte) string {
+	digest := sha256.Sum256(termsBytes)
+	return hex.EncodeToString(digest[:])
+}
+
+// getOffer 读取报价
+func getOffer(ctx contractapi.TransactionContextInterface, offerID string) (*Offer, error) {
+	offerKey, err := ctx.GetStub().CreateCompositeKey(offerPrefix, []string{offerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create offer key: %v", err)
+	}
+	offerBytes, err := ctx.GetStub().GetState(offerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if offerBytes == nil {
+		return nil, fmt.Errorf("the offer %s does not exist", offerID)
+	}
+
+	var offer Offer
+	err = json.Unmarshal(offerBytes, &offer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal offer: %v", err)
+	}
+	return &offer, nil
+}
+
+// putOffer 写入报价
+func putOffer(ctx contractapi.TransactionContextInterface, offer *Offer) error {
+	offerKey, err := ctx.GetStub().CreateCompositeKey(offerPrefix, []string{offer.OfferID})
+	if err != nil {
+		return fmt.Errorf("failed to create offer key: %v", err)
+	}
+	offerBytes, err := json.Marshal(offer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal offer: %v", err)
+	}
+	err = ctx.GetStub().PutState(offerKey, offerBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put offer: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 每个 LP 在每个交易对中的持仓单独存放在 lpPosition~交易对~账户 组合键下
+// 池子记录只保存储备与累计值，份额变动与转让不会改写其他 LP 的数据
+const positionPrefix = "lpPosition"
+
+// Position 定义 LP 持仓，Growth0/Growth1 为上次结算时的每份额累计手续费，Owed0/Owed1 为已结算未领取的手续费
+type Position struct {
+	PairID  string   `json:"pairId"`
+	Owner   string   `json:"owner"`
+	Shares  *big.Int `json:"shares"`
+	Growth0 *big.Int `json:"growth0"`
+	Growth1 *big.Int `json:"growth1"`
+	Owed0   *big.Int `json:"owed0"`
+	Owed1   *big.Int `json:"owed1"`
+}
+
+// positionKey 持仓在世界状态中的键
+func positionKey(ctx contractapi.TransactionContextInterface, pairID string, owner string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(positionPrefix, []string{pairID, owner})
+	if err != nil {
+		return "", fmt.Errorf("failed to create position key: %v", err)
+	}
+	return key, nil
+}
+
+// getPosition 读取 LP 持仓，没有记录时返回空持仓
+func getPosition(ctx contractapi.TransactionContextInterface, pairID string, owner string) (*Position, error) {
+	key, err := positionKey(ctx, pairID, owner)
+	if err != nil {
+		return nil, err
+	}
+	positionBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read position: %v", err)
+	}
+	if positionBytes == nil {
+		return newPosition(pairID, owner), nil
+	}
+
+	var position Position
+	err = json.Unmarshal(positionBytes, &position)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal position: %v", err)
+	}
+	return &position, nil
+}
+
+// putPosition 写入 LP 持仓，份额与未领取手续费都为0时删除记录
+func putPosition(ctx contractapi.TransactionContextInterface, position *Position) error {
+	key, err := positionKey(ctx, position.PairID, position.Owner)
+	if err != nil {
+		return err
+	}
+	if position.Shares.Sign() == 0 && position.Owed0.Sign() == 0 && position.Owed1.Sign() == 0 {
+		err = ctx.GetStub().DelState(key)
+		if err != nil {
+			return fmt.Errorf("failed to delete position: %v", err)
+		}
+		return nil
+	}
+
+	positionBytes, err := json.Marshal(position)
+	if err != nil {
+		return fmt.Errorf("failed to marshal position: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, positionBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update position: %v", err)
+	}
+	return nil
+}
+
+// newPosition 创建空持仓，累计值从0开始
+func newPosition(pairID string, owner string) *Position {
+	return &Position{
+		PairID:  pairID,
+		Owner:   owner,
+		Shares:  big.NewInt(0),
+		Growth0: big.NewInt(0),
+		Growth1: big.NewInt(0),
+		Owed0:   big.NewInt(0),
+		Owed1:   big.NewInt(0),
+	}
+}
+
+// moveShares 将 amount 份额从 from 移到 to，未结算的手续费随份额转移，不需要读取池子
+// to 的检查点取双方检查点按份额加权的平均值（向上取整），转移后双方可领取的手续费之和不增加
+func moveShares(from *Position, to *Position, amount *big.Int) {
+	total := new(big.Int).Add(to.Shares, amount)
+	to.Growth0 = weightedGrowth(to.Shares, to.Growth0, amount, from.Growth0, total)
+	to.Growth1 = weightedGrowth(to.Shares, to.Growth1, amount, from.Growth1, total)
+	to.Shares = total
+	from.Shares = new(big.Int).Sub(from.Shares, amount)
+}
+
+// weightedGrowth 计算 (sharesA * growthA + sharesB * growthB) / total，向上取整
+func weightedGrowth(sharesA *big.Int, growthA *big.Int, sharesB *big.Int, growthB *big.Int, total *big.Int) *big.Int {
+	sum := new(big.Int).Mul(sharesA, growthA)
+	sum.Add(sum, new(big.Int).Mul(sharesB, growthB))
+	return ceilDiv(sum, total)
+}
@@ -0,0 +1,176 @@
+package chaincode
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 价格以最小单位之比表示，保留的小数位数
+const priceDecimals = 18
+
+// Quote 定义只读报价结果，金额均为最小单位
+type Quote struct {
+	TokenIn        string `json:"tokenIn"`
+	TokenOut       string `json:"tokenOut"`
+	AmountIn       string `json:"amountIn"`
+	AmountOut      string `json:"amountOut"`
+	Fee            string `json:"fee"`            // 从输入中扣除的手续费
+	SpotPrice      string `json:"spotPrice"`      // 交易前每单位 tokenIn 可换得的 tokenOut
+	ExecutionPrice string `json:"executionPrice"` // 本次成交的 amountOut / amountIn
+	PriceImpact    string `json:"priceImpact"`    // 成交价相对现价的偏离百分比，含手续费
+}
+
+// QuoteExactIn 预览输入 amountIn 个 tokenIn 可得到的 tokenOut 数量，与实际兑换使用相同的手续费与取整方式
+func (e *Exchange) QuoteExactIn(ctx contractapi.TransactionContextInterface, pairID string, tokenIn string, amountIn string) (*Quote, error) {
+	amount, err := parsePositiveAmount(amountIn, "amountIn")
+	if err != nil {
+		return nil, err
+	}
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return nil, err
+	}
+	in, out, err := quoteSides(pool, tokenIn)
+	if err != nil {
+		return nil, err
+	}
+
+	amountOut, fee := getAmountOut(amount, in.reserve, out.reserve, pool.SwapFeeNum, pool.SwapFeeDenom)
+	if amountOut.Sign() <= 0 {
+		return nil, fmt.Errorf("insufficient output amount")
+	}
+
+	return newQuote(in, out, amount, amountOut, fee), nil
+}
+
+// QuoteExactOut 预览得到 amountOut 个 tokenOut 最少需要输入的 tokenIn 数量
+func (e *Exchange) QuoteExactOut(ctx contractapi.TransactionContextInterface, pairID string, tokenIn string, amountOut string) (*Quote, error) {
+	amount, err := parsePositiveAmount(amountOut, "amountOut")
+	if err != nil {
+		return nil, err
+	}
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return nil, err
+	}
+	in, out, err := quoteSides(pool, tokenIn)
+	if err != nil {
+		return nil, err
+	}
+
+	amountIn, err := getAmountIn(amount, in.reserve, out.reserve, pool.SwapFeeNum, pool.SwapFeeDenom)
+	if err != nil {
+		return nil, err
+	}
+	// 按实际兑换的公式重新计算，输出可能因取整略多于 amountOut
+	actualOut, fee := getAmountOut(amountIn, in.reserve, out.reserve, pool.SwapFeeNum, pool.SwapFeeDenom)
+
+	return newQuote(in, out, amountIn, actualOut, fee), nil
+}
+
+// GetSpotPrice 查询当前每单位 tokenIn 可换得的 tokenOut 数量（不含手续费）
+func (e *Exchange) GetSpotPrice(ctx contractapi.TransactionContextInterface, pairID string, tokenIn string) (string, error) {
+	pool, err := getPool(ctx, pairID)
+	if err != nil {
+		return "", err
+	}
+	in, out, err := quoteSides(pool, tokenIn)
+	if err != nil {
+		return "", err
+	}
+
+	return new(big.Rat).SetFrac(out.reserve, in.reserve).FloatString(priceDecimals), nil
+}
+
+// GetPriceImpact 查询输入 amountIn 个 tokenIn 时成交价相对现价的偏离百分比
+func (e *Exchange) GetPriceImpact(ctx contractapi.TransactionContextInterface, pairID string, tokenIn string, amountIn string) (string, error) {
+	quote, err := e.QuoteExactIn(ctx, pairID, tokenIn, amountIn)
+	if err != nil {
+		return "", err
+	}
+	return quote.PriceImpact, nil
+}
+
+// quoteSides 返回报价使用的输入侧与输出侧，池子没有流动性时无法报价
+func quoteSides(pool *Pool, tokenIn string) (*poolSide, *poolSide, error) {
+	if pool.Reserve0.Sign() == 0 || pool.Reserve1.Sign() == 0 {
+		return nil, nil, fmt.Errorf("insufficient liquidity")
+	}
+	return pool.sides(tokenIn)
+}
+
+// getAmountOut 按恒定乘积公式计算输出数量，手续费从输入中扣除，均向下取整
+func getAmountOut(amountIn *big.Int, reserveIn *big.Int, reserveOut *big.Int, feeNum uint64, feeDenom uint64) (*big.Int, *big.Int) {
+	fee := new(big.Int).Mul(amountIn, new(big.Int).SetUint64(feeNum))
+	fee.Div(fee, new(big.Int).SetUint64(feeDenom))
+	amountAfterFee := new(big.Int).Sub(amountIn, fee)
+
+	amountOut := new(big.Int).Mul(amountAfterFee, reserveOut)
+	amountOut.Div(amountOut, new(big.Int).Add(reserveIn, amountAfterFee))
+
+	return amountOut, fee
+}
+
+// getAmountIn 计算使 getAmountOut 不小于 amountOut 的最小输入
+func getAmountIn(amountOut *big.Int, reserveIn *big.Int, reserveOut *big.Int, feeNum uint64, feeDenom uint64) (*big.Int, error) {
+	if amountOut.Cmp(reserveOut) >= 0 {
+		return nil, fmt.Errorf("insufficient liquidity for output amount %s", amountOut.String())
+	}
+	if feeNum >= feeDenom {
+		return nil, fmt.Errorf("invalid swap fee")
+	}
+
+	// 扣费后的输入至少为 ceil(amountOut * reserveIn / (reserveOut - amountOut))
+	needed := new(big.Int).Mul(amountOut, reserveIn)
+	needed = ceilDiv(needed, new(big.Int).Sub(reserveOut, amountOut))
+
+	// 扣费前的输入估算为 ceil(needed * denom / (denom - num))，再按向下取整的手续费校正
+	denom := new(big.Int).SetUint64(feeDenom)
+	amountIn := ceilDiv(new(big.Int).Mul(needed, denom), new(big.Int).SetUint64(feeDenom-feeNum))
+	afterFee := func(in *big.Int) *big.Int {
+		fee := new(big.Int).Mul(in, new(big.Int).SetUint64(feeNum))
+		fee.Div(fee, denom)
+		return fee.Sub(in, fee)
+	}
+	one := big.NewInt(1)
+	for afterFee(amountIn).Cmp(needed) < 0 {
+		amountIn.Add(amountIn, one)
+	}
+	for amountIn.Cmp(one) > 0 && afterFee(new(big.Int).Sub(amountIn, one)).Cmp(needed) >= 0 {
+		amountIn.Sub(amountIn, one)
+	}
+
+	return amountIn, nil
+}
+
+// newQuote 组装报价，计算现价、成交价与价格影响
+func newQuote(in *poolSide, out *poolSide, amountIn *big.Int, amountOut *big.Int, fee *big.Int) *Quote {
+	spot := new(big.Rat).SetFrac(out.reserve, in.reserve)
+	execution := new(big.Rat).SetFrac(amountOut, amountIn)
+	// 价格影响 = (1 - 成交价 / 现价) * 100
+	impact := new(big.Rat).Quo(execution, spot)
+	impact.Sub(big.NewRat(1, 1), impact)
+	impact.Mul(impact, big.NewRat(100, 1))
+
+	return &Quote{
+		TokenIn:        in.token,
+		TokenOut:       out.token,
+		AmountIn:       amountIn.String(),
+		AmountOut:      amountOut.String(),
+		Fee:            fee.String(),
+		SpotPrice:      spot.FloatString(priceDecimals),
+		ExecutionPrice: execution.FloatString(priceDecimals),
+		PriceImpact:    impact.FloatString(priceDecimals),
+	}
+}
+
+// ceilDiv 向上取整的除法
+func ceilDiv(a *big.Int, b *big.Int) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(a, b, new(big.Int))
+	if remainder.Sign() > 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	return quotient
+}
@@ -0,0 +1,334 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 世界狀態命名空間
+const (
+	retirementPrefix           = "retirement"
+	retirementSequenceKey      = "retirementSequence"
+	retiredSupplyPrefix        = "retiredSupply"
+	retirementBeneficiaryIndex = "retirementBeneficiary"
+	retirementYearIndex        = "retirementYear"
+	retirementAccountIndex     = "retirementAccount"
+)
+
+// RetirementCertificate 定義註銷證書，寫入後不再修改，報告中引用 CertificateID 即可在鏈上核驗
+type RetirementCertificate struct {
+	CertificateID string `json:"certificateID"` // 按註銷順序編號，如 CCT-RET-00000001
+	Sequence      uint64 `json:"sequence"`
+	Account       string `json:"account"`     // 註銷代幣的賬戶
+	Beneficiary   string `json:"beneficiary"` // 抵消排放的受益方
+	Purpose       string `json:"purpose"`
+	ReportingYear int    `json:"reportingYear"`
+	Amount        uint64 `json:"amount"` // 註銷的 CCT 數量，即抵消的噸 CO2e
+	// 註銷批次信用時記錄批次屬性
+	LotID       string   `json:"lotID,omitempty" metadata:",optional"`
+	ProjectID   string   `json:"projectID,omitempty" metadata:",optional"`
+	Methodology string   `json:"methodology,omitempty" metadata:",optional"`
+	Vintage     int      `json:"vintage,omitempty" metadata:",optional"`
+	Country     string   `json:"country,omitempty" metadata:",optional"`
+	Serials     []string `json:"serials,omitempty" metadata:",optional"` // 註銷的序列號區間
+	TxID        string   `json:"txID"`
+	RetireTime  string   `json:"retireTime"`
+}
+
+// Retire 註銷調用者的 amount 個 CCT 用於抵消排放，代幣被銷毀且不能再次交易，返回註銷證書
+func (c *CarbonCoinToken) Retire(ctx contractapi.TransactionContextInterface, amount uint64, beneficiary string, purpose string, reportingYear int) (*RetirementCertificate, error) {
+	return retire(ctx, nil, amount, beneficiary, purpose, reportingYear)
+}
+
+// retire 銷毀調用者的信用並寫入註銷證書，lot 為nil時註銷不分批次的 CCT
+// 累計註銷量包含全部批次，批次自身的註銷量另行記錄
+func retire(ctx contractapi.TransactionContextInterface, lot *Lot, amount uint64, beneficiary string, purpose string, reportingYear int) (*RetirementCertificate, error) {
+	if amount == 0 {
+		return nil, fmt.Errorf("retire amount must be greater than 0")
+	}
+	if beneficiary == "" {
+		return nil, fmt.Errorf("beneficiary must not be empty")
+	}
+	if purpose == "" {
+		return nil, fmt.Errorf("purpose must not be empty")
+	}
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	// 報告年度不能晚於交易所在年度
+	if reportingYear <= 0 || reportingYear > time.Unix(txtime.Seconds, 0).UTC().Year() {
+		return nil, fmt.Errorf("invalid reporting year %d", reportingYear)
+	}
+
+	account, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	symbol := cctSymbol
+	if lot != nil {
+		symbol = lotSymbol(lot.LotID)
+		err = addRetiredSupply(ctx, symbol, amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = burn(ctx, symbol, account, amount)
+	if err != nil {
+		return nil, err
+	}
+	err = addRetiredSupply(ctx, cctSymbol, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	sequence, err := nextRetirementSequence(ctx)
+	if err != nil {
+		return nil, err
+	}
+	retireTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	certificate := RetirementCertificate{
+		CertificateID: fmt.Sprintf("%s-RET-%08d", cctSymbol, sequence),
+		Sequence:      sequence,
+		Account:       account,
+		Beneficiary:   beneficiary,
+		Purpose:       purpose,
+		ReportingYear: reportingYear,
+		Amount:        amount,
+		TxID:          ctx.GetStub().GetTxID(),
+		RetireTime:    retireTime,
+	}
+	lotID := cctSymbol
+	if lot != nil {
+		lotID = lot.LotID
+		certificate.LotID = lot.LotID
+		certificate.ProjectID = lot.ProjectID
+		certificate.Methodology = lot.Methodology
+		certificate.Vintage = lot.Vintage
+		certificate.Country = lot.Country
+	}
+
+	// 註銷的序列號標記為已註銷並指向本證書，不能再轉讓或重複註銷
+	serials, err := retireSerials(ctx, lotID, account, amount, certificate.CertificateID)
+	if err != nil {
+		return nil, err
+	}
+	for _, serialRange := range serials {
+		certificate.Serials = append(certificate.Serials, formatSerialRange(serialRange))
+	}
+	err = putRetirementCertificate(ctx, &certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一交易只保留最後一個事件，證書事件覆蓋 burn 發出的 Transfer 事件
+	err = emitEvent(ctx, "Retired", certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &certificate, nil
+}
+
+// RetiredSupply 查詢累計註銷的 CCT 數量，包含各批次的註銷量
+func (c *CarbonCoinToken) RetiredSupply(ctx contractapi.TransactionContextInterface) (uint64, error) {
+	return readRetiredSupply(ctx, cctSymbol)
+}
+
+// GetRetirementCertificate 通過證書編號查詢註銷證書
+func (c *CarbonCoinToken) GetRetirementCertificate(ctx contractapi.TransactionContextInterface, certificateID string) (*RetirementCertificate, error) {
+	return getRetirementCertificate(ctx, certificateID)
+}
+
+// GetRetirementsByBeneficiary 查詢受益方的全部註銷證書
+func (c *CarbonCoinToken) GetRetirementsByBeneficiary(ctx contractapi.TransactionContextInterface, beneficiary string) ([]*RetirementCertificate, error) {
+	return queryRetirements(ctx, retirementBeneficiaryIndex, beneficiary)
+}
+
+// GetRetirementsByYear 查詢某一報告年度的全部註銷證書
+func (c *CarbonCoinToken) GetRetirementsByYear(ctx contractapi.TransactionContextInterface, reportingYear int) ([]*RetirementCertificate, error) {
+	return queryRetirements(ctx, retirementYearIndex, yearAttribute(reportingYear))
+}
+
+// GetRetirementsByAccount 查詢賬戶註銷的全部證書，account 可以是賬戶ID或後端用戶ID
+func (c *CarbonCoinToken) GetRetirementsByAccount(ctx contractapi.TransactionContextInterface, account string) ([]*RetirementCertificate, error) {
+	account, err := resolveAccount(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return queryRetirements(ctx, retirementAccountIndex, account)
+}
+
+// nextRetirementSequence 遞增並返回證書序號，序號從1開始
+func nextRetirementSequence(ctx contractapi.TransactionContextInterface) (uint64, error) {
+	sequenceBytes, err := ctx.GetStub().GetState(retirementSequenceKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read retirement sequence: %v", err)
+	}
+	var sequence uint64
+	if sequenceBytes != nil {
+		err = json.Unmarshal(sequenceBytes, &sequence)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unmarshal retirement sequence: %v", err)
+		}
+	}
+	sequence, err = addUint64(sequence, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	sequenceBytes, err = json.Marshal(sequence)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal retirement sequence: %v", err)
+	}
+	err = ctx.GetStub().PutState(retirementSequenceKey, sequenceBytes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update retirement sequence: %v", err)
+	}
+
+	return sequence, nil
+}
+
+// addRetiredSupply 增加累計註銷量
+func addRetiredSupply(ctx contractapi.TransactionContextInterface, symbol string, amount uint64) error {
+	retired, err := readRetiredSupply(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	retired, err = addUint64(retired, amount)
+	if err != nil {
+		return err
+	}
+	return writeRetiredSupply(ctx, symbol, retired)
+}
+
+// readRetiredSupply 讀取累計註銷量
+func readRetiredSupply(ctx contractapi.TransactionContextInterface, symbol string) (uint64, error) {
+	retiredKey, err := ctx.GetStub().CreateCompositeKey(retiredSupplyPrefix, []string{symbol})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create retired supply key: %v", err)
+	}
+
+	retiredBytes, err := ctx.GetStub().GetState(retiredKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read retired supply: %v", err)
+	}
+	if retiredBytes == nil {
+		return 0, nil
+	}
+
+	var retired uint64
+	err = json.Unmarshal(retiredBytes, &retired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unmarshal retired supply: %v", err)
+	}
+
+	return retired, nil
+}
+
+// writeRetiredSupply 寫入累計註銷量
+func writeRetiredSupply(ctx contractapi.TransactionContextInterface, symbol string, retired uint64) error {
+	retiredKey, err := ctx.GetStub().CreateCompositeKey(retiredSupplyPrefix, []string{symbol})
+	if err != nil {
+		return fmt.Errorf("failed to create retired supply key: %v", err)
+	}
+
+	retiredBytes, err := json.Marshal(retired)
+	if err != nil {
+		return fmt.Errorf("failed to marshal retired supply: %v", err)
+	}
+	err = ctx.GetStub().PutState(retiredKey, retiredBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update retired supply: %v", err)
+	}
+
+	return nil
+}
+
+// yearAttribute 年度補零到固定寬度，保證索引按年度排序
+func yearAttribute(year int) string {
+	return fmt.Sprintf("%04d", year)
+}
+
+// getRetirementCertificate 讀取註銷證書
+func getRetirementCertificate(ctx contractapi.TransactionContextInterface, certificateID string) (*RetirementCertificate, error) {
+	certificateKey, err := ctx.GetStub().CreateCompositeKey(retirementPrefix, []string{certificateID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create retirement key: %v", err)
+	}
+
+	certificateBytes, err := ctx.GetStub().GetState(certificateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if certificateBytes == nil {
+		return nil, fmt.Errorf("the retirement certificate %s does not exist", certificateID)
+	}
+
+	var certificate RetirementCertificate
+	err = json.Unmarshal(certificateBytes, &certificate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal retirement certificate: %v", err)
+	}
+
+	return &certificate, nil
+}
+
+// putRetirementCertificate 寫入註銷證書並建立索引，證書編號已存在時拒絕覆蓋
+func putRetirementCertificate(ctx contractapi.TransactionContextInterface, certificate *RetirementCertificate) error {
+	certificateKey, err := ctx.GetStub().CreateCompositeKey(retirementPrefix, []string{certificate.CertificateID})
+	if err != nil {
+		return fmt.Errorf("failed to create retirement key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(certificateKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("the retirement certificate %s already exists", certificate.CertificateID)
+	}
+
+	certificateBytes, err := json.Marshal(certificate)
+	if err != nil {
+		return fmt.Errorf("failed to marshal retirement certificate: %v", err)
+	}
+	err = ctx.GetStub().PutState(certificateKey, certificateBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put retirement certificate: %v", err)
+	}
+
+	err = putIndex(ctx, retirementBeneficiaryIndex, []string{certificate.Beneficiary, certificate.CertificateID})
+	if err != nil {
+		return err
+	}
+	err = putIndex(ctx, retirementYearIndex, []string{yearAttribute(certificate.ReportingYear), certificate.CertificateID})
+	if err != nil {
+		return err
+	}
+	return putIndex(ctx, retirementAccountIndex, []string{certificate.Account, certificate.CertificateID})
+}
+
+// queryRetirements 通過索引查詢註銷證書，索引的最後一個屬性為證書編號
+func queryRetirements(ctx contractapi.TransactionContextInterface, index string, value string) ([]*RetirementCertificate, error) {
+	certificateIDs, err := queryIndex(ctx, index, value)
+	if err != nil {
+		return nil, err
+	}
+
+	certificates := []*RetirementCertificate{}
+	for _, certificateID := range certificateIDs {
+		certificate, err := getRetirementCertificate(ctx, certificateID)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	return certificates, nil
+}
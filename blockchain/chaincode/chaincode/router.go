@@ -0,0 +1,175 @@
+package chaincode
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 兑换路径最多包含的代币数量，即最多三跳
+const maxPathLength = 4
+
+// PathSwapResult 定义多跳兑换结果，Amounts[i] 为路径上第 i 个代币的数量
+type PathSwapResult struct {
+	TxID        string   `json:"txId"`
+	Path        []string `json:"path"`
+	Amounts     []string `json:"amounts"`
+	HaltedUntil int64    `json:"haltedUntil,omitempty" metadata:",optional"` // 非0表示某一跳触发熔断，整条路径未执行
+}
+
+// PathSwapEvent 多跳兑换事件
+type PathSwapEvent struct {
+	Trader  string   `json:"trader"`
+	Path    []string `json:"path"`
+	Amounts []string `json:"amounts"`
+}
+
+// SwapExactInAlongPath 沿 path 依次兑换，卖出 amountIn 个 path[0]，得到 path 最后一个代币
+// 相邻两个代币必须已有交易对，每一跳的输出直接转入下一跳的池子，所有跳在同一笔交易中完成
+// 最终输出低于 minAmountOut 或交易时间晚于 deadline 时整笔交易失败，任一跳触发熔断时只记录该交易对的熔断
+func (e *Exchange) SwapExactInAlongPath(ctx contractapi.TransactionContextInterface, path []string, amountIn string, minAmountOut string, deadline int64) (*PathSwapResult, error) {
+	err := checkDeadline(ctx, deadline)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := parsePositiveAmount(amountIn, "amountIn")
+	if err != nil {
+		return nil, err
+	}
+	minOut, err := parseAmount(minAmountOut, "minAmountOut")
+	if err != nil {
+		return nil, err
+	}
+	pools, err := pathPools(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	trader, err := getCallerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	protocolFee, err := getProtocolFee(ctx)
+	if err != nil {
+		return nil, err
+	}
+	breaker, err := getCircuitBreaker(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一代币会在上一跳的池子与下一跳的池子之间转移，转账汇总后统一写入
+	settle := newSettlement()
+	amounts := []*big.Int{amount}
+	payer := trader
+	for i, pool := range pools {
+		_, _, amountOut, err := applySwap(ctx, pool, path[i], amounts[i], protocolFee, breaker)
+		if trip, ok := err.(*circuitBreakerTrip); ok {
+			haltedUntil, err := tripCircuitBreaker(ctx, trip)
+			if err != nil {
+				return nil, err
+			}
+			return &PathSwapResult{TxID: ctx.GetStub().GetTxID(), Path: path, Amounts: amountStrings(amounts[:1]), HaltedUntil: haltedUntil}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		settle.move(path[i], payer, poolAccount(pool.PairID), amounts[i])
+		payer = poolAccount(pool.PairID)
+		amounts = append(amounts, amountOut)
+	}
+	amountOut := amounts[len(amounts)-1]
+	if amountOut.Cmp(minOut) < 0 {
+		return nil, fmt.Errorf("insufficient output amount: %s < %s", amountOut.String(), minOut.String())
+	}
+	settle.move(path[len(path)-1], payer, trader, amountOut)
+
+	err = settle.apply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, pool := range pools {
+		err = putPool(ctx, pool)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &PathSwapResult{
+		TxID:    ctx.GetStub().GetTxID(),
+		Path:    path,
+		Amounts: amountStrings(amounts),
+	}
+	err = emitEvent(ctx, "SwapAlongPath", PathSwapEvent{Trader: trader, Path: path, Amounts: result.Amounts})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAmountsOut 预览沿 path 卖出 amountIn 个 path[0] 时每一跳的数量，与实际兑换使用相同的手续费与取整方式
+func (e *Exchange) GetAmountsOut(ctx contractapi.TransactionContextInterface, path []string, amountIn string) ([]string, error) {
+	amount, err := parsePositiveAmount(amountIn, "amountIn")
+	if err != nil {
+		return nil, err
+	}
+	pools, err := pathPools(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	amounts := []*big.Int{amount}
+	for i, pool := range pools {
+		in, out, err := quoteSides(pool, path[i])
+		if err != nil {
+			return nil, err
+		}
+		amountOut, _ := getAmountOut(amounts[i], in.reserve, out.reserve, pool.SwapFeeNum, pool.SwapFeeDenom)
+		if amountOut.Sign() <= 0 {
+			return nil, fmt.Errorf("insufficient output amount at %s", pool.PairID)
+		}
+		amounts = append(amounts, amountOut)
+	}
+	return amountStrings(amounts), nil
+}
+
+// pathPools 校验兑换路径并按顺序读取每一跳的池子，路径中的代币不能重复
+func pathPools(ctx contractapi.TransactionContextInterface, path []string) ([]*Pool, error) {
+	if len(path) < 2 || len(path) > maxPathLength {
+		return nil, fmt.Errorf("path must contain between 2 and %d tokens", maxPathLength)
+	}
+	seen := make(map[string]bool)
+	for _, token := range path {
+		if seen[token] {
+			return nil, fmt.Errorf("token %s appears more than once in path", token)
+		}
+		seen[token] = true
+	}
+
+	pools := []*Pool{}
+	for i := 0; i < len(path)-1; i++ {
+		token0, token1, err := sortTokens(path[i], path[i+1])
+		if err != nil {
+			return nil, err
+		}
+		pool, err := getPool(ctx, token0+pairSeparator+token1)
+		if err != nil {
+			return nil, err
+		}
+		err = requireContinuous(pool)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// amountStrings 将金额列表转换为十进制字符串
+func amountStrings(amounts []*big.Int) []string {
+	result := make([]string, len(amounts))
+	for i, amount := range amounts {
+		result[i] = amount.String()
+	}
+	return result
+}
@@ -0,0 +1,331 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 序列號狀態
+const (
+	SerialStatusActive      = "ACTIVE"
+	SerialStatusRetired     = "RETIRED"
+	SerialStatusSurrendered = "SURRENDERED" // 清繳抵扣履約義務
+)
+
+// 不分批次的 CCT 以 CCT 作為批次ID分配序列號，序列號跟隨餘額在賬戶、池子、訂單簿與託管賬戶之間轉移，
+// 註銷或清繳時只從調用者自己持有的區間中按序列號從小到大消耗
+
+/*
+世界狀態命名空間
+序列號區間以普通鍵 serialRange~(MaxUint64-起始序列號) 保存，按鍵升序範圍查詢時第一條記錄即為起始序列號不大於查詢值的區間
+組合鍵不支持從中間開始的範圍查詢，因此這裡不使用組合鍵
+*/
+const (
+	serialRangePrefix   = "serialRange~"
+	serialRangeEnd      = "serialRange~~"
+	serialSequenceKey   = "serialSequence"
+	serialHoldingIndex  = "serialHolding"
+	serialNumberPattern = "%s-%012d"
+)
+
+// SerialRange 定義一段連續序列號 [Start, End]，同一區間的信用屬於同一批次、同一賬戶並處於同一狀態
+// 部分轉讓或註銷時區間拆分為兩段，序列號在簽發時全局遞增分配，不會重複
+type SerialRange struct {
+	Start         uint64 `json:"start"`
+	End           uint64 `json:"end"`
+	LotID         string `json:"lotID"`
+	Owner         string `json:"owner"`
+	Status        string `json:"status"`
+	CertificateID string `json:"certificateID,omitempty" metadata:",optional"` // 註銷時記錄註銷證書編號
+	PeriodID      string `json:"periodID,omitempty" metadata:",optional"`      // 清繳時記錄履約期
+}
+
+// SerialStatus 定義單個序列號的查詢結果
+type SerialStatus struct {
+	Serial        string       `json:"serial"`
+	Range         *SerialRange `json:"range"`
+	Status        string       `json:"status"`
+	Owner         string       `json:"owner"`
+	CertificateID string       `json:"certificateID,omitempty" metadata:",optional"`
+}
+
+// GetSerialOwner 查詢序列號當前的持有賬戶，已註銷的序列號返回註銷時的持有賬戶
+func (c *CarbonCoinToken) GetSerialOwner(ctx contractapi.TransactionContextInterface, serial uint64) (string, error) {
+	serialRange, err := findSerialRange(ctx, serial)
+	if err != nil {
+		return "", err
+	}
+	return serialRange.Owner, nil
+}
+
+// GetSerialStatus 查詢序列號所在區間、持有賬戶與是否已註銷
+func (c *CarbonCoinToken) GetSerialStatus(ctx contractapi.TransactionContextInterface, serial uint64) (*SerialStatus, error) {
+	serialRange, err := findSerialRange(ctx, serial)
+	if err != nil {
+		return nil, err
+	}
+	return &SerialStatus{
+		Serial:        formatSerial(serial),
+		Range:         serialRange,
+		Status:        serialRange.Status,
+		Owner:         serialRange.Owner,
+		CertificateID: serialRange.CertificateID,
+	}, nil
+}
+
+// GetSerialRanges 查詢賬戶持有的某一批次未註銷的序列號區間，owner 可以是賬戶ID或後端用戶ID
+func (c *CarbonCoinToken) GetSerialRanges(ctx contractapi.TransactionContextInterface, owner string, lotID string) ([]*SerialRange, error) {
+	owner, err := resolveAccount(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return heldSerialRanges(ctx, owner, lotID)
+}
+
+// issueSerials 為一次簽發分配連續的序列號區間
+func issueSerials(ctx contractapi.TransactionContextInterface, lotID string, owner string, amount uint64) (*SerialRange, error) {
+	start, err := allocateSerials(ctx, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	serialRange := &SerialRange{Start: start, End: start + amount - 1, LotID: lotID, Owner: owner, Status: SerialStatusActive}
+	err = putSerialRange(ctx, serialRange)
+	if err != nil {
+		return nil, err
+	}
+	return serialRange, nil
+}
+
+// allocateSerials 從全局序列中分配 amount 個連續的序列號並返回起始序列號
+// 鏈碼讀不到本交易自己寫入的狀態，一筆交易只能分配一次，需要多段區間時一次分配後自行切分
+func allocateSerials(ctx contractapi.TransactionContextInterface, amount uint64) (uint64, error) {
+	sequenceBytes, err := ctx.GetStub().GetState(serialSequenceKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read serial sequence: %v", err)
+	}
+	var last uint64
+	if sequenceBytes != nil {
+		err = json.Unmarshal(sequenceBytes, &last)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unmarshal serial sequence: %v", err)
+		}
+	}
+	end, err := addUint64(last, amount)
+	if err != nil {
+		return 0, err
+	}
+	sequenceBytes, err = json.Marshal(end)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal serial sequence: %v", err)
+	}
+	err = ctx.GetStub().PutState(serialSequenceKey, sequenceBytes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update serial sequence: %v", err)
+	}
+	return last + 1, nil
+}
+
+// issueCCTSerials 為一次不分批次的 CCT 鑄幣分配連續的序列號區間，區間歸屬鑄幣的賬戶
+func issueCCTSerials(ctx contractapi.TransactionContextInterface, owner string, amount uint64) (*SerialRange, error) {
+	return issueSerials(ctx, cctSymbol, owner, amount)
+}
+
+// moveSerials 將 from 持有的批次中序列號最小的 amount 個轉給 to
+func moveSerials(ctx contractapi.TransactionContextInterface, lotID string, from string, to string, amount uint64) error {
+	return distributeSerials(ctx, lotID, from, []string{to}, []uint64{amount})
+}
+
+// distributeSerials 將 from 持有的批次中序列號最小的部分依次轉給多個賬戶，recipients[i] 分得 amounts[i] 個
+// 鏈碼讀不到本交易自己寫入的狀態，同一賬戶在一筆交易中只能拆分一次，多個收款方需在一次調用中分配
+func distributeSerials(ctx contractapi.TransactionContextInterface, lotID string, from string, recipients []string, amounts []uint64) error {
+	_, err := splitSerials(ctx, lotID, from, amounts, func(index int, serialRange *SerialRange) {
+		serialRange.Owner = recipients[index]
+	})
+	return err
+}
+
+// retireSerials 註銷 owner 持有的批次中序列號最小的 amount 個，返回註銷的區間
+func retireSerials(ctx contractapi.TransactionContextInterface, lotID string, owner string, amount uint64, certificateID string) ([]*SerialRange, error) {
+	return splitSerials(ctx, lotID, owner, []uint64{amount}, func(_ int, serialRange *SerialRange) {
+		serialRange.Status = SerialStatusRetired
+		serialRange.CertificateID = certificateID
+	})
+}
+
+// surrenderSerials 將 owner 持有的批次中序列號最小的 amount 個標記為已清繳，記錄履約期
+func surrenderSerials(ctx contractapi.TransactionContextInterface, lotID string, owner string, amount uint64, periodID string) ([]*SerialRange, error) {
+	return splitSerials(ctx, lotID, owner, []uint64{amount}, func(_ int, serialRange *SerialRange) {
+		serialRange.Status = SerialStatusSurrendered
+		serialRange.PeriodID = periodID
+	})
+}
+
+// splitSerials 從 owner 的區間中按序列號從小到大依次取出 amounts[i] 個並交給 update 修改，amounts 均須大於0
+// 一個區間可能拆成多段，第一段沿用原鍵，其餘各段以新的起始序列號寫入，未取出的剩餘部分仍歸 owner
+func splitSerials(ctx contractapi.TransactionContextInterface, lotID string, owner string, amounts []uint64, update func(int, *SerialRange)) ([]*SerialRange, error) {
+	var total uint64
+	for _, amount := range amounts {
+		if amount == 0 {
+			return nil, fmt.Errorf("serial amount must be greater than 0")
+		}
+		var err error
+		total, err = addUint64(total, amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	held, err := heldSerialRanges(ctx, owner, lotID)
+	if err != nil {
+		return nil, err
+	}
+
+	carved := []*SerialRange{}
+	remaining := append([]uint64(nil), amounts...)
+	index := 0
+	for _, serialRange := range held {
+		if index == len(remaining) {
+			break
+		}
+		err = delIndex(ctx, serialHoldingIndex, serialHoldingAttributes(serialRange))
+		if err != nil {
+			return nil, err
+		}
+
+		for serialRange != nil && index < len(remaining) {
+			var rest *SerialRange
+			if size := serialRange.End - serialRange.Start + 1; size > remaining[index] {
+				split := *serialRange
+				split.Start = serialRange.Start + remaining[index]
+				serialRange.End = split.Start - 1
+				rest = &split
+			}
+			remaining[index] -= serialRange.End - serialRange.Start + 1
+			total -= serialRange.End - serialRange.Start + 1
+
+			update(index, serialRange)
+			err = putSerialRange(ctx, serialRange)
+			if err != nil {
+				return nil, err
+			}
+			carved = append(carved, serialRange)
+
+			if remaining[index] == 0 {
+				index++
+			}
+			serialRange = rest
+		}
+		if serialRange != nil {
+			err = putSerialRange(ctx, serialRange)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if total > 0 {
+		return nil, fmt.Errorf("account %s holds fewer serials of lot %s than required", owner, lotID)
+	}
+
+	return carved, nil
+}
+
+// heldSerialRanges 讀取賬戶持有的某一批次未註銷的區間，按起始序列號升序
+func heldSerialRanges(ctx contractapi.TransactionContextInterface, owner string, lotID string) ([]*SerialRange, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(serialHoldingIndex, []string{owner, lotID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	ranges := []*SerialRange{}
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split index key: %v", err)
+		}
+		var start uint64
+		_, err = fmt.Sscanf(attributes[len(attributes)-1], "%d", &start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid serial holding index: %v", err)
+		}
+		serialRange, err := findSerialRange(ctx, start)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, serialRange)
+	}
+	return ranges, nil
+}
+
+// findSerialRange 查找包含 serial 的區間
+func findSerialRange(ctx contractapi.TransactionContextInterface, serial uint64) (*SerialRange, error) {
+	if serial == 0 {
+		return nil, fmt.Errorf("serial must be greater than 0")
+	}
+	iterator, err := ctx.GetStub().GetStateByRange(serialRangeKey(serial), serialRangeEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer iterator.Close()
+
+	if iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var serialRange SerialRange
+		err = json.Unmarshal(queryResponse.Value, &serialRange)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal serial range: %v", err)
+		}
+		if serial <= serialRange.End {
+			return &serialRange, nil
+		}
+	}
+	return nil, fmt.Errorf("the serial %s has not been issued", formatSerial(serial))
+}
+
+// putSerialRange 寫入區間，未註銷的區間同時登記到持有賬戶的索引
+func putSerialRange(ctx contractapi.TransactionContextInterface, serialRange *SerialRange) error {
+	rangeBytes, err := json.Marshal(serialRange)
+	if err != nil {
+		return fmt.Errorf("failed to marshal serial range: %v", err)
+	}
+	err = ctx.GetStub().PutState(serialRangeKey(serialRange.Start), rangeBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put serial range: %v", err)
+	}
+
+	if serialRange.Status != SerialStatusActive {
+		return nil
+	}
+	return putIndex(ctx, serialHoldingIndex, serialHoldingAttributes(serialRange))
+}
+
+// serialRangeKey 區間的鍵，起始序列號取反後補零，使鍵的升序對應起始序列號的降序
+func serialRangeKey(start uint64) string {
+	return fmt.Sprintf("%s%020d", serialRangePrefix, math.MaxUint64-start)
+}
+
+// serialHoldingAttributes 持有索引的屬性，起始序列號補零以保證按鍵排序
+func serialHoldingAttributes(serialRange *SerialRange) []string {
+	return []string{serialRange.Owner, serialRange.LotID, fmt.Sprintf("%020d", serialRange.Start)}
+}
+
+// formatSerial 序列號的展示格式
+func formatSerial(serial uint64) string {
+	return fmt.Sprintf(serialNumberPattern, cctSymbol, serial)
+}
+
+// formatSerialRange 區間的展示格式
+func formatSerialRange(serialRange *SerialRange) string {
+	return formatSerial(serialRange.Start) + ".." + formatSerial(serialRange.End)
+}
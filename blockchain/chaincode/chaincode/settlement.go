@@ -0,0 +1,136 @@
+package chaincode
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// balanceRef 指向某个账户某种代币的余额
+type balanceRef struct {
+	symbol  string
+	account string
+}
+
+// settlement 汇总一笔交易中的多次转账，最后对每个余额只读写一次
+// 链码读不到本交易自己写入的状态，同一账户在一笔交易中多次调用 transfer 会相互覆盖
+type settlement struct {
+	deltas map[balanceRef]*big.Int
+}
+
+// newSettlement 创建空的结算
+func newSettlement() *settlement {
+	return &settlement{deltas: make(map[balanceRef]*big.Int)}
+}
+
+// move 记录一笔从 from 到 to 的转账，金额为0时忽略
+func (s *settlement) move(symbol string, from string, to string, amount *big.Int) {
+	if amount.Sign() == 0 {
+		return
+	}
+	s.add(balanceRef{symbol: symbol, account: from}, new(big.Int).Neg(amount))
+	s.add(balanceRef{symbol: symbol, account: to}, amount)
+}
+
+// add 累加账户余额的变动
+func (s *settlement) add(ref balanceRef, amount *big.Int) {
+	delta, ok := s.deltas[ref]
+	if !ok {
+		delta = big.NewInt(0)
+		s.deltas[ref] = delta
+	}
+	delta.Add(delta, amount)
+}
+
+// apply 按代币与账户排序写入净变动，任一余额不足时整笔交易失败
+func (s *settlement) apply(ctx contractapi.TransactionContextInterface) error {
+	refs := make([]balanceRef, 0, len(s.deltas))
+	for ref, delta := range s.deltas {
+		if delta.Sign() != 0 {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].symbol != refs[j].symbol {
+			return refs[i].symbol < refs[j].symbol
+		}
+		return refs[i].account < refs[j].account
+	})
+
+	for _, ref := range refs {
+		balance, err := readBalance(ctx, ref.symbol, ref.account)
+		if err != nil {
+			return err
+		}
+		newBalance := new(big.Int).SetUint64(balance)
+		newBalance.Add(newBalance, s.deltas[ref])
+		if newBalance.Sign() < 0 {
+			return fmt.Errorf("account %s has insufficient %s balance", ref.account, ref.symbol)
+		}
+		value, err := toUint64(newBalance)
+		if err != nil {
+			return err
+		}
+		err = writeBalance(ctx, ref.symbol, ref.account, value)
+		if err != nil {
+			return err
+		}
+	}
+	return s.moveSerials(ctx, refs)
+}
+
+// moveSerials 按净变动转移不分批次 CCT 的序列号，转出方依次分给各转入方
+// 每个转出方只拆分一次自己的区间，转出总量与转入总量相等
+func (s *settlement) moveSerials(ctx contractapi.TransactionContextInterface, refs []balanceRef) error {
+	var senders []balanceRef
+	var recipients []string
+	var received []uint64
+	for _, ref := range refs {
+		if ref.symbol != cctSymbol {
+			continue
+		}
+		if s.deltas[ref].Sign() < 0 {
+			senders = append(senders, ref)
+			continue
+		}
+		value, err := toUint64(s.deltas[ref])
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, ref.account)
+		received = append(received, value)
+	}
+
+	next := 0
+	for _, sender := range senders {
+		sent, err := toUint64(new(big.Int).Neg(s.deltas[sender]))
+		if err != nil {
+			return err
+		}
+		var to []string
+		var amounts []uint64
+		for sent > 0 && next < len(recipients) {
+			amount := received[next]
+			if amount > sent {
+				amount = sent
+			}
+			to = append(to, recipients[next])
+			amounts = append(amounts, amount)
+			received[next] -= amount
+			sent -= amount
+			if received[next] == 0 {
+				next++
+			}
+		}
+		if sent > 0 {
+			return fmt.Errorf("unbalanced %s settlement", cctSymbol)
+		}
+		err = distributeSerials(ctx, cctSymbol, sender.account, to, amounts)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
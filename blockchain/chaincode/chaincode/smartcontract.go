@@ -6,17 +6,28 @@ import (
 	"log"
 	"time"
 
-	"google.golang.org/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// 世界状态命名空间
+const (
+	userPrefix = "user"
+	lotPrefix  = "lot"
+)
+
 // 定义合约结构体
 type SmartContract struct {
 	contractapi.Contract
 }
 
-// 注册用户
+// 注册用户，并将用户ID绑定到调用者账户
 func (s *SmartContract) RegisterUser(ctx contractapi.TransactionContextInterface, userID string, userType string, realInfoHash string) error {
+	err := bindAlias(ctx, userID)
+	if err != nil {
+		return err
+	}
+
 	user := User{
 		UserID:       userID,
 		UserType:     userType,
@@ -27,7 +38,11 @@ func (s *SmartContract) RegisterUser(ctx contractapi.TransactionContextInterface
 	if err != nil {
 		return err
 	}
-	err = ctx.GetStub().PutState(userID, userAsBytes)
+	key, err := userKey(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(key, userAsBytes)
 	if err != nil {
 		return err
 	}
@@ -36,6 +51,12 @@ func (s *SmartContract) RegisterUser(ctx contractapi.TransactionContextInterface
 
 // 农产品上链，传入用户ID、农产品上链信息
 func (s *SmartContract) Uplink(ctx contractapi.TransactionContextInterface, userID string, traceability_code string, arg1 string, arg2 string, arg3 string, arg4 string, arg5 string) (string, error) {
+	// 只有绑定了该用户ID的账户才能代其上链
+	err := requireAlias(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
 	// 获取用户类型
 	userType, err := s.GetUserType(ctx, userID)
 	if err != nil {
@@ -43,7 +64,11 @@ func (s *SmartContract) Uplink(ctx contractapi.TransactionContextInterface, user
 	}
 
 	// 通过溯源码获取农产品的上链信息
-	FruitAsBytes, err := ctx.GetStub().GetState(traceability_code)
+	fruitKey, err := lotKey(ctx, traceability_code)
+	if err != nil {
+		return "", err
+	}
+	FruitAsBytes, err := ctx.GetStub().GetState(fruitKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to read from world state: %v", err)
 	}
@@ -119,7 +144,7 @@ func (s *SmartContract) Uplink(ctx contractapi.TransactionContextInterface, user
 		return "", fmt.Errorf("failed to marshal fruit: %v", err)
 	}
 	//将农产品的信息存入区块链
-	err = ctx.GetStub().PutState(traceability_code, fruitAsBytes)
+	err = ctx.GetStub().PutState(fruitKey, fruitAsBytes)
 	if err != nil {
 		return "", fmt.Errorf("failed to put fruit: %v", err)
 	}
@@ -135,7 +160,16 @@ func (s *SmartContract) Uplink(ctx contractapi.TransactionContextInterface, user
 
 // 添加农产品到用户的农产品列表
 func (s *SmartContract) AddFruit(ctx contractapi.TransactionContextInterface, userID string, fruit *Fruit) error {
-	userBytes, err := ctx.GetStub().GetState(userID)
+	err := requireAlias(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	key, err := userKey(ctx, userID)
+	if err != nil {
+		return err
+	}
+	userBytes, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return fmt.Errorf("failed to read from world state: %v", err)
 	}
@@ -153,7 +187,7 @@ func (s *SmartContract) AddFruit(ctx contractapi.TransactionContextInterface, us
 	if err != nil {
 		return err
 	}
-	err = ctx.GetStub().PutState(userID, userAsBytes)
+	err = ctx.GetStub().PutState(key, userAsBytes)
 	if err != nil {
 		return err
 	}
@@ -162,7 +196,11 @@ func (s *SmartContract) AddFruit(ctx contractapi.TransactionContextInterface, us
 
 // 获取用户类型
 func (s *SmartContract) GetUserType(ctx contractapi.TransactionContextInterface, userID string) (string, error) {
-	userBytes, err := ctx.GetStub().GetState(userID)
+	key, err := userKey(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	userBytes, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return "", fmt.Errorf("failed to read from world state: %v", err)
 	}
@@ -180,7 +218,11 @@ func (s *SmartContract) GetUserType(ctx contractapi.TransactionContextInterface,
 
 // 获取用户信息
 func (s *SmartContract) GetUserInfo(ctx contractapi.TransactionContextInterface, userID string) (*User, error) {
-	userBytes, err := ctx.GetStub().GetState(userID)
+	key, err := userKey(ctx, userID)
+	if err != nil {
+		return &User{}, err
+	}
+	userBytes, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return &User{}, fmt.Errorf("failed to read from world state: %v", err)
 	}
@@ -198,7 +240,11 @@ func (s *SmartContract) GetUserInfo(ctx contractapi.TransactionContextInterface,
 
 // 获取农产品的上链信息
 func (s *SmartContract) GetFruitInfo(ctx contractapi.TransactionContextInterface, traceability_code string) (*Fruit, error) {
-	FruitAsBytes, err := ctx.GetStub().GetState(traceability_code)
+	fruitKey, err := lotKey(ctx, traceability_code)
+	if err != nil {
+		return &Fruit{}, err
+	}
+	FruitAsBytes, err := ctx.GetStub().GetState(fruitKey)
 	if err != nil {
 		return &Fruit{}, fmt.Errorf("failed to read from world state: %v", err)
 	}
@@ -219,7 +265,11 @@ func (s *SmartContract) GetFruitInfo(ctx contractapi.TransactionContextInterface
 
 // 获取用户的农产品ID列表
 func (s *SmartContract) GetFruitList(ctx contractapi.TransactionContextInterface, userID string) ([]*Fruit, error) {
-	userBytes, err := ctx.GetStub().GetState(userID)
+	key, err := userKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	userBytes, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
 	}
@@ -237,7 +287,7 @@ func (s *SmartContract) GetFruitList(ctx contractapi.TransactionContextInterface
 
 // 获取所有的农产品信息
 func (s *SmartContract) GetAllFruitInfo(ctx contractapi.TransactionContextInterface) ([]Fruit, error) {
-	fruitListAsBytes, err := ctx.GetStub().GetStateByRange("", "")
+	fruitListAsBytes, err := ctx.GetStub().GetStateByPartialCompositeKey(lotPrefix, []string{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
 	}
@@ -265,7 +315,11 @@ func (s *SmartContract) GetAllFruitInfo(ctx contractapi.TransactionContextInterf
 func (s *SmartContract) GetFruitHistory(ctx contractapi.TransactionContextInterface, traceability_code string) ([]HistoryQueryResult, error) {
 	log.Printf("GetAssetHistory: ID %v", traceability_code)
 
-	resultsIterator, err := ctx.GetStub().GetHistoryForKey(traceability_code)
+	fruitKey, err := lotKey(ctx, traceability_code)
+	if err != nil {
+		return nil, err
+	}
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(fruitKey)
 	if err != nil {
 		return nil, err
 	}
@@ -316,3 +370,21 @@ func (s *SmartContract) GetFruitHistory(ctx contractapi.TransactionContextInterf
 
 	return records, nil
 }
+
+// userKey 用户存放在 user~userID 组合键下
+func userKey(ctx contractapi.TransactionContextInterface, userID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(userPrefix, []string{userID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create user key: %v", err)
+	}
+	return key, nil
+}
+
+// lotKey 农产品存放在 lot~溯源码 组合键下
+func lotKey(ctx contractapi.TransactionContextInterface, traceability_code string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(lotPrefix, []string{traceability_code})
+	if err != nil {
+		return "", fmt.Errorf("failed to create lot key: %v", err)
+	}
+	return key, nil
+}
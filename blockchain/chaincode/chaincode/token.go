@@ -0,0 +1,385 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// 世界狀態命名空間，鍵的第一個屬性為代幣符號，CCT 與 STABLE 共用同一套記賬邏輯
+const (
+	balancePrefix   = "balance"
+	supplyPrefix    = "supply"
+	allowancePrefix = "allowance"
+)
+
+// Token 定義代幣結構
+type Token struct {
+	Owner   string `json:"owner"`
+	Balance uint64 `json:"balance"`
+}
+
+// Allowance 定義授權額度結構
+type Allowance struct {
+	Owner   string `json:"owner"`
+	Spender string `json:"spender"`
+	Value   uint64 `json:"value"`
+}
+
+// TransferEvent ERC-20 Transfer 事件，鑄造時 From 為空，銷毀時 To 為空
+type TransferEvent struct {
+	Token string `json:"token"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value uint64 `json:"value"`
+}
+
+// ApprovalEvent ERC-20 Approval 事件
+type ApprovalEvent struct {
+	Token   string `json:"token"`
+	Owner   string `json:"owner"`
+	Spender string `json:"spender"`
+	Value   uint64 `json:"value"`
+}
+
+// callerTransfer 從調用者賬戶轉賬給 to，to 可以是賬戶ID或後端用戶ID
+func callerTransfer(ctx contractapi.TransactionContextInterface, symbol string, to string, amount uint64) error {
+	from, err := getCallerAccount(ctx)
+	if err != nil {
+		return err
+	}
+	to, err = resolveAccount(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	return transfer(ctx, symbol, from, to, amount)
+}
+
+// callerApprove 授權 spender 代表調用者轉出最多 amount 個代幣，覆蓋原有額度
+func callerApprove(ctx contractapi.TransactionContextInterface, symbol string, spender string, amount uint64) error {
+	owner, err := getCallerAccount(ctx)
+	if err != nil {
+		return err
+	}
+	spender, err = resolveAccount(ctx, spender)
+	if err != nil {
+		return err
+	}
+	if spender == owner {
+		return fmt.Errorf("invalid spender")
+	}
+
+	err = writeAllowance(ctx, symbol, owner, spender, amount)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "Approval", ApprovalEvent{Token: symbol, Owner: owner, Spender: spender, Value: amount})
+}
+
+// callerTransferFrom 調用者使用 from 的授權額度轉賬給 to
+func callerTransferFrom(ctx contractapi.TransactionContextInterface, symbol string, from string, to string, amount uint64) error {
+	spender, err := getCallerAccount(ctx)
+	if err != nil {
+		return err
+	}
+	from, err = resolveAccount(ctx, from)
+	if err != nil {
+		return err
+	}
+	to, err = resolveAccount(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	allowance, err := readAllowance(ctx, symbol, from, spender)
+	if err != nil {
+		return err
+	}
+	if allowance < amount {
+		return fmt.Errorf("spender %s has insufficient allowance from %s", spender, from)
+	}
+
+	err = transfer(ctx, symbol, from, to, amount)
+	if err != nil {
+		return err
+	}
+
+	// 扣減授權額度
+	return writeAllowance(ctx, symbol, from, spender, allowance-amount)
+}
+
+// queryBalance 查詢餘額，owner 可以是賬戶ID或後端用戶ID
+func queryBalance(ctx contractapi.TransactionContextInterface, symbol string, owner string) (uint64, error) {
+	owner, err := resolveAccount(ctx, owner)
+	if err != nil {
+		return 0, err
+	}
+	return readBalance(ctx, symbol, owner)
+}
+
+// queryAllowance 查詢 owner 授權給 spender 的剩餘額度
+func queryAllowance(ctx contractapi.TransactionContextInterface, symbol string, owner string, spender string) (uint64, error) {
+	owner, err := resolveAccount(ctx, owner)
+	if err != nil {
+		return 0, err
+	}
+	spender, err = resolveAccount(ctx, spender)
+	if err != nil {
+		return 0, err
+	}
+	return readAllowance(ctx, symbol, owner, spender)
+}
+
+// mint 增發代幣並增加總供應量
+func mint(ctx contractapi.TransactionContextInterface, symbol string, owner string, amount uint64) error {
+	if amount == 0 {
+		return fmt.Errorf("mint amount must be greater than 0")
+	}
+
+	// 增加代幣餘額
+	balance, err := readBalance(ctx, symbol, owner)
+	if err != nil {
+		return err
+	}
+	balance, err = addUint64(balance, amount)
+	if err != nil {
+		return err
+	}
+	err = writeBalance(ctx, symbol, owner, balance)
+	if err != nil {
+		return err
+	}
+
+	// 增加總供應量
+	supply, err := readTotalSupply(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	supply, err = addUint64(supply, amount)
+	if err != nil {
+		return err
+	}
+	err = writeTotalSupply(ctx, symbol, supply)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "Transfer", TransferEvent{Token: symbol, From: "", To: owner, Value: amount})
+}
+
+// burn 銷毀代幣並減少總供應量
+func burn(ctx contractapi.TransactionContextInterface, symbol string, owner string, amount uint64) error {
+	if amount == 0 {
+		return fmt.Errorf("burn amount must be greater than 0")
+	}
+
+	balance, err := readBalance(ctx, symbol, owner)
+	if err != nil {
+		return err
+	}
+	if balance < amount {
+		return fmt.Errorf("account %s has insufficient balance", owner)
+	}
+	err = writeBalance(ctx, symbol, owner, balance-amount)
+	if err != nil {
+		return err
+	}
+
+	supply, err := readTotalSupply(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	if supply < amount {
+		return fmt.Errorf("total supply of %s is less than burn amount", symbol)
+	}
+	err = writeTotalSupply(ctx, symbol, supply-amount)
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, "Transfer", TransferEvent{Token: symbol, From: owner, To: "", Value: amount})
+}
+
+// transfer 在兩個賬戶之間轉移代幣並發出 Transfer 事件
+func transfer(ctx contractapi.TransactionContextInterface, symbol string, from string, to string, amount uint64) error {
+	if amount == 0 {
+		return fmt.Errorf("transfer amount must be greater than 0")
+	}
+	if to == "" {
+		return fmt.Errorf("invalid recipient")
+	}
+
+	fromBalance, err := readBalance(ctx, symbol, from)
+	if err != nil {
+		return err
+	}
+	if fromBalance < amount {
+		return fmt.Errorf("account %s has insufficient %s balance", from, symbol)
+	}
+	// 與 ERC-20 一致，轉給自己不改變餘額與序列號，但仍發出 Transfer 事件
+	if from == to {
+		return emitEvent(ctx, "Transfer", TransferEvent{Token: symbol, From: from, To: to, Value: amount})
+	}
+	toBalance, err := readBalance(ctx, symbol, to)
+	if err != nil {
+		return err
+	}
+	toBalance, err = addUint64(toBalance, amount)
+	if err != nil {
+		return err
+	}
+
+	err = writeBalance(ctx, symbol, from, fromBalance-amount)
+	if err != nil {
+		return err
+	}
+	err = writeBalance(ctx, symbol, to, toBalance)
+	if err != nil {
+		return err
+	}
+	// 不分批次的 CCT 序列號跟隨餘額轉移，批次信用的序列號由 transferLot 轉移
+	if symbol == cctSymbol {
+		err = moveSerials(ctx, cctSymbol, from, to, amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	return emitEvent(ctx, "Transfer", TransferEvent{Token: symbol, From: from, To: to, Value: amount})
+}
+
+// readBalance 讀取賬戶餘額，餘額存放在 balance~symbol~owner 組合鍵下，沒有記錄時返回0
+func readBalance(ctx contractapi.TransactionContextInterface, symbol string, owner string) (uint64, error) {
+	balanceKey, err := ctx.GetStub().CreateCompositeKey(balancePrefix, []string{symbol, owner})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create balance key: %v", err)
+	}
+
+	tokenBytes, err := ctx.GetStub().GetState(balanceKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if tokenBytes == nil {
+		return 0, nil
+	}
+
+	var token Token
+	err = json.Unmarshal(tokenBytes, &token)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unmarshal token: %v", err)
+	}
+
+	return token.Balance, nil
+}
+
+// writeBalance 寫入賬戶餘額
+func writeBalance(ctx contractapi.TransactionContextInterface, symbol string, owner string, balance uint64) error {
+	if owner == "" {
+		return fmt.Errorf("owner must not be empty")
+	}
+
+	balanceKey, err := ctx.GetStub().CreateCompositeKey(balancePrefix, []string{symbol, owner})
+	if err != nil {
+		return fmt.Errorf("failed to create balance key: %v", err)
+	}
+
+	tokenBytes, err := json.Marshal(Token{Owner: owner, Balance: balance})
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %v", err)
+	}
+	err = ctx.GetStub().PutState(balanceKey, tokenBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update state: %v", err)
+	}
+
+	return nil
+}
+
+// readTotalSupply 讀取代幣總供應量
+func readTotalSupply(ctx contractapi.TransactionContextInterface, symbol string) (uint64, error) {
+	supplyKey, err := ctx.GetStub().CreateCompositeKey(supplyPrefix, []string{symbol})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create supply key: %v", err)
+	}
+
+	supplyBytes, err := ctx.GetStub().GetState(supplyKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read total supply: %v", err)
+	}
+	if supplyBytes == nil {
+		return 0, nil
+	}
+
+	var supply uint64
+	err = json.Unmarshal(supplyBytes, &supply)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unmarshal total supply: %v", err)
+	}
+
+	return supply, nil
+}
+
+// writeTotalSupply 寫入代幣總供應量
+func writeTotalSupply(ctx contractapi.TransactionContextInterface, symbol string, supply uint64) error {
+	supplyKey, err := ctx.GetStub().CreateCompositeKey(supplyPrefix, []string{symbol})
+	if err != nil {
+		return fmt.Errorf("failed to create supply key: %v", err)
+	}
+
+	supplyBytes, err := json.Marshal(supply)
+	if err != nil {
+		return fmt.Errorf("failed to marshal total supply: %v", err)
+	}
+	err = ctx.GetStub().PutState(supplyKey, supplyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update total supply: %v", err)
+	}
+
+	return nil
+}
+
+// readAllowance 讀取授權額度，授權記錄存放在 allowance~symbol~owner~spender 組合鍵下
+func readAllowance(ctx contractapi.TransactionContextInterface, symbol string, owner string, spender string) (uint64, error) {
+	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{symbol, owner, spender})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create allowance key: %v", err)
+	}
+
+	allowanceBytes, err := ctx.GetStub().GetState(allowanceKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read allowance: %v", err)
+	}
+	if allowanceBytes == nil {
+		return 0, nil
+	}
+
+	var allowance Allowance
+	err = json.Unmarshal(allowanceBytes, &allowance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unmarshal allowance: %v", err)
+	}
+
+	return allowance.Value, nil
+}
+
+// writeAllowance 寫入授權額度
+func writeAllowance(ctx contractapi.TransactionContextInterface, symbol string, owner string, spender string, value uint64) error {
+	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{symbol, owner, spender})
+	if err != nil {
+		return fmt.Errorf("failed to create allowance key: %v", err)
+	}
+
+	allowanceBytes, err := json.Marshal(Allowance{Owner: owner, Spender: spender, Value: value})
+	if err != nil {
+		return fmt.Errorf("failed to marshal allowance: %v", err)
+	}
+	err = ctx.GetStub().PutState(allowanceKey, allowanceBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update allowance: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// getTxTime 獲取交易時間戳並格式化為北京時間
+func getTxTime(ctx contractapi.TransactionContextInterface) (string, error) {
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return "", fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	timeLocation, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		return "", fmt.Errorf("failed to load time location: %v", err)
+	}
+
+	return time.Unix(txtime.Seconds, 0).In(timeLocation).Format("2006-01-02 15:04:05"), nil
+}
+
+// putIndex 寫入組合鍵索引，索引只依靠鍵本身，值為佔位字節
+func putIndex(ctx contractapi.TransactionContextInterface, index string, attributes []string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(index, attributes)
+	if err != nil {
+		return fmt.Errorf("failed to create %s index key: %v", index, err)
+	}
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return fmt.Errorf("failed to put %s index: %v", index, err)
+	}
+	return nil
+}
+
+// delIndex 刪除組合鍵索引
+func delIndex(ctx contractapi.TransactionContextInterface, index string, attributes []string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(index, attributes)
+	if err != nil {
+		return fmt.Errorf("failed to create %s index key: %v", index, err)
+	}
+	err = ctx.GetStub().DelState(indexKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s index: %v", index, err)
+	}
+	return nil
+}
+
+// emitEvent 將事件序列化後通過 SetEvent 發出
+func emitEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	eventBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", name, err)
+	}
+	err = ctx.GetStub().SetEvent(name, eventBytes)
+	if err != nil {
+		return fmt.Errorf("failed to set %s event: %v", name, err)
+	}
+
+	return nil
+}
+
+// addUint64 帶溢出檢查的加法
+func addUint64(a uint64, b uint64) (uint64, error) {
+	sum := a + b
+	if sum < a {
+		return 0, fmt.Errorf("math: addition overflow")
+	}
+	return sum, nil
+}
+
+// checkDeadline 校驗交易時間戳未超過截止時間（Unix 秒），防止交易在排序期間被延遲執行
+func checkDeadline(ctx contractapi.TransactionContextInterface, deadline int64) error {
+	if deadline <= 0 {
+		return fmt.Errorf("invalid deadline")
+	}
+	txtime, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to read TxTimestamp: %v", err)
+	}
+	if txtime.Seconds > deadline {
+		return fmt.Errorf("transaction expired: deadline %d, tx time %d", deadline, txtime.Seconds)
+	}
+	return nil
+}
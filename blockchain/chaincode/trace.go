@@ -12,8 +12,8 @@ import (
 )
 
 func main() {
-	// 創建組合 chaincode
-	cc, err := contractapi.NewChaincode(&chaincode.CarbonCoinToken{}, &chaincode.Exchange{})
+	// 創建組合 chaincode，SmartContract 作為默認合約，其餘合約以 "合約名:函數名" 調用
+	cc, err := contractapi.NewChaincode(&chaincode.SmartContract{}, &chaincode.CarbonCoinToken{}, &chaincode.StableCoin{}, &chaincode.Exchange{}, &chaincode.LimitOrderBook{}, &chaincode.OTC{}, &chaincode.Compliance{}, &chaincode.MRV{})
 	if err != nil {
 		log.Panicf("Error creating combined chaincode: %v", err)
 	}
@@ -23,10 +23,10 @@ do
 done
 
 
-# 启动区块链网络、创建通道
-./network.sh up createChannel
-# 部署链码，使用trace链码
-./network.sh deployCC -ccn trace -ccp ../chaincode -ccl go
+# 启动区块链网络、创建通道，身份由 Fabric CA 签发，链码按证书属性 role 识别监管机构、核查机构与后端登记身份
+./network.sh up createChannel -ca
+# 部署链码，使用trace链码，场外交易的私有数据集合定义在 collections_config.json
+./network.sh deployCC -ccn trace -ccp ../chaincode -ccl go -cccg ../chaincode/collections_config.json
 cp -r organizations explorer/
 
 # 启动区块链浏览器
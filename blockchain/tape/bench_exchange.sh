@@ -0,0 +1,87 @@
+#!/bin/bash
+# 交易所池子记录拆分前后的吞吐对比
+# 用法：./bench_exchange.sh 标签 [交易数]，例如在旧版链码上执行 ./bench_exchange.sh before，升级后执行 ./bench_exchange.sh after
+# 执行前 User1 需已注册账户，持有足够的 CCT 与 STABLE，并已向 CCT-STABLE 池注入流动性；
+# 后端用户的资产记在 User1 名下各自的子账户中，压测使用的是 User1 本身的账户，需以 User1 身份直接调用 Exchange:AddLiquidity
+# 份额转让由 User2 至 User5 四个 LP 身份并发发起，收款方分别为用户12至15（User1 名下的子账户），
+# 首次执行时以 User1 注册收款方，并按交易数向每个 LP 身份划拨份额
+# 结果追加写入 bench_exchange.log，每组压测记录 tape 输出的 tps 与耗时
+label=${1:?"用法: ./bench_exchange.sh 标签 [交易数]"}
+num=${2:-1000}
+log=bench_exchange.log
+lps=(2 3 4 5)
+# 每个 LP 身份发起的转让数，合计为 num
+per_lp=$((num / ${#lps[@]}))
+
+network=$(cd ../network && pwd)
+org1=$network/organizations/peerOrganizations/org1.example.com
+org2=$network/organizations/peerOrganizations/org2.example.com
+export PATH=$network/bin:$PATH
+export FABRIC_CFG_PATH=$network/config
+export CORE_PEER_TLS_ENABLED=true
+export CORE_PEER_LOCALMSPID=Org1MSP
+export CORE_PEER_ADDRESS=localhost:7051
+export CORE_PEER_TLS_ROOTCERT_FILE=$org1/tlsca/tlsca.org1.example.com-cert.pem
+
+# 以 org1 的某个用户身份调用链码，$1 为用户名，其余为 peer chaincode 的参数
+as_user() {
+    local user=$1
+    shift
+    CORE_PEER_MSPCONFIGPATH=$org1/users/$user@org1.example.com/msp peer chaincode "$@"
+}
+
+invoke() {
+    local user=$1 args=$2
+    as_user "$user" invoke -o localhost:7050 --ordererTLSHostnameOverride orderer.example.com \
+        --tls --cafile "$network/organizations/ordererOrganizations/example.com/tlsca/tlsca.example.com-cert.pem" \
+        -C mychannel -n trace --waitForEvent \
+        --peerAddresses localhost:7051 --tlsRootCertFiles "$org1/tlsca/tlsca.org1.example.com-cert.pem" \
+        --peerAddresses localhost:9051 --tlsRootCertFiles "$org2/tlsca/tlsca.org2.example.com-cert.pem" \
+        -c "$args"
+}
+
+# 准备：注册收款方，LP 身份登记账户后由 User1 划拨 per_lp 份份额
+setup() {
+    for lp in "${lps[@]}"; do
+        invoke User1 "{\"Args\":[\"RegisterUser\",\"1$lp\",\"bench\",\"bench\"]}" > /dev/null 2>&1
+        invoke User$lp '{"Args":["RegisterAccount"]}' > /dev/null 2>&1
+        account=$(as_user User$lp query -C mychannel -n trace -c '{"Args":["GetMyAccount"]}' | sed -n 's/.*"accountID":"\([0-9a-f]*\)".*/\1/p')
+        if [ -z "$account" ]; then
+            echo "User$lp 的账户登记失败" >&2
+            exit 1
+        fi
+        invoke User1 "{\"Args\":[\"Exchange:Transfer\",\"CCT-STABLE\",\"$account\",\"$per_lp\"]}" > /dev/null 2>&1 || {
+            echo "向 User$lp 划拨份额失败，User1 需持有至少 $((per_lp * ${#lps[@]})) 份 CCT-STABLE 份额" >&2
+            exit 1
+        }
+    done
+}
+
+# 四个 LP 身份并发转让，$1 为输出前缀
+lp_transfers() {
+    for lp in "${lps[@]}"; do
+        ./tape --config config_lp_transfer_user$lp.yaml -n $per_lp 2>&1 | grep -iE "tps|duration|elapsed" | sed "s/^/${1}User$lp: /" | tee -a $log &
+    done
+}
+
+run() {
+    echo "[$label] $1" | tee -a $log
+    ./tape --config "$2" -n $num 2>&1 | grep -iE "tps|duration|elapsed" | tee -a $log
+}
+
+setup
+
+# 只有兑换：同一区块内的兑换都会改写池子记录
+run "swap only" config_swap.yaml
+
+# 只有份额转让：拆分前每次转让都会改写池子记录，拆分后四组转让读写的持仓互不重叠
+echo "[$label] lp transfer only" | tee -a $log
+lp_transfers ""
+wait
+
+# 兑换与份额转让并发：拆分前两类交易在池子记录上相互冲突，拆分后互不影响
+setup
+echo "[$label] swap + lp transfer" | tee -a $log
+./tape --config config_swap.yaml -n $num 2>&1 | grep -iE "tps|duration|elapsed" | sed 's/^/swap: /' | tee -a $log &
+lp_transfers "lp transfer "
+wait